@@ -50,6 +50,14 @@ type BFDProfileSpec struct {
 	// +kubebuilder:validation:Minimum:=10
 	// +optional
 	EchoInterval *uint32 `json:"echoInterval,omitempty"`
+	// Configures the minimal echo receive interval that this system
+	// is capable of handling in milliseconds, separately from the
+	// echoInterval, for the BGP implementations supporting it.
+	// Not set by default.
+	// +kubebuilder:validation:Maximum:=60000
+	// +kubebuilder:validation:Minimum:=10
+	// +optional
+	EchoReceiveInterval *uint32 `json:"echoReceiveInterval,omitempty"`
 	// Enables or disables the echo transmission mode.
 	// This mode is disabled by default, and not supported on multi
 	// hops setups.
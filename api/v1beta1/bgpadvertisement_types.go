@@ -64,6 +64,12 @@ type BGPAdvertisementSpec struct {
 	// When empty, the loadbalancer IP is announced to all the BGPPeers configured.
 	// +optional
 	Peers []string `json:"peers,omitempty"`
+
+	// NextHop is the address to be advertised as next hop for the LoadBalancer IPs,
+	// in place of the address of the speaker. It must be of the same family as the
+	// selected IPAddressPools.
+	// +optional
+	NextHop string `json:"nextHop,omitempty"`
 }
 
 // BGPAdvertisementStatus defines the observed state of BGPAdvertisement.
@@ -49,6 +49,23 @@ type IPAddressPoolSpec struct {
 	// multiple IPAddressPools have the same priority, choice will be random.
 	// +optional
 	AllocateTo *ServiceAllocation `json:"serviceAllocation,omitempty"`
+
+	// AllocationPriority is the priority of the pool when automatically
+	// assigning IPs to services not pinned to any pool. Pools with a lower value
+	// are preferred, and the next ones are used only when the preferred ones
+	// are full. A pool with no priority set is used only if the pools with priority
+	// can't be used.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	AllocationPriority int `json:"allocationPriority,omitempty"`
+
+	// AllocationAlgorithm is how the IPs of the pool are picked. LowestFree,
+	// the default, always picks the lowest available IP, keeping the addresses
+	// stable. RoundRobin picks the first available IP following the last
+	// allocated one, spreading the allocations over the pool.
+	// +optional
+	// +kubebuilder:validation:Enum=LowestFree;RoundRobin
+	AllocationAlgorithm string `json:"allocationAlgorithm,omitempty"`
 }
 
 // ServiceAllocation defines ip pool allocation to namespace and/or service.
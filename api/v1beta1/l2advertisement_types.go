@@ -39,6 +39,12 @@ type L2AdvertisementSpec struct {
 	// If the field is not set, we advertise from all the interfaces on the host.
 	// +optional
 	Interfaces []string `json:"interfaces,omitempty"`
+	// NodeSelectionPolicy defines how the node announcing an IP is chosen among the eligible ones.
+	// Hash, the default, spreads the IPs among the nodes by hashing them, while Leader always
+	// announces all the IPs from the same node, the first eligible one sorted by name.
+	// +kubebuilder:validation:Enum:=Hash;Leader
+	// +optional
+	NodeSelectionPolicy string `json:"nodeSelectionPolicy,omitempty"`
 }
 
 // L2AdvertisementStatus defines the observed state of L2Advertisement.
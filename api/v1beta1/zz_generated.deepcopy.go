@@ -199,6 +199,11 @@ func (in *BFDProfileSpec) DeepCopyInto(out *BFDProfileSpec) {
 		*out = new(uint32)
 		**out = **in
 	}
+	if in.EchoReceiveInterval != nil {
+		in, out := &in.EchoReceiveInterval, &out.EchoReceiveInterval
+		*out = new(uint32)
+		**out = **in
+	}
 	if in.EchoMode != nil {
 		in, out := &in.EchoMode, &out.EchoMode
 		*out = new(bool)
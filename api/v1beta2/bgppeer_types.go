@@ -29,17 +29,44 @@ type BGPPeerSpec struct {
 	MyASN uint32 `json:"myASN"`
 
 	// AS number to expect from the remote end of the session.
+	// Mutually exclusive with dynamicASN.
+	// +optional
 	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:validation:Maximum=4294967295
-	ASN uint32 `json:"peerASN"`
+	ASN uint32 `json:"peerASN,omitempty"`
+
+	// DynamicASN detects the AS number to use for the remote end of the session
+	// without explicitly setting it via the ASN field. Limited to:
+	// internal - if the neighbor's ASN is different than MyASN connection is denied.
+	// external - if the neighbor's ASN is the same as MyASN the connection is denied.
+	// Mutually exclusive with peerASN.
+	// +optional
+	// +kubebuilder:validation:Enum=internal;external
+	DynamicASN string `json:"dynamicASN,omitempty"`
 
 	// Address to dial when establishing the session.
-	Address string `json:"peerAddress"`
+	// Mutually exclusive with interface.
+	// +optional
+	Address string `json:"peerAddress,omitempty"`
+
+	// Interface to establish an unnumbered session on, when the peer
+	// is identified by the interface it's reachable from rather than
+	// by its address. Mutually exclusive with peerAddress.
+	// +optional
+	Interface string `json:"interface,omitempty"`
 
 	// Source address to use when establishing the session.
+	// Mutually exclusive with sourceAddresses.
 	// +optional
 	SrcAddress string `json:"sourceAddress,omitempty"`
 
+	// Source addresses to use when establishing the session, at most one
+	// per address family. The one of the same family as peerAddress is used.
+	// Mutually exclusive with sourceAddress.
+	// +optional
+	// +kubebuilder:validation:MaxItems=2
+	SrcAddresses []string `json:"sourceAddresses,omitempty"`
+
 	// Port to dial when establishing the session.
 	// +optional
 	// +kubebuilder:validation:Minimum=0
@@ -55,6 +82,10 @@ type BGPPeerSpec struct {
 	// +optional
 	KeepaliveTime metav1.Duration `json:"keepaliveTime,omitempty"`
 
+	// Requested BGP connect time, controls how long BGP waits between connection attempts to a neighbor.
+	// +optional
+	ConnectTime *metav1.Duration `json:"connectTime,omitempty"`
+
 	// BGP router ID to advertise to the peer
 	// +optional
 	RouterID string `json:"routerID,omitempty"`
@@ -75,6 +106,19 @@ type BGPPeerSpec struct {
 	// +optional
 	PasswordSecret v1.SecretReference `json:"passwordSecret,omitempty"`
 
+	// The mechanism used to authenticate the session with the password. TCP-MD5, the
+	// default, uses TCP MD5 signatures, while the TCP-AO ones use the TCP Authentication
+	// Option, per RFC5925, with the given MAC algorithm. Requires a password.
+	// +optional
+	// +kubebuilder:validation:Enum=TCP-MD5;TCP-AO-HMAC-SHA-1-96;TCP-AO-AES-128-CMAC-96
+	AuthAlgorithm string `json:"authAlgorithm,omitempty"`
+
+	// The id of the TCP-AO key derived from the password. Valid only with a TCP-AO
+	// authAlgorithm, defaults to 0.
+	// +optional
+	// +kubebuilder:validation:Maximum=255
+	AuthKeyID uint32 `json:"authKeyID,omitempty"`
+
 	// The name of the BFD Profile to be used for the BFD session associated to the BGP session. If not set, the BFD session won't be set up.
 	// +optional
 	BFDProfile string `json:"bfdProfile,omitempty"`
@@ -83,13 +127,49 @@ type BGPPeerSpec struct {
 	// +optional
 	EBGPMultiHop bool `json:"ebgpMultiHop,omitempty"`
 
+	// The TTL of the packets sent to a BGPPeer which is multi-hops away. Valid only
+	// when ebgpMultiHop is set. If not set, the default TTL of the BGP implementation
+	// is used.
+	// +optional
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:validation:Maximum=255
+	EBGPMultiHopTTL uint32 `json:"ebgpMultiHopTTL,omitempty"`
+
+	// To set if the session must enforce the generalized TTL security mechanism,
+	// per RFC5082, accepting only packets with TTL 255. Valid only for directly
+	// connected peers, so it can't be set together with ebgpMultiHop.
+	// +optional
+	TTLSecurity bool `json:"ttlSecurity,omitempty"`
+
 	// To set if we want to peer with the BGPPeer using an interface belonging to
 	// a host vrf
 	// +optional
 	VRFName string `json:"vrf,omitempty"`
+
+	// GracefulRestart configures the BGP graceful restart capability, per RFC4724.
+	// If not set, graceful restart is disabled.
+	// +optional
+	GracefulRestart *GracefulRestart `json:"gracefulRestart,omitempty"`
+
+	// To set if the peer is administratively down: the peer is part of the
+	// configuration, but the speakers don't establish the session with it.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
 	// Add future BGP configuration here
 }
 
+// GracefulRestart defines the graceful restart settings of a BGP session.
+type GracefulRestart struct {
+	// To set if the graceful restart capability is advertised to the peer.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// The time the peer should wait for the session to be re-established
+	// after a restart, per RFC4724.
+	// +optional
+	RestartTime metav1.Duration `json:"restartTime,omitempty"`
+}
+
 // BGPPeerStatus defines the observed state of Peer.
 type BGPPeerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
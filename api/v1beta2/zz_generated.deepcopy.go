@@ -74,8 +74,18 @@ func (in *BGPPeerList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BGPPeerSpec) DeepCopyInto(out *BGPPeerSpec) {
 	*out = *in
+	if in.SrcAddresses != nil {
+		in, out := &in.SrcAddresses, &out.SrcAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	out.HoldTime = in.HoldTime
 	out.KeepaliveTime = in.KeepaliveTime
+	if in.ConnectTime != nil {
+		in, out := &in.ConnectTime, &out.ConnectTime
+		*out = new(v1.Duration)
+		**out = **in
+	}
 	if in.NodeSelectors != nil {
 		in, out := &in.NodeSelectors, &out.NodeSelectors
 		*out = make([]v1.LabelSelector, len(*in))
@@ -84,6 +94,11 @@ func (in *BGPPeerSpec) DeepCopyInto(out *BGPPeerSpec) {
 		}
 	}
 	out.PasswordSecret = in.PasswordSecret
+	if in.GracefulRestart != nil {
+		in, out := &in.GracefulRestart, &out.GracefulRestart
+		*out = new(GracefulRestart)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPPeerSpec.
@@ -110,3 +125,19 @@ func (in *BGPPeerStatus) DeepCopy() *BGPPeerStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GracefulRestart) DeepCopyInto(out *GracefulRestart) {
+	*out = *in
+	out.RestartTime = in.RestartTime
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GracefulRestart.
+func (in *GracefulRestart) DeepCopy() *GracefulRestart {
+	if in == nil {
+		return nil
+	}
+	out := new(GracefulRestart)
+	in.DeepCopyInto(out)
+	return out
+}
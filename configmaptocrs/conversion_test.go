@@ -4,16 +4,27 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/conversion"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 )
 
 var update = flag.Bool("update", false, "update .golden files")
@@ -25,6 +36,23 @@ const (
 	configMapDataTestDir = "./testdata/configmap-data"
 )
 
+// validPeer returns a peer passing all the validations, for the tests to
+// change only the fields they are about.
+func validPeer() peer {
+	return peer{MyASN: 42, ASN: 142, Addr: "1.2.3.4"}
+}
+
+// assertValidationError fails the test unless err is a ConversionError of
+// kind ValidationError, which is returned for further checks.
+func assertValidationError(t *testing.T, err error) *config.ConversionError {
+	t.Helper()
+	var convErr *config.ConversionError
+	if !errors.As(err, &convErr) || convErr.Kind != config.ValidationError {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	return convErr
+}
+
 func TestGenerateResourcesWithConfigMapDefinition(t *testing.T) {
 	testGenerate(t, configMapYAMLTestDir, testConfigMapSource)
 }
@@ -78,3 +106,3431 @@ func testGenerate(t *testing.T, testDir string, testOnlyData bool) {
 		})
 	}
 }
+
+func TestGenerateKustomize(t *testing.T) {
+	log.SetOutput(io.Discard)
+	oldInputDirPath, oldOnlyData := inputDirPath, *onlyData
+	defer func() { inputDirPath, *onlyData = oldInputDirPath, oldOnlyData }()
+	inputDirPath, *onlyData = configMapDataTestDir, true
+
+	dir := t.TempDir()
+	if err := generateKustomize(dir, "config-data-full.yaml"); err != nil {
+		t.Fatalf("failed to generate the kustomize base: %s", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read the output directory: %s", err)
+	}
+	files := []string{}
+	for _, e := range entries {
+		files = append(files, e.Name())
+	}
+	expectedFiles := []string{
+		"bfdprofiles.yaml",
+		"bgpadvertisements.yaml",
+		"bgppeers.yaml",
+		"communities.yaml",
+		"ipaddresspools.yaml",
+		"kustomization.yaml",
+	}
+	if !cmp.Equal(expectedFiles, files) {
+		t.Fatalf("unexpected files (-want +got):\n%s", cmp.Diff(expectedFiles, files))
+	}
+
+	kustomization, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read the kustomization: %s", err)
+	}
+	expectedKustomization := autoGenComment + `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- bgppeers.yaml
+- ipaddresspools.yaml
+- bgpadvertisements.yaml
+- bfdprofiles.yaml
+- communities.yaml
+`
+	if !cmp.Equal(expectedKustomization, string(kustomization)) {
+		t.Fatalf("unexpected kustomization (-want +got):\n%s", cmp.Diff(expectedKustomization, string(kustomization)))
+	}
+
+	// The files listed by the kustomization, in order, hold the same
+	// resources as the single file output.
+	var got strings.Builder
+	for _, f := range []string{"bgppeers.yaml", "ipaddresspools.yaml", "bgpadvertisements.yaml", "bfdprofiles.yaml", "communities.yaml"} {
+		content, err := os.ReadFile(filepath.Join(dir, f))
+		if err != nil {
+			t.Fatalf("failed to read %s: %s", f, err)
+		}
+		if !strings.HasPrefix(string(content), autoGenComment) {
+			t.Fatalf("expected %s to start with the autogenerated comment", f)
+		}
+		got.WriteString(strings.TrimPrefix(string(content), autoGenComment))
+	}
+	single, err := os.ReadFile(filepath.Join(configMapDataTestDir, "config-data-full.golden"))
+	if err != nil {
+		t.Fatalf("failed to read the golden file: %s", err)
+	}
+	expected := strings.TrimPrefix(string(single), autoGenComment)
+	if !cmp.Equal(expected, got.String()) {
+		t.Fatalf("unexpected resources (-want +got):\n%s", cmp.Diff(expected, got.String()))
+	}
+}
+
+func TestConversionErrors(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	tests := []struct {
+		desc         string
+		convert      func() error
+		expectedKind config.ConversionErrorKind
+		expectedName string
+	}{
+		{
+			desc: "malformed hold time",
+			convert: func() error {
+				_, err := parseHoldTime(peer{Addr: "1.2.3.4", HoldTime: "foo"}, defaultOptions())
+				return err
+			},
+			expectedKind: config.ParseError,
+			expectedName: "1.2.3.4",
+		},
+		{
+			desc: "hold time too short",
+			convert: func() error {
+				_, err := parseHoldTime(peer{Interface: "eth0", HoldTime: "1s"}, defaultOptions())
+				return err
+			},
+			expectedKind: config.ValidationError,
+			expectedName: "eth0",
+		},
+		{
+			desc: "peer with malformed keepalive time",
+			convert: func() error {
+				in := validPeer()
+				in.KeepaliveTime = "foo"
+				_, err := parsePeer(&configFile{}, in, defaultOptions())
+				return err
+			},
+			expectedKind: config.ParseError,
+			expectedName: "1.2.3.4",
+		},
+		{
+			desc: "name not convertible to k8s",
+			convert: func() error {
+				_, err := formatToK8S("1234", "IpAddressPool")
+				return err
+			},
+			expectedKind: config.ValidationError,
+			expectedName: "1234",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.convert()
+			var convErr *config.ConversionError
+			if !errors.As(err, &convErr) {
+				t.Fatalf("expected a conversion error, got %v", err)
+			}
+			if convErr.Kind != test.expectedKind {
+				t.Fatalf("expected kind %s, got %s", test.expectedKind, convErr.Kind)
+			}
+			if convErr.Name != test.expectedName {
+				t.Fatalf("expected name %s, got %s", test.expectedName, convErr.Name)
+			}
+			if convErr.Error() != convErr.Reason {
+				t.Fatalf("expected error message %q, got %q", convErr.Reason, convErr.Error())
+			}
+		})
+	}
+}
+
+func TestPeerBGPRole(t *testing.T) {
+	tests := []struct {
+		role        string
+		expected    string
+		expectedErr bool
+	}{
+		{role: "", expected: ""},
+		{role: "provider", expected: "provider"},
+		{role: "rs-server", expected: "rs-server"},
+		{role: "rs-client", expected: "rs-client"},
+		{role: "customer", expected: "customer"},
+		{role: "peer", expected: "peer"},
+		{role: "Customer", expected: "customer"},
+		{role: "transit", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.role, func(t *testing.T) {
+			in := validPeer()
+			in.BGPRole = test.role
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			role, ok := p.Annotations[bgpRoleAnnotation]
+			if test.expected == "" && ok {
+				t.Fatalf("expected no bgp role annotation, got %q", role)
+			}
+			if role != test.expected {
+				t.Fatalf("expected bgp role %q, got %q", test.expected, role)
+			}
+		})
+	}
+}
+
+func TestPoolQoSHints(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		desc                string
+		pool                addressPool
+		expectedAnnotations map[string]string
+		expectedErr         bool
+	}{
+		{
+			desc:                "no hints",
+			pool:                addressPool{Name: "pool", Addresses: []string{"192.168.1.0/24"}},
+			expectedAnnotations: nil,
+		},
+		{
+			desc:                "valid dscp",
+			pool:                addressPool{Name: "pool", Addresses: []string{"192.168.1.0/24"}, DSCP: intPtr(46)},
+			expectedAnnotations: map[string]string{dscpAnnotation: "46"},
+		},
+		{
+			desc:                "valid tos",
+			pool:                addressPool{Name: "pool", Addresses: []string{"192.168.1.0/24"}, ToS: intPtr(184)},
+			expectedAnnotations: map[string]string{tosAnnotation: "184"},
+		},
+		{
+			desc:        "dscp out of range",
+			pool:        addressPool{Name: "pool", Addresses: []string{"192.168.1.0/24"}, DSCP: intPtr(64)},
+			expectedErr: true,
+		},
+		{
+			desc:        "negative tos",
+			pool:        addressPool{Name: "pool", Addresses: []string{"192.168.1.0/24"}, ToS: intPtr(-1)},
+			expectedErr: true,
+		},
+		{
+			desc:        "both dscp and tos",
+			pool:        addressPool{Name: "pool", Addresses: []string{"192.168.1.0/24"}, DSCP: intPtr(46), ToS: intPtr(184)},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pools, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{test.pool}}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(test.expectedAnnotations, pools[0].Annotations) {
+				t.Fatalf("unexpected annotations (-want +got)\n%s", cmp.Diff(test.expectedAnnotations, pools[0].Annotations))
+			}
+		})
+	}
+}
+
+func TestPeerTCPMSS(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		desc        string
+		mss         *int
+		expected    string
+		expectedErr bool
+	}{
+		{desc: "unset", mss: nil, expected: ""},
+		{desc: "set", mss: intPtr(1400), expected: "1400"},
+		{desc: "lowest", mss: intPtr(536), expected: "536"},
+		{desc: "too low", mss: intPtr(100), expectedErr: true},
+		{desc: "too high", mss: intPtr(65496), expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.TCPMSS = test.mss
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			mss, ok := p.Annotations[tcpMSSAnnotation]
+			if test.expected == "" && ok {
+				t.Fatalf("expected no tcp mss annotation, got %q", mss)
+			}
+			if mss != test.expected {
+				t.Fatalf("expected tcp mss %q, got %q", test.expected, mss)
+			}
+		})
+	}
+}
+
+func TestPeerGracefulRestart(t *testing.T) {
+	tests := []struct {
+		desc        string
+		gr          *gracefulRestart
+		expected    *v1beta2.GracefulRestart
+		expectedErr bool
+	}{
+		{desc: "unset", gr: nil, expected: nil},
+		{
+			desc: "enabled",
+			gr:   &gracefulRestart{Enabled: true, RestartTime: "2m"},
+			expected: &v1beta2.GracefulRestart{
+				Enabled:     true,
+				RestartTime: metav1.Duration{Duration: 2 * time.Minute},
+			},
+		},
+		{
+			desc:     "enabled without restart time",
+			gr:       &gracefulRestart{Enabled: true},
+			expected: &v1beta2.GracefulRestart{Enabled: true},
+		},
+		{
+			desc: "rounded restart time",
+			gr:   &gracefulRestart{Enabled: true, RestartTime: "90500ms"},
+			expected: &v1beta2.GracefulRestart{
+				Enabled:     true,
+				RestartTime: metav1.Duration{Duration: 90 * time.Second},
+			},
+		},
+		{desc: "invalid restart time", gr: &gracefulRestart{Enabled: true, RestartTime: "foo"}, expectedErr: true},
+		{desc: "restart time too short", gr: &gracefulRestart{Enabled: true, RestartTime: "500ms"}, expectedErr: true},
+		{desc: "restart time too long", gr: &gracefulRestart{Enabled: true, RestartTime: "4096s"}, expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.GracefulRestart = test.gr
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				var convErr *config.ConversionError
+				if !errors.As(err, &convErr) {
+					t.Fatalf("expected a conversion error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(test.expected, p.Spec.GracefulRestart) {
+				t.Fatalf("unexpected graceful restart (-want +got)\n%s", cmp.Diff(test.expected, p.Spec.GracefulRestart))
+			}
+		})
+	}
+}
+
+func TestPeerVRF(t *testing.T) {
+	tests := []struct {
+		desc        string
+		vrf         string
+		expectedErr bool
+	}{
+		{desc: "no vrf", vrf: ""},
+		{desc: "vrf", vrf: "red"},
+		{desc: "vrf with dashes and dots", vrf: "vrf-red.100"},
+		{desc: "too long", vrf: "averyveryverylongvrf", expectedErr: true},
+		{desc: "with slash", vrf: "red/blue", expectedErr: true},
+		{desc: "with space", vrf: "red blue", expectedErr: true},
+		{desc: "dot", vrf: ".", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.VRFName = test.vrf
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.VRFName != test.vrf {
+				t.Fatalf("expected vrf %q, got %q", test.vrf, p.Spec.VRFName)
+			}
+		})
+	}
+}
+
+func TestPeerNoDefaultVRF(t *testing.T) {
+	tests := []struct {
+		desc         string
+		vrf          string
+		noDefaultVRF bool
+		expectedErr  bool
+	}{
+		{desc: "vrf with default fallback", vrf: "red"},
+		{desc: "vrf without default fallback", vrf: "red", noDefaultVRF: true},
+		{desc: "no vrf", noDefaultVRF: true, expectedErr: true},
+		{desc: "default vrf", vrf: "default", noDefaultVRF: true, expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.VRFName = test.vrf
+			in.NoDefaultVRF = test.noDefaultVRF
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				if convErr := assertValidationError(t, err); convErr.Name != "1.2.3.4" {
+					t.Fatalf("expected the error for peer 1.2.3.4, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.VRFName != test.vrf {
+				t.Fatalf("expected vrf %q, got %q", test.vrf, p.Spec.VRFName)
+			}
+			_, annotated := p.Annotations[disableDefaultVRFAnnotation]
+			if annotated != test.noDefaultVRF {
+				t.Fatalf("expected the %s annotation to be set: %v, got annotations %v", disableDefaultVRFAnnotation, test.noDefaultVRF, p.Annotations)
+			}
+		})
+	}
+}
+
+func TestPeerPort(t *testing.T) {
+	tests := []struct {
+		desc        string
+		port        int
+		expected    uint16
+		expectedErr bool
+	}{
+		{desc: "default", port: 0, expected: 0},
+		{desc: "valid", port: 1179, expected: 1179},
+		{desc: "highest", port: 65535, expected: 65535},
+		{desc: "too high", port: 179000, expectedErr: true},
+		{desc: "negative", port: -1, expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.Port = test.port
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				if !strings.Contains(err.Error(), "1.2.3.4") {
+					t.Fatalf("expected the error to name the peer, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.Port != test.expected {
+				t.Fatalf("expected port %d, got %d", test.expected, p.Spec.Port)
+			}
+		})
+	}
+}
+
+func TestPeerTTLSecurity(t *testing.T) {
+	tests := []struct {
+		desc         string
+		ttlSecurity  bool
+		ebgpMultiHop bool
+		expectedErr  bool
+	}{
+		{desc: "default"},
+		{desc: "single hop", ttlSecurity: true},
+		{desc: "multihop", ebgpMultiHop: true},
+		{desc: "multihop with ttl security", ttlSecurity: true, ebgpMultiHop: true, expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.TTLSecurity = test.ttlSecurity
+			in.EBGPMultiHop = test.ebgpMultiHop
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.TTLSecurity != test.ttlSecurity {
+				t.Fatalf("expected ttl security %v, got %v", test.ttlSecurity, p.Spec.TTLSecurity)
+			}
+		})
+	}
+}
+
+func TestPeerDisabled(t *testing.T) {
+	tests := []struct {
+		desc        string
+		peer        peer
+		expectedErr bool
+	}{
+		{
+			desc: "enabled",
+			peer: peer{MyASN: 42, ASN: 142, Addr: "1.2.3.4"},
+		},
+		{
+			desc: "disabled",
+			peer: peer{MyASN: 42, ASN: 142, Addr: "1.2.3.4", Disabled: true},
+		},
+		{
+			desc:        "disabled with invalid port",
+			peer:        peer{MyASN: 42, ASN: 142, Addr: "1.2.3.4", Port: 70000, Disabled: true},
+			expectedErr: true,
+		},
+		{
+			desc:        "disabled with invalid hold time",
+			peer:        peer{MyASN: 42, ASN: 142, Addr: "1.2.3.4", HoldTime: "1s", Disabled: true},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p, err := parsePeer(&configFile{}, test.peer, defaultOptions())
+			if test.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.Disabled != test.peer.Disabled {
+				t.Fatalf("expected disabled %v, got %v", test.peer.Disabled, p.Spec.Disabled)
+			}
+		})
+	}
+}
+
+func TestPeerSourceAddress(t *testing.T) {
+	tests := []struct {
+		desc         string
+		addr         string
+		srcAddr      string
+		expectedKind config.ConversionErrorKind
+	}{
+		{desc: "unset", addr: "1.2.3.4"},
+		{desc: "ipv4", addr: "1.2.3.4", srcAddr: "10.0.0.1"},
+		{desc: "ipv6", addr: "2001:db8::1", srcAddr: "2001:db8::2"},
+		{desc: "malformed", addr: "1.2.3.4", srcAddr: "10.0.0", expectedKind: config.ParseError},
+		{desc: "ipv6 source for ipv4 peer", addr: "1.2.3.4", srcAddr: "2001:db8::2", expectedKind: config.ValidationError},
+		{desc: "ipv4 source for ipv6 peer", addr: "2001:db8::1", srcAddr: "10.0.0.1", expectedKind: config.ValidationError},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p, err := parsePeer(&configFile{}, peer{MyASN: 42, ASN: 142, Addr: test.addr, SrcAddr: test.srcAddr}, defaultOptions())
+			if test.expectedKind != "" {
+				var convErr *config.ConversionError
+				if !errors.As(err, &convErr) || convErr.Kind != test.expectedKind || convErr.Name != test.addr {
+					t.Fatalf("expected a %s error for peer %s, got %v", test.expectedKind, test.addr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.SrcAddress != test.srcAddr {
+				t.Fatalf("expected source address %q, got %q", test.srcAddr, p.Spec.SrcAddress)
+			}
+		})
+	}
+}
+
+func TestPeerSourceAddresses(t *testing.T) {
+	tests := []struct {
+		desc         string
+		srcAddr      string
+		srcAddrs     []string
+		expectedKind config.ConversionErrorKind
+	}{
+		{desc: "single source", srcAddr: "10.0.0.1"},
+		{desc: "dual source", srcAddrs: []string{"10.0.0.1", "2001:db8::2"}},
+		{desc: "two ipv4 sources", srcAddrs: []string{"10.0.0.1", "10.0.0.2"}, expectedKind: config.ValidationError},
+		{desc: "two ipv6 sources", srcAddrs: []string{"2001:db8::2", "2001:db8::3"}, expectedKind: config.ValidationError},
+		{desc: "malformed source", srcAddrs: []string{"10.0.0.1", "2001:db8:::2"}, expectedKind: config.ParseError},
+		{desc: "both source and sources", srcAddr: "10.0.0.1", srcAddrs: []string{"2001:db8::2"}, expectedKind: config.ValidationError},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.SrcAddr = test.srcAddr
+			in.SrcAddrs = test.srcAddrs
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedKind != "" {
+				var convErr *config.ConversionError
+				if !errors.As(err, &convErr) || convErr.Kind != test.expectedKind || convErr.Name != "1.2.3.4" {
+					t.Fatalf("expected a %s error for peer 1.2.3.4, got %v", test.expectedKind, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.SrcAddress != test.srcAddr || !cmp.Equal(p.Spec.SrcAddresses, test.srcAddrs) {
+				t.Fatalf("expected source address %q and source addresses %v, got %q and %v",
+					test.srcAddr, test.srcAddrs, p.Spec.SrcAddress, p.Spec.SrcAddresses)
+			}
+		})
+	}
+}
+
+func TestPeerDefaultHoldTime(t *testing.T) {
+	tests := []struct {
+		desc            string
+		defaultHoldTime time.Duration
+		holdTime        string
+		expected        time.Duration
+		expectedErr     bool
+	}{
+		{desc: "standard default", defaultHoldTime: 90 * time.Second, expected: 90 * time.Second},
+		{desc: "custom default", defaultHoldTime: 30 * time.Second, expected: 30 * time.Second},
+		{desc: "zero default", defaultHoldTime: 0, expected: 0},
+		{desc: "explicit hold time", defaultHoldTime: 30 * time.Second, holdTime: "10s", expected: 10 * time.Second},
+		{desc: "default too short", defaultHoldTime: time.Second, expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.defaultHoldTime = test.defaultHoldTime
+			in := validPeer()
+			in.HoldTime = test.holdTime
+			p, err := parsePeer(&configFile{}, in, opts)
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.HoldTime.Duration != test.expected {
+				t.Fatalf("expected hold time %s, got %s", test.expected, p.Spec.HoldTime.Duration)
+			}
+		})
+	}
+}
+
+func TestPeerRouterID(t *testing.T) {
+	tests := []struct {
+		desc         string
+		routerID     string
+		expectedKind config.ConversionErrorKind
+	}{
+		{desc: "unset"},
+		{desc: "valid", routerID: "10.0.0.1"},
+		{desc: "malformed", routerID: "10.0.0", expectedKind: config.ParseError},
+		{desc: "not an address", routerID: "router1", expectedKind: config.ParseError},
+		{desc: "ipv6", routerID: "2001:db8::1", expectedKind: config.ValidationError},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.RouterID = test.routerID
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedKind != "" {
+				var convErr *config.ConversionError
+				if !errors.As(err, &convErr) || convErr.Kind != test.expectedKind || convErr.Name != "1.2.3.4" {
+					t.Fatalf("expected a %s error for peer 1.2.3.4, got %v", test.expectedKind, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.RouterID != test.routerID {
+				t.Fatalf("expected router id %q, got %q", test.routerID, p.Spec.RouterID)
+			}
+		})
+	}
+}
+
+func TestPeerEBGPMultiHopTTL(t *testing.T) {
+	tests := []struct {
+		desc         string
+		ebgpMultiHop bool
+		ttl          int
+		expectedErr  bool
+	}{
+		{desc: "unset"},
+		{desc: "multihop without ttl", ebgpMultiHop: true},
+		{desc: "multihop with ttl", ebgpMultiHop: true, ttl: 5},
+		{desc: "ttl without multihop", ttl: 5, expectedErr: true},
+		{desc: "ttl too low", ebgpMultiHop: true, ttl: 1, expectedErr: true},
+		{desc: "ttl too high", ebgpMultiHop: true, ttl: 256, expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.EBGPMultiHop = test.ebgpMultiHop
+			in.EBGPMultiHopTTL = test.ttl
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.EBGPMultiHopTTL != uint32(test.ttl) {
+				t.Fatalf("expected ebgp multihop ttl %d, got %d", test.ttl, p.Spec.EBGPMultiHopTTL)
+			}
+		})
+	}
+}
+
+func TestPeerAuthAlgorithm(t *testing.T) {
+	tests := []struct {
+		desc        string
+		password    string
+		algorithm   string
+		keyID       int
+		expectedErr bool
+	}{
+		{desc: "default", password: "secret"},
+		{desc: "tcp-md5", password: "secret", algorithm: "TCP-MD5"},
+		{desc: "tcp-ao with key id", password: "secret", algorithm: "TCP-AO-AES-128-CMAC-96", keyID: 3},
+		{desc: "unknown algorithm", password: "secret", algorithm: "TCP-AO-HMAC-SHA-256", expectedErr: true},
+		{desc: "algorithm without password", algorithm: "TCP-AO-HMAC-SHA-1-96", expectedErr: true},
+		{desc: "key id with tcp-md5", password: "secret", algorithm: "TCP-MD5", keyID: 3, expectedErr: true},
+		{desc: "key id too high", password: "secret", algorithm: "TCP-AO-HMAC-SHA-1-96", keyID: 256, expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.Password = test.password
+			in.AuthAlgorithm = test.algorithm
+			in.AuthKeyID = test.keyID
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.AuthAlgorithm != test.algorithm || p.Spec.AuthKeyID != uint32(test.keyID) {
+				t.Fatalf("expected auth algorithm %q with key id %d, got %q with %d", test.algorithm, test.keyID, p.Spec.AuthAlgorithm, p.Spec.AuthKeyID)
+			}
+		})
+	}
+}
+
+func TestPeerDynamicASN(t *testing.T) {
+	tests := []struct {
+		desc        string
+		asn         uint32
+		dynamicASN  string
+		expectedErr bool
+	}{
+		{desc: "fixed asn", asn: 142},
+		{desc: "dynamic external", dynamicASN: "external"},
+		{desc: "dynamic internal", dynamicASN: "internal"},
+		{desc: "both asn and dynamic asn", asn: 142, dynamicASN: "external", expectedErr: true},
+		{desc: "unknown dynamic asn", dynamicASN: "any", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p, err := parsePeer(&configFile{}, peer{MyASN: 42, ASN: test.asn, DynamicASN: test.dynamicASN, Addr: "1.2.3.4"}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if p.Spec.ASN != test.asn || p.Spec.DynamicASN != test.dynamicASN {
+				t.Fatalf("expected asn %d with dynamic asn %q, got %d with %q", test.asn, test.dynamicASN, p.Spec.ASN, p.Spec.DynamicASN)
+			}
+		})
+	}
+}
+
+func TestBFDEchoReceiveInterval(t *testing.T) {
+	interval := func(i uint32) *uint32 { return &i }
+	tests := []struct {
+		desc        string
+		interval    *uint32
+		expectedErr bool
+	}{
+		{desc: "not set"},
+		{desc: "valid", interval: interval(100)},
+		{desc: "lower bound", interval: interval(10)},
+		{desc: "upper bound", interval: interval(60000)},
+		{desc: "too low", interval: interval(5), expectedErr: true},
+		{desc: "too high", interval: interval(60001), expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &configFile{BFDProfiles: []bfdProfile{{Name: "bfd1", EchoReceiveInterval: test.interval}}}
+			profiles, err := bfdProfileFor(c, resourcesNameSpace)
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(profiles[0].Spec.EchoReceiveInterval, test.interval) {
+				t.Fatalf("expected echo receive interval %v, got %v", test.interval, profiles[0].Spec.EchoReceiveInterval)
+			}
+		})
+	}
+}
+
+func TestBFDDuplicateProfiles(t *testing.T) {
+	c := &configFile{
+		BFDProfiles: []bfdProfile{{Name: "default"}, {Name: "fast"}, {Name: "default"}},
+	}
+	_, err := bfdProfileFor(c, resourcesNameSpace)
+	if convErr := assertValidationError(t, err); convErr.Name != "default" {
+		t.Fatalf("expected the error for bfd profile default, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "duplicate bfd profile name default") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestStrictDurations(t *testing.T) {
+	tests := []struct {
+		desc        string
+		parse       func(peer, options) (time.Duration, error)
+		peer        peer
+		strict      bool
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{
+			desc:     "keepalive time truncated",
+			parse:    parseKeepaliveTime,
+			peer:     peer{KeepaliveTime: "1500ms"},
+			expected: time.Second,
+		},
+		{
+			desc:        "keepalive time strict",
+			parse:       parseKeepaliveTime,
+			peer:        peer{KeepaliveTime: "1500ms"},
+			strict:      true,
+			expectedErr: true,
+		},
+		{
+			desc:     "keepalive time strict whole seconds",
+			parse:    parseKeepaliveTime,
+			peer:     peer{KeepaliveTime: "2000ms"},
+			strict:   true,
+			expected: 2 * time.Second,
+		},
+		{
+			desc:     "hold time truncated",
+			parse:    parseHoldTime,
+			peer:     peer{HoldTime: "3500ms"},
+			expected: 3 * time.Second,
+		},
+		{
+			desc:        "hold time strict",
+			parse:       parseHoldTime,
+			peer:        peer{HoldTime: "1500ms"},
+			strict:      true,
+			expectedErr: true,
+		},
+		{
+			desc:        "connect time strict",
+			parse:       parseConnectTime,
+			peer:        peer{ConnectTime: "1500ms"},
+			strict:      true,
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.strictDurations = test.strict
+			d, err := test.parse(test.peer, opts)
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if d != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, d)
+			}
+		})
+	}
+}
+
+func TestDefaultBFDProfile(t *testing.T) {
+	tests := []struct {
+		desc        string
+		defaultBFD  string
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			desc:     "no default",
+			expected: []string{"", "fast"},
+		},
+		{
+			desc:       "default inherited and explicit override",
+			defaultBFD: "slow",
+			expected:   []string{"slow", "fast"},
+		},
+		{
+			desc:        "default not found",
+			defaultBFD:  "missing",
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.defaultBFDProfile = test.defaultBFD
+			c := &configFile{
+				Peers: []peer{
+					{MyASN: 42, ASN: 142, Addr: "1.2.3.4"},
+					{MyASN: 42, ASN: 142, Addr: "1.2.3.5", BFDProfile: "fast"},
+				},
+				BFDProfiles: []bfdProfile{{Name: "slow"}, {Name: "fast"}},
+			}
+			peers, _, err := peersFor(c, opts)
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			profiles := []string{}
+			for _, p := range peers {
+				profiles = append(profiles, p.Spec.BFDProfile)
+			}
+			if !cmp.Equal(test.expected, profiles) {
+				t.Fatalf("unexpected bfd profiles (-want +got)\n%s", cmp.Diff(test.expected, profiles))
+			}
+		})
+	}
+}
+
+func TestPeerSourceSubnet(t *testing.T) {
+	tests := []struct {
+		desc        string
+		subnets     string
+		peer        peer
+		expectedErr config.ConversionErrorKind
+	}{
+		{
+			desc: "no local subnets",
+			peer: peer{MyASN: 42, ASN: 142, Addr: "10.0.0.1", SrcAddr: "10.1.0.2"},
+		},
+		{
+			desc:    "same subnet",
+			subnets: "10.0.0.0/24, fc00::/64",
+			peer:    peer{MyASN: 42, ASN: 142, Addr: "10.0.0.1", SrcAddr: "10.0.0.2"},
+		},
+		{
+			desc:        "cross subnet",
+			subnets:     "10.0.0.0/24,10.1.0.0/24",
+			peer:        peer{MyASN: 42, ASN: 142, Addr: "10.0.0.1", SrcAddr: "10.1.0.2"},
+			expectedErr: config.ValidationError,
+		},
+		{
+			desc:        "cross subnet among the source addresses",
+			subnets:     "fc00::/64",
+			peer:        peer{MyASN: 42, ASN: 142, Addr: "fc00::1", SrcAddrs: []string{"10.0.0.2", "fc00:1::2"}},
+			expectedErr: config.ValidationError,
+		},
+		{
+			desc:    "cross subnet multihop peer",
+			subnets: "10.0.0.0/24",
+			peer:    peer{MyASN: 42, ASN: 142, Addr: "10.0.0.1", SrcAddr: "10.1.0.2", EBGPMultiHop: true},
+		},
+		{
+			desc:    "peer outside of the local subnets",
+			subnets: "10.0.0.0/24",
+			peer:    peer{MyASN: 42, ASN: 142, Addr: "10.2.0.1", SrcAddr: "10.1.0.2"},
+		},
+		{
+			desc:        "invalid local subnet",
+			subnets:     "10.0.0.0/33",
+			peer:        peer{MyASN: 42, ASN: 142, Addr: "10.0.0.1", SrcAddr: "10.0.0.2"},
+			expectedErr: config.ParseError,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := defaultOptions()
+			subnets, err := parseLocalSubnets(test.subnets)
+			if err == nil {
+				opts.localSubnets = subnets
+				c := &configFile{Peers: []peer{test.peer}}
+				_, _, err = peersFor(c, opts)
+			}
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			var convErr *config.ConversionError
+			if !errors.As(err, &convErr) || convErr.Kind != test.expectedErr {
+				t.Fatalf("expected a %s, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPoolsWithoutAdvertisements(t *testing.T) {
+	tests := []struct {
+		desc     string
+		pools    []addressPool
+		expected []string
+	}{
+		{
+			desc: "pools with advertisements",
+			pools: []addressPool{
+				{Name: "bgp", Protocol: BGP, Addresses: []string{"10.20.0.0/16"}},
+				{Name: "l2", Protocol: Layer2, Addresses: []string{"10.30.0.0/16"}},
+			},
+			expected: []string{},
+		},
+		{
+			desc: "pool skipping the default advertisement",
+			pools: []addressPool{
+				{Name: "bgp", Protocol: BGP, Addresses: []string{"10.20.0.0/16"}},
+				{Name: "skipped", Protocol: BGP, Addresses: []string{"10.30.0.0/16"}, SkipDefaultAdv: true},
+			},
+			expected: []string{"skipped"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r, err := resourcesFor(&configFile{Pools: test.pools}, defaultOptions())
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := poolsWithoutAdvertisements(r); !cmp.Equal(test.expected, got) {
+				t.Fatalf("unexpected pools without advertisements (-want +got)\n%s", cmp.Diff(test.expected, got))
+			}
+		})
+	}
+}
+
+func TestPoolExternalBlock(t *testing.T) {
+	blocks := `
+ipam-v4: ["192.168.10.0/24", "192.168.11.1"]
+empty: []
+`
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		pool         addressPool
+		expected     []string
+		expectedKind config.ConversionErrorKind
+	}{
+		{
+			desc:        "resolved block",
+			annotations: map[string]string{externalBlocksAnnotation: blocks},
+			pool:        addressPool{Name: "pool1", Protocol: Layer2, ExternalBlock: "ipam-v4"},
+			expected:    []string{"192.168.10.0/24", "192.168.11.1/32"},
+		},
+		{
+			desc:         "unknown block",
+			annotations:  map[string]string{externalBlocksAnnotation: blocks},
+			pool:         addressPool{Name: "pool1", Protocol: Layer2, ExternalBlock: "ipam-v6"},
+			expectedKind: config.ValidationError,
+		},
+		{
+			desc:         "empty block",
+			annotations:  map[string]string{externalBlocksAnnotation: blocks},
+			pool:         addressPool{Name: "pool1", Protocol: Layer2, ExternalBlock: "empty"},
+			expectedKind: config.ValidationError,
+		},
+		{
+			desc:         "missing annotation",
+			pool:         addressPool{Name: "pool1", Protocol: Layer2, ExternalBlock: "ipam-v4"},
+			expectedKind: config.ValidationError,
+		},
+		{
+			desc:         "invalid annotation",
+			annotations:  map[string]string{externalBlocksAnnotation: "ipam-v4: 192.168.10.0/24"},
+			pool:         addressPool{Name: "pool1", Protocol: Layer2, ExternalBlock: "ipam-v4"},
+			expectedKind: config.ParseError,
+		},
+		{
+			desc:         "both addresses and external block",
+			annotations:  map[string]string{externalBlocksAnnotation: blocks},
+			pool:         addressPool{Name: "pool1", Protocol: Layer2, Addresses: []string{"10.0.0.0/24"}, ExternalBlock: "ipam-v4"},
+			expectedKind: config.ValidationError,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &configFile{Pools: []addressPool{test.pool}, annotations: test.annotations}
+			r, err := resourcesFor(c, defaultOptions())
+			if test.expectedKind != "" {
+				var convErr *config.ConversionError
+				if !errors.As(err, &convErr) || convErr.Kind != test.expectedKind {
+					t.Fatalf("expected a %s error, got %v", test.expectedKind, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(test.expected, r.Pools[0].Spec.Addresses) {
+				t.Fatalf("unexpected addresses (-want +got)\n%s", cmp.Diff(test.expected, r.Pools[0].Spec.Addresses))
+			}
+		})
+	}
+}
+
+func TestUnreachablePools(t *testing.T) {
+	autoAssign := false
+	tests := []struct {
+		desc     string
+		pools    []addressPool
+		expected []string
+	}{
+		{
+			desc: "non auto-assign pool with advertisements",
+			pools: []addressPool{
+				{Name: "manual", Protocol: BGP, Addresses: []string{"10.20.0.0/16"}, AutoAssign: &autoAssign},
+			},
+			expected: []string{},
+		},
+		{
+			desc: "auto-assign pool without advertisements",
+			pools: []addressPool{
+				{Name: "skipped", Protocol: BGP, Addresses: []string{"10.30.0.0/16"}, SkipDefaultAdv: true},
+			},
+			expected: []string{},
+		},
+		{
+			desc: "non auto-assign pool without advertisements",
+			pools: []addressPool{
+				{Name: "manual", Protocol: BGP, Addresses: []string{"10.20.0.0/16"}, AutoAssign: &autoAssign},
+				{Name: "unreachable", Protocol: BGP, Addresses: []string{"10.30.0.0/16"}, AutoAssign: &autoAssign, SkipDefaultAdv: true},
+			},
+			expected: []string{"unreachable"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r, err := resourcesFor(&configFile{Pools: test.pools}, defaultOptions())
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := unreachablePools(r); !cmp.Equal(test.expected, got) {
+				t.Fatalf("unexpected unreachable pools (-want +got)\n%s", cmp.Diff(test.expected, got))
+			}
+		})
+	}
+}
+
+func TestResourcesWarnings(t *testing.T) {
+	c := &configFile{
+		Peers: []peer{
+			{MyASN: 64512, ASN: 64513, Addr: "10.0.0.1", HoldTime: "90s"},
+			{MyASN: 64512, ASN: 64513, Addr: "10.0.0.2", HoldTime: "10500ms"},
+		},
+		Pools: []addressPool{
+			{Name: "bgp-pool", Protocol: BGP, Addresses: []string{"192.168.10.0/24"}},
+		},
+	}
+	r, warnings, err := resourcesWithWarningsFor(c, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if r.Peers[1].Spec.HoldTime.Duration != 10*time.Second {
+		t.Fatalf("expected hold time 10s, got %s", r.Peers[1].Spec.HoldTime.Duration)
+	}
+	expected := []Warning{
+		{Element: "peer2", Message: "peer2: hold time 10500ms rounded to 10s"},
+	}
+	if !cmp.Equal(expected, warnings) {
+		t.Fatalf("unexpected warnings (-want +got)\n%s", cmp.Diff(expected, warnings))
+	}
+
+	// the warnings of a previous conversion are not carried over.
+	c.Peers = c.Peers[:1]
+	_, warnings, err = resourcesWithWarningsFor(c, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+}
+
+func TestPeerConnectTime(t *testing.T) {
+	tests := []struct {
+		desc        string
+		connectTime string
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{desc: "unset", connectTime: "", expected: 0},
+		{desc: "seconds", connectTime: "10s", expected: 10 * time.Second},
+		{desc: "rounded", connectTime: "2500ms", expected: 2 * time.Second},
+		{desc: "zero", connectTime: "0s", expected: 0},
+		{desc: "rounds to zero", connectTime: "500ms", expectedErr: true},
+		{desc: "invalid duration", connectTime: "ten seconds", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			in := validPeer()
+			in.ConnectTime = test.connectTime
+			p, err := parsePeer(&configFile{}, in, defaultOptions())
+			if test.expectedErr {
+				var convErr *config.ConversionError
+				if !errors.As(err, &convErr) {
+					t.Fatalf("expected a conversion error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if test.connectTime == "" {
+				if p.Spec.ConnectTime != nil {
+					t.Fatalf("expected no connect time, got %s", p.Spec.ConnectTime.Duration)
+				}
+				return
+			}
+			if p.Spec.ConnectTime == nil || p.Spec.ConnectTime.Duration != test.expected {
+				t.Fatalf("expected connect time %s, got %v", test.expected, p.Spec.ConnectTime)
+			}
+		})
+	}
+}
+
+func TestUnnumberedPeers(t *testing.T) {
+	tests := []struct {
+		desc        string
+		peers       []peer
+		expected    []v1beta2.BGPPeerSpec
+		expectedErr bool
+	}{
+		{
+			desc: "interface peer",
+			peers: []peer{
+				{MyASN: 42, ASN: 142, Addr: "1.2.3.4"},
+				{MyASN: 42, ASN: 142, Interface: "eth0"},
+			},
+			expected: []v1beta2.BGPPeerSpec{
+				{MyASN: 42, ASN: 142, Address: "1.2.3.4"},
+				{MyASN: 42, ASN: 142, Interface: "eth0"},
+			},
+		},
+		{
+			desc:        "both address and interface",
+			peers:       []peer{{MyASN: 42, ASN: 142, Addr: "1.2.3.4", Interface: "eth0"}},
+			expectedErr: true,
+		},
+		{
+			desc:        "neither address nor interface",
+			peers:       []peer{{MyASN: 42, ASN: 142}},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			peers, _, err := peersFor(&configFile{Peers: test.peers}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(peers) != len(test.expected) {
+				t.Fatalf("expected %d peers, got %d", len(test.expected), len(peers))
+			}
+			for i, p := range peers {
+				if name := fmt.Sprintf("peer%d", i+1); p.Name != name {
+					t.Fatalf("expected peer name %s, got %s", name, p.Name)
+				}
+				if p.Spec.Address != test.expected[i].Address || p.Spec.Interface != test.expected[i].Interface {
+					t.Fatalf("peer %s: expected address %q and interface %q, got %q and %q", p.Name,
+						test.expected[i].Address, test.expected[i].Interface, p.Spec.Address, p.Spec.Interface)
+				}
+			}
+		})
+	}
+}
+
+func TestPeerPasswordSecrets(t *testing.T) {
+	cf := &configFile{
+		Peers: []peer{
+			{MyASN: 42, ASN: 142, Addr: "1.2.3.4", Password: "s3cr3t-password"},
+			{MyASN: 42, ASN: 142, Addr: "1.2.3.5"},
+		},
+	}
+
+	t.Run("plaintext", func(t *testing.T) {
+		peers, secrets, err := peersFor(cf, defaultOptions())
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(secrets) != 0 {
+			t.Fatalf("expected no secrets, got %d", len(secrets))
+		}
+		if peers[0].Spec.Password != "s3cr3t-password" {
+			t.Fatalf("expected the password to be set in the peer, got %q", peers[0].Spec.Password)
+		}
+	})
+
+	t.Run("secrets", func(t *testing.T) {
+		var err error
+		resources := config.ClusterResources{}
+		opts := defaultOptions()
+		opts.passwordSecrets = true
+		resources.Peers, resources.PasswordSecrets, err = peersFor(cf, opts)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(resources.PasswordSecrets) != 1 {
+			t.Fatalf("expected 1 secret, got %d", len(resources.PasswordSecrets))
+		}
+		ref := resources.Peers[0].Spec.PasswordSecret
+		secret, ok := resources.PasswordSecrets[ref.Name]
+		if !ok {
+			t.Fatalf("secret %q referenced by %s not found", ref.Name, resources.Peers[0].Name)
+		}
+		if string(secret.Data["password"]) != "s3cr3t-password" {
+			t.Fatalf("unexpected password in secret %q", secret.Data["password"])
+		}
+		if resources.Peers[1].Spec.PasswordSecret.Name != "" {
+			t.Fatalf("expected no secret for the peer without password, got %q", resources.Peers[1].Spec.PasswordSecret.Name)
+		}
+
+		cfg, err := config.For(resources, config.DontValidate)
+		if err != nil {
+			t.Fatalf("failed to parse the resources %v", err)
+		}
+		if cfg.Peers[resources.Peers[0].Name].Password != "s3cr3t-password" {
+			t.Fatalf("expected the password to be read from the secret")
+		}
+
+		for _, p := range resources.Peers {
+			if p.Spec.Password != "" {
+				t.Fatalf("expected no plaintext password in peer %s, got %q", p.Name, p.Spec.Password)
+			}
+		}
+		resources.PasswordSecrets = nil
+		var buf bytes.Buffer
+		if err := createResourcesYAMLs(&buf, resources); err != nil {
+			t.Fatalf("failed to render the peers %v", err)
+		}
+		if strings.Contains(buf.String(), "s3cr3t-password") {
+			t.Fatalf("plaintext password found in the rendered peers:\n%s", buf.String())
+		}
+	})
+}
+
+func TestLintLegacy(t *testing.T) {
+	cm := corev1.ConfigMap{
+		Data: map[string]string{
+			"config": `
+peers:
+- peer-address: 10.0.0.1
+  peer-asn: 64513
+  my-asn: 64512
+  hold-time: 1s
+  keepalive-time: foo
+- peer-address: 10.0.0.2
+  peer-asn: 100
+  my-asn: 64512
+  node-selectors:
+  - match-expressions:
+    - key: kubernetes.io/hostname
+      operator: Foo
+      values: [hostA]
+bgp-communities:
+  bad: foo
+address-pools:
+- name: default
+  protocol: bgp
+  addresses:
+  - 198.51.100.0/24
+  bgp-advertisements:
+  - communities:
+    - missing-alias
+- name: l2
+  protocol: layer2
+  addresses:
+  - 198.51.200.0/33
+`,
+		},
+	}
+
+	errs, warnings := LintLegacy(cm)
+	expectedErrs := []string{
+		`peer1: invalid hold time "1s": must be 0 or >=3s`,
+		`peer1: invalid keepalive time "foo": time: invalid duration "foo"`,
+		`peer2: peer 10.0.0.2: invalid node selector: "Foo" is not a valid label selector operator`,
+		`bgp-communities: invalid community "foo" for alias bad: invalid community format: foo`,
+		`default: invalid community "missing-alias": invalid community format: missing-alias`,
+		`l2: pool l2: invalid address "198.51.200.0/33": invalid CIDR "198.51.200.0/33"`,
+	}
+	if !cmp.Equal(expectedErrs, errs) {
+		t.Fatalf("unexpected errors (-want +got)\n%s", cmp.Diff(expectedErrs, errs))
+	}
+	expectedWarnings := []string{
+		"peer2: local ASN 64512 is private while the eBGP peer ASN 100 is public",
+	}
+	if !cmp.Equal(expectedWarnings, warnings) {
+		t.Fatalf("unexpected warnings (-want +got)\n%s", cmp.Diff(expectedWarnings, warnings))
+	}
+
+	errs, _ = LintLegacy(corev1.ConfigMap{Data: map[string]string{"config": "peers: []"}})
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors for a valid configuration, got %v", errs)
+	}
+}
+
+func TestLintWarnings(t *testing.T) {
+	data := `
+namespace-communities:
+  ns1: ["64512:1"]
+  ns2: ["64512:2"]
+  unused: ["64512:3"]
+address-pools:
+- name: shared
+  protocol: bgp
+  namespaces: [ns1, ns2]
+  addresses:
+  - 192.168.1.0/24
+- name: dual
+  protocol: bgp
+  addresses:
+  - 192.168.2.0/24
+  - fc00:f853:ccd:e799::/64
+  bgp-advertisements:
+  - aggregation-length: 32
+`
+	cf := &configFile{}
+	if err := yaml.Unmarshal([]byte(data), cf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, convWarnings, err := resourcesWithWarningsFor(cf, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{}
+	for _, w := range convWarnings {
+		expected = append(expected, fmt.Sprintf("%s: %s", w.Element, w.Message))
+	}
+
+	errs, warnings := LintLegacy(corev1.ConfigMap{Data: map[string]string{"config": data}})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected lint errors %v", errs)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("expected the namespace and the advertisement warnings, got %v", warnings)
+	}
+	if !cmp.Equal(expected, warnings) {
+		t.Fatalf("unexpected lint warnings (-want +got)\n%s", cmp.Diff(expected, warnings))
+	}
+}
+
+func TestLintMatchesConversion(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config string
+	}{
+		{
+			desc: "bgp+layer2 pool skipping the default advertisement",
+			config: `
+address-pools:
+- name: pool
+  protocol: bgp+layer2
+  skip-default-advertisement: true
+  addresses:
+  - 192.168.1.0/24
+`,
+		},
+		{
+			desc: "dual-stack pool with ipv4 addresses only",
+			config: `
+address-pools:
+- name: pool
+  protocol: layer2
+  require-dual-stack: true
+  addresses:
+  - 192.168.1.0/24
+`,
+		},
+		{
+			desc: "bgp pool with interfaces",
+			config: `
+address-pools:
+- name: pool
+  protocol: bgp
+  interfaces: [eth0]
+  addresses:
+  - 192.168.1.0/24
+`,
+		},
+		{
+			desc: "peer with an unknown bfd profile",
+			config: `
+peers:
+- peer-address: 10.0.0.1
+  peer-asn: 64513
+  my-asn: 64512
+  bfd-profile: missing
+`,
+		},
+		{
+			desc: "peer with an invalid node selector",
+			config: `
+peers:
+- peer-address: 10.0.0.1
+  peer-asn: 64513
+  my-asn: 64512
+  node-selectors:
+  - match-expressions:
+    - key: kubernetes.io/hostname
+      operator: Foo
+      values: [hostA]
+`,
+		},
+		{
+			desc: "duplicate bfd profile names",
+			config: `
+bfd-profiles:
+- name: bfd
+- name: bfd
+`,
+		},
+		{
+			desc: "bfd profile with an out of range echo receive interval",
+			config: `
+bfd-profiles:
+- name: bfd
+  echo-receive-interval: 5
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cf := &configFile{}
+			if err := yaml.Unmarshal([]byte(test.config), cf); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			_, convErr := resourcesFor(cf, defaultOptions())
+			errs, _ := LintLegacy(corev1.ConfigMap{Data: map[string]string{"config": test.config}})
+			if convErr == nil || len(errs) != 1 {
+				t.Fatalf("expected both the conversion and the lint to fail, got %v and %v", convErr, errs)
+			}
+			if !strings.HasSuffix(errs[0], convErr.Error()) {
+				t.Fatalf("expected the lint error %q to report %q", errs[0], convErr.Error())
+			}
+		})
+	}
+}
+
+func TestPrivateASNWarning(t *testing.T) {
+	tests := []struct {
+		desc         string
+		myASN        uint32
+		asn          uint32
+		expectedWarn bool
+	}{
+		{desc: "ibgp private", myASN: 64512, asn: 64512},
+		{desc: "ibgp public", myASN: 100, asn: 100},
+		{desc: "ebgp both private", myASN: 64512, asn: 65000},
+		{desc: "ebgp both private 4 bytes", myASN: 4200000000, asn: 64513},
+		{desc: "ebgp both public", myASN: 100, asn: 200},
+		{desc: "ebgp private local", myASN: 65000, asn: 200, expectedWarn: true},
+		{desc: "ebgp private peer", myASN: 100, asn: 65534, expectedWarn: true},
+		{desc: "ebgp private 4 bytes peer", myASN: 100, asn: 4294967294, expectedWarn: true},
+		{desc: "ebgp reserved asn is not private", myASN: 65535, asn: 200},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			w := privateASNWarning(peer{MyASN: test.myASN, ASN: test.asn, Addr: "1.2.3.4"})
+			if test.expectedWarn && w == "" {
+				t.Fatalf("expected a warning")
+			}
+			if !test.expectedWarn && w != "" {
+				t.Fatalf("unexpected warning %q", w)
+			}
+		})
+	}
+}
+
+func TestDefaultCommunity(t *testing.T) {
+	tests := []struct {
+		desc             string
+		defaultCommunity string
+		advs             []bgpAdvertisement
+		expected         [][]string
+		expectedErr      bool
+	}{
+		{
+			desc:     "no default community",
+			advs:     nil,
+			expected: [][]string{nil},
+		},
+		{
+			desc:             "empty advertisement",
+			defaultCommunity: "65535:65282",
+			advs:             nil,
+			expected:         [][]string{{"65535:65282"}},
+		},
+		{
+			desc:             "advertisement without communities",
+			defaultCommunity: "65535:65282",
+			advs:             []bgpAdvertisement{{LocalPref: 100}},
+			expected:         [][]string{{"65535:65282"}},
+		},
+		{
+			desc:             "advertisements with explicit communities",
+			defaultCommunity: "65535:65282",
+			advs: []bgpAdvertisement{
+				{Communities: []string{"1234:1"}},
+				{LocalPref: 100},
+			},
+			expected: [][]string{{"1234:1"}, {"65535:65282"}},
+		},
+		{
+			desc:             "alias",
+			defaultCommunity: "no-advertise",
+			advs:             nil,
+			expected:         [][]string{{"no-advertise"}},
+		},
+		{
+			desc:             "invalid",
+			defaultCommunity: "foo",
+			expectedErr:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cf := &configFile{
+				BGPCommunities:   map[string]string{"no-advertise": "65535:65282"},
+				DefaultCommunity: test.defaultCommunity,
+				Pools: []addressPool{
+					{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"}, BGPAdvertisements: test.advs},
+				},
+			}
+			advs, err := bgpAdvertisementsFor(cf, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			communities := [][]string{}
+			for _, adv := range advs {
+				communities = append(communities, adv.Spec.Communities)
+			}
+			if !cmp.Equal(test.expected, communities) {
+				t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff(test.expected, communities))
+			}
+		})
+	}
+}
+
+func TestGracefulShutdownCommunity(t *testing.T) {
+	tests := []struct {
+		desc     string
+		advs     []bgpAdvertisement
+		expected [][]string
+	}{
+		{
+			desc:     "disabled",
+			advs:     []bgpAdvertisement{{Communities: []string{"1234:1"}}},
+			expected: [][]string{{"1234:1"}},
+		},
+		{
+			desc:     "enabled without communities",
+			advs:     []bgpAdvertisement{{GracefulShutdown: true}},
+			expected: [][]string{{"65535:0"}},
+		},
+		{
+			desc:     "enabled with communities",
+			advs:     []bgpAdvertisement{{Communities: []string{"65535:65282", "1234:1", "large:1:2:3"}, GracefulShutdown: true}},
+			expected: [][]string{{"large:1:2:3", "1234:1", "65535:0", "65535:65282"}},
+		},
+		{
+			desc:     "enabled with the community already set",
+			advs:     []bgpAdvertisement{{Communities: []string{"1234:1", "65535:0"}, GracefulShutdown: true}},
+			expected: [][]string{{"1234:1", "65535:0"}},
+		},
+		{
+			desc:     "enabled with an alias of the community",
+			advs:     []bgpAdvertisement{{Communities: []string{"gshut"}, GracefulShutdown: true}},
+			expected: [][]string{{"gshut"}},
+		},
+		{
+			desc: "enabled on one advertisement only",
+			advs: []bgpAdvertisement{
+				{Communities: []string{"1234:1"}, GracefulShutdown: true},
+				{Communities: []string{"1234:1"}, LocalPref: 100},
+			},
+			expected: [][]string{{"1234:1", "65535:0"}, {"1234:1"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cf := &configFile{
+				BGPCommunities: map[string]string{"gshut": "65535:0"},
+				Pools: []addressPool{
+					{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"}, BGPAdvertisements: test.advs},
+				},
+			}
+			advs, err := bgpAdvertisementsFor(cf, defaultOptions())
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			communities := [][]string{}
+			for _, adv := range advs {
+				communities = append(communities, adv.Spec.Communities)
+			}
+			if !cmp.Equal(test.expected, communities) {
+				t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff(test.expected, communities))
+			}
+		})
+	}
+}
+
+func TestNamespaceCommunities(t *testing.T) {
+	tests := []struct {
+		desc        string
+		namespaces  []string
+		mappings    map[string][]string
+		expected    [][]string
+		expectedErr bool
+	}{
+		{
+			desc:       "no mappings",
+			namespaces: []string{"tenant-a"},
+			expected:   [][]string{nil},
+		},
+		{
+			desc:       "single namespace",
+			namespaces: []string{"tenant-a"},
+			mappings:   map[string][]string{"tenant-a": {"64512:100"}},
+			expected:   [][]string{nil, {"64512:100"}},
+		},
+		{
+			desc:       "alias and unmapped namespace",
+			namespaces: []string{"tenant-a", "tenant-b"},
+			mappings:   map[string][]string{"tenant-b": {"no-advertise", "64512:200"}},
+			expected:   [][]string{nil, {"64512:200", "no-advertise"}},
+		},
+		{
+			desc:        "invalid community",
+			namespaces:  []string{"tenant-a"},
+			mappings:    map[string][]string{"tenant-a": {"foo"}},
+			expectedErr: true,
+		},
+		{
+			desc:        "no communities",
+			namespaces:  []string{"tenant-a"},
+			mappings:    map[string][]string{"tenant-a": {}},
+			expectedErr: true,
+		},
+		{
+			desc:        "invalid namespace",
+			namespaces:  []string{"tenant-a"},
+			mappings:    map[string][]string{"Tenant_A": {"64512:100"}},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cf := &configFile{
+				BGPCommunities:       map[string]string{"no-advertise": "65535:65282"},
+				NamespaceCommunities: test.mappings,
+				Pools: []addressPool{
+					{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"}, Namespaces: test.namespaces},
+				},
+			}
+			advs, err := bgpAdvertisementsFor(cf, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			communities := [][]string{}
+			for _, adv := range advs {
+				if !cmp.Equal([]string{"pool"}, adv.Spec.IPAddressPools) {
+					t.Fatalf("unexpected pools %v for advertisement %s", adv.Spec.IPAddressPools, adv.Name)
+				}
+				communities = append(communities, adv.Spec.Communities)
+			}
+			if !cmp.Equal(test.expected, communities) {
+				t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff(test.expected, communities))
+			}
+		})
+	}
+}
+
+func TestNamespaceCommunitiesWithBlackhole(t *testing.T) {
+	data := `
+default-community: "64512:1"
+namespace-communities:
+  tenant-a: ["64512:100"]
+  tenant-b: ["64512:200", "blackhole"]
+address-pools:
+- name: pool
+  protocol: bgp
+  blackhole-community: blackhole
+  namespaces: [tenant-a, tenant-b]
+  addresses:
+  - 192.168.1.0/24
+`
+	cf := &configFile{}
+	if err := yaml.Unmarshal([]byte(data), cf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	r, err := resourcesFor(cf, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := map[string][]string{
+		"pool-bgp-0": {"64512:1", "65535:666"},
+		"pool-bgp-1": {"64512:100", "65535:666"},
+		"pool-bgp-2": {"64512:200", "65535:666"},
+	}
+	communities := map[string][]string{}
+	for _, adv := range r.BGPAdvs {
+		communities[adv.Name] = adv.Spec.Communities
+	}
+	if !cmp.Equal(expected, communities) {
+		t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff(expected, communities))
+	}
+}
+
+func TestNextHop(t *testing.T) {
+	tests := []struct {
+		desc        string
+		addresses   []string
+		nextHop     string
+		expectedErr bool
+	}{
+		{desc: "unset", addresses: []string{"192.168.1.0/24"}},
+		{desc: "ipv4", addresses: []string{"192.168.1.0/24"}, nextHop: "10.0.0.1"},
+		{desc: "ipv6", addresses: []string{"fc00:f853:ccd:e799::/124"}, nextHop: "fc00::1"},
+		{desc: "family mismatch", addresses: []string{"192.168.1.0/24"}, nextHop: "fc00::1", expectedErr: true},
+		{desc: "dual-stack pool", addresses: []string{"192.168.1.0/24", "fc00:f853:ccd:e799::/124"}, nextHop: "10.0.0.1", expectedErr: true},
+		{desc: "invalid ip", addresses: []string{"192.168.1.0/24"}, nextHop: "10.0.0.300", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cf := &configFile{
+				Pools: []addressPool{
+					{
+						Name:              "pool",
+						Protocol:          BGP,
+						Addresses:         test.addresses,
+						BGPAdvertisements: []bgpAdvertisement{{NextHop: test.nextHop}},
+					},
+				},
+			}
+			r, err := resourcesFor(cf, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if r.BGPAdvs[0].Spec.NextHop != test.nextHop {
+				t.Fatalf("expected next hop %q, got %q", test.nextHop, r.BGPAdvs[0].Spec.NextHop)
+			}
+		})
+	}
+}
+
+func TestL2AdvertisementInterfaces(t *testing.T) {
+	tests := []struct {
+		desc        string
+		pool        addressPool
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			desc:     "all interfaces",
+			pool:     addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}},
+			expected: nil,
+		},
+		{
+			desc:     "restricted to interfaces",
+			pool:     addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}, Interfaces: []string{"eth0", "eth1"}},
+			expected: []string{"eth0", "eth1"},
+		},
+		{
+			desc:        "empty interface name",
+			pool:        addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}, Interfaces: []string{"eth0", " "}},
+			expectedErr: true,
+		},
+		{
+			desc:        "bgp pool with interfaces",
+			pool:        addressPool{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"}, Interfaces: []string{"eth0"}},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r, err := resourcesFor(&configFile{Pools: []addressPool{test.pool}}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(r.L2Advs) != 1 {
+				t.Fatalf("expected 1 advertisement, got %d", len(r.L2Advs))
+			}
+			if !cmp.Equal(test.expected, r.L2Advs[0].Spec.Interfaces) {
+				t.Fatalf("unexpected interfaces (-want +got)\n%s", cmp.Diff(test.expected, r.L2Advs[0].Spec.Interfaces))
+			}
+		})
+	}
+}
+
+func TestL2AdvertisementNodeSelectors(t *testing.T) {
+	sel := nodeSelector{
+		MatchLabels: map[string]string{"node-role": "edge"},
+		MatchExpressions: []selectorRequirements{
+			{Key: "zone", Operator: "In", Values: []string{"a", "b"}},
+		},
+	}
+	c := &configFile{Pools: []addressPool{
+		{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}, NodeSelectors: []nodeSelector{sel}},
+	}}
+	advs, err := l2AdvertisementsFor(c, resourcesNameSpace)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(advs) != 1 {
+		t.Fatalf("expected 1 advertisement, got %d", len(advs))
+	}
+	expected := []metav1.LabelSelector{
+		{
+			MatchLabels: map[string]string{"node-role": "edge"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+			},
+		},
+	}
+	if !cmp.Equal(expected, advs[0].Spec.NodeSelectors) {
+		t.Fatalf("unexpected node selectors (-want +got)\n%s", cmp.Diff(expected, advs[0].Spec.NodeSelectors))
+	}
+
+	c.Pools[0].Protocol = BGP
+	_, err = resourcesFor(c, defaultOptions())
+	assertValidationError(t, err)
+}
+
+func TestL2AdvertisementNodeSelectionPolicy(t *testing.T) {
+	tests := []struct {
+		desc        string
+		protocol    Proto
+		policy      string
+		expectedErr bool
+	}{
+		{
+			desc:     "default",
+			protocol: Layer2,
+		},
+		{
+			desc:     "leader",
+			protocol: Layer2,
+			policy:   "Leader",
+		},
+		{
+			desc:        "unknown policy",
+			protocol:    Layer2,
+			policy:      "Random",
+			expectedErr: true,
+		},
+		{
+			desc:        "bgp pool",
+			protocol:    BGP,
+			policy:      "Leader",
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &configFile{Pools: []addressPool{
+				{Name: "pool", Protocol: test.protocol, Addresses: []string{"192.168.1.0/24"}, NodeSelection: test.policy},
+			}}
+			r, err := resourcesFor(c, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(r.L2Advs) != 1 {
+				t.Fatalf("expected 1 advertisement, got %d", len(r.L2Advs))
+			}
+			if r.L2Advs[0].Spec.NodeSelectionPolicy != test.policy {
+				t.Fatalf("expected node selection policy %q, got %q", test.policy, r.L2Advs[0].Spec.NodeSelectionPolicy)
+			}
+		})
+	}
+}
+
+func TestLayer2PoolWithBGPAttributes(t *testing.T) {
+	tests := []struct {
+		desc        string
+		pool        addressPool
+		expectedErr string
+	}{
+		{
+			desc: "bgp pool with localpref",
+			pool: addressPool{
+				Name:              "pool",
+				Protocol:          BGP,
+				Addresses:         []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{{LocalPref: 100}},
+			},
+		},
+		{
+			desc: "layer2 pool with stray localpref",
+			pool: addressPool{
+				Name:              "pool",
+				Protocol:          Layer2,
+				Addresses:         []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{{LocalPref: 100}},
+			},
+			expectedErr: "pool pool: localpref is a bgp only attribute and can't be set on a layer2 pool",
+		},
+		{
+			desc: "layer2 pool with communities",
+			pool: addressPool{
+				Name:              "pool",
+				Protocol:          Layer2,
+				Addresses:         []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{{Communities: []string{"65535:65282"}}},
+			},
+			expectedErr: "pool pool: communities is a bgp only attribute and can't be set on a layer2 pool",
+		},
+		{
+			desc: "layer2 pool with next-hop",
+			pool: addressPool{
+				Name:              "pool",
+				Protocol:          Layer2,
+				Addresses:         []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{{NextHop: "10.0.0.1"}},
+			},
+			expectedErr: "pool pool: next-hop is a bgp only attribute and can't be set on a layer2 pool",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := resourcesFor(&configFile{Pools: []addressPool{test.pool}}, defaultOptions())
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			assertValidationError(t, err)
+			if err.Error() != test.expectedErr {
+				t.Fatalf("expected error %q, got %q", test.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestAdvertisementNamesStable(t *testing.T) {
+	pools := []addressPool{
+		{Name: "bgp-pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+			BGPAdvertisements: []bgpAdvertisement{{LocalPref: 100}, {LocalPref: 200}}},
+		{Name: "l2-pool", Protocol: Layer2, Addresses: []string{"192.168.2.0/24"}},
+	}
+	names := func(c *configFile) []string {
+		bgpAdvs, err := bgpAdvertisementsFor(c, defaultOptions())
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		l2Advs, err := l2AdvertisementsFor(c, resourcesNameSpace)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		res := map[string][]string{}
+		for _, adv := range bgpAdvs {
+			res[adv.Spec.IPAddressPools[0]] = append(res[adv.Spec.IPAddressPools[0]], adv.Name)
+		}
+		for _, adv := range l2Advs {
+			res[adv.Spec.IPAddressPools[0]] = append(res[adv.Spec.IPAddressPools[0]], adv.Name)
+		}
+		return append(res["bgp-pool"], res["l2-pool"]...)
+	}
+
+	expected := []string{"bgp-pool-bgp-0", "bgp-pool-bgp-1", "l2-pool-l2-0"}
+	got := names(&configFile{Pools: pools})
+	if !cmp.Equal(expected, got) {
+		t.Fatalf("unexpected names (-want +got)\n%s", cmp.Diff(expected, got))
+	}
+
+	inserted := append([]addressPool{
+		{Name: "first-bgp", Protocol: BGP, Addresses: []string{"192.168.3.0/24"}},
+		{Name: "first-l2", Protocol: Layer2, Addresses: []string{"192.168.4.0/24"}},
+	}, pools...)
+	got = names(&configFile{Pools: inserted})
+	if !cmp.Equal(expected, got) {
+		t.Fatalf("names changed after inserting pools (-want +got)\n%s", cmp.Diff(expected, got))
+	}
+}
+
+func TestPeerBFDProfileReference(t *testing.T) {
+	c := &configFile{
+		Peers: []peer{
+			{MyASN: 64512, ASN: 64513, Addr: "10.0.0.1"},
+			{MyASN: 64512, ASN: 64513, Addr: "10.0.0.2", BFDProfile: "fast"},
+		},
+		BFDProfiles: []bfdProfile{{Name: "fast"}},
+	}
+	r, err := resourcesFor(c, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if r.Peers[1].Spec.BFDProfile != "fast" {
+		t.Fatalf("expected bfd profile fast, got %q", r.Peers[1].Spec.BFDProfile)
+	}
+
+	c.BFDProfiles = []bfdProfile{{Name: "slow"}}
+	_, err = resourcesFor(c, defaultOptions())
+	if convErr := assertValidationError(t, err); convErr.Name != "10.0.0.2" {
+		t.Fatalf("expected the error for peer 10.0.0.2, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "peer 10.0.0.2: bfd profile fast not found") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestAdvertisementCommunityReference(t *testing.T) {
+	c := &configFile{
+		BGPCommunities: map[string]string{"bar": "64512:1234"},
+		Pools: []addressPool{
+			{
+				Name:              "bgp-pool",
+				Protocol:          BGP,
+				Addresses:         []string{"192.168.10.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{{Communities: []string{"bar", "64512:10"}}},
+			},
+		},
+	}
+	r, err := resourcesFor(c, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !cmp.Equal(r.BGPAdvs[0].Spec.Communities, []string{"64512:10", "bar"}) {
+		t.Fatalf("unexpected communities %v", r.BGPAdvs[0].Spec.Communities)
+	}
+
+	// The legacy communities are validated while parsing, so the dangling
+	// alias is injected in the generated resources.
+	r.BGPAdvs[0].Spec.Communities = append(r.BGPAdvs[0].Spec.Communities, "foo")
+	err = validateCommunityRefs(r.BGPAdvs, r.Communities)
+	assertValidationError(t, err)
+	if !strings.Contains(err.Error(), "bgp advertisement bgp-pool-bgp-0: community foo not found") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCommonLabels(t *testing.T) {
+	c := &configFile{
+		Peers: []peer{
+			{MyASN: 64512, ASN: 64513, Addr: "10.0.0.1", Password: "s3cr3t", BFDProfile: "fast"},
+		},
+		BFDProfiles:    []bfdProfile{{Name: "fast"}},
+		BGPCommunities: map[string]string{"bar": "64512:1234"},
+		Pools: []addressPool{
+			{Name: "bgp-pool", Protocol: BGP, Addresses: []string{"192.168.10.0/24"}},
+			{Name: "l2-pool", Protocol: Layer2, Addresses: []string{"192.168.20.0/24"}},
+		},
+	}
+	commonLabels, err := parseLabels("app.kubernetes.io/managed-by=metallb-conversion,team=network")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	opts := defaultOptions()
+	opts.passwordSecrets = true
+	opts.labels = commonLabels
+	r, err := resourcesFor(c, opts)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	kinds := map[string]bool{}
+	for _, o := range resourcesToObjects(r) {
+		obj, ok := o.(metav1.Object)
+		if !ok {
+			t.Fatalf("unexpected object %T", o)
+		}
+		kinds[fmt.Sprintf("%T", o)] = true
+		if !cmp.Equal(commonLabels, obj.GetLabels()) {
+			t.Fatalf("unexpected labels for %T %s (-want +got)\n%s", o, obj.GetName(), cmp.Diff(commonLabels, obj.GetLabels()))
+		}
+	}
+	expectedKinds := map[string]bool{
+		"*v1beta2.BGPPeer":          true,
+		"*v1beta1.BFDProfile":       true,
+		"*v1beta1.Community":        true,
+		"*v1beta1.IPAddressPool":    true,
+		"*v1beta1.BGPAdvertisement": true,
+		"*v1beta1.L2Advertisement":  true,
+		"*v1.Secret":                true,
+	}
+	if !cmp.Equal(expectedKinds, kinds) {
+		t.Fatalf("unexpected kinds (-want +got)\n%s", cmp.Diff(expectedKinds, kinds))
+	}
+
+	opts.labels = nil
+	r, err = resourcesFor(c, opts)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, o := range resourcesToObjects(r) {
+		if l := o.(metav1.Object).GetLabels(); len(l) != 0 {
+			t.Fatalf("expected no labels for %T, got %v", o, l)
+		}
+	}
+
+	if _, err := parseLabels("foo=bar,=baz"); err == nil {
+		t.Fatalf("expected an error for an invalid label")
+	}
+}
+
+func TestPoolNamespaceSelectors(t *testing.T) {
+	tests := []struct {
+		desc        string
+		pool        addressPool
+		expected    *v1beta1.ServiceAllocation
+		expectedErr bool
+	}{
+		{
+			desc: "no selectors",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}},
+		},
+		{
+			desc: "labeled namespaces",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"},
+				NamespaceSelectors: []nodeSelector{{MatchLabels: map[string]string{"team": "edge"}}}},
+			expected: &v1beta1.ServiceAllocation{
+				NamespaceSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"team": "edge"}, MatchExpressions: []metav1.LabelSelectorRequirement{}},
+				},
+			},
+		},
+		{
+			desc: "namespaces and labeled namespaces",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"},
+				Namespaces: []string{"tenant-a"},
+				NamespaceSelectors: []nodeSelector{{MatchExpressions: []selectorRequirements{
+					{Key: "team", Operator: "Exists"},
+				}}}},
+			expected: &v1beta1.ServiceAllocation{
+				Namespaces: []string{"tenant-a"},
+				NamespaceSelectors: []metav1.LabelSelector{
+					{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "team", Operator: metav1.LabelSelectorOpExists, Values: []string{}},
+					}},
+				},
+			},
+		},
+		{
+			desc: "invalid operator",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"},
+				NamespaceSelectors: []nodeSelector{{MatchExpressions: []selectorRequirements{
+					{Key: "team", Operator: "Foo"},
+				}}}},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pools, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{test.pool}}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(test.expected, pools[0].Spec.AllocateTo) {
+				t.Fatalf("unexpected allocation (-want +got)\n%s", cmp.Diff(test.expected, pools[0].Spec.AllocateTo))
+			}
+		})
+	}
+}
+
+func TestPoolServiceSelectors(t *testing.T) {
+	tests := []struct {
+		desc        string
+		pool        addressPool
+		expected    *v1beta1.ServiceAllocation
+		expectedErr bool
+	}{
+		{
+			desc: "labeled services",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"},
+				ServiceSelectors: []nodeSelector{{MatchLabels: map[string]string{"tier": "public"}}}},
+			expected: &v1beta1.ServiceAllocation{
+				ServiceSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"tier": "public"}, MatchExpressions: []metav1.LabelSelectorRequirement{}},
+				},
+			},
+		},
+		{
+			desc: "labeled services with priority",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}, Priority: 10,
+				ServiceSelectors: []nodeSelector{{MatchExpressions: []selectorRequirements{
+					{Key: "tier", Operator: "In", Values: []string{"public"}},
+				}}}},
+			expected: &v1beta1.ServiceAllocation{
+				Priority: 10,
+				ServiceSelectors: []metav1.LabelSelector{
+					{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"public"}},
+					}},
+				},
+			},
+		},
+		{
+			desc: "invalid selector",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"},
+				ServiceSelectors: []nodeSelector{{MatchExpressions: []selectorRequirements{
+					{Key: "tier", Operator: "In"},
+				}}}},
+			expectedErr: true,
+		},
+		{
+			desc:        "priority without scope",
+			pool:        addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}, Priority: 10},
+			expectedErr: true,
+		},
+		{
+			desc: "negative priority",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}, Priority: -1,
+				Namespaces: []string{"tenant-a"}},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pools, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{test.pool}}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(test.expected, pools[0].Spec.AllocateTo) {
+				t.Fatalf("unexpected allocation (-want +got)\n%s", cmp.Diff(test.expected, pools[0].Spec.AllocateTo))
+			}
+		})
+	}
+}
+
+func TestPoolRequireDualStack(t *testing.T) {
+	tests := []struct {
+		desc        string
+		addresses   []string
+		expectedErr bool
+	}{
+		{
+			desc:      "dual-stack",
+			addresses: []string{"192.168.1.0/24", "fc00:f853:ccd:e799::/124"},
+		},
+		{
+			desc:        "ipv4 only",
+			addresses:   []string{"192.168.1.0/24", "192.168.10.1-192.168.10.10"},
+			expectedErr: true,
+		},
+		{
+			desc:        "ipv6 only",
+			addresses:   []string{"fc00:f853:ccd:e799::/124"},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pool := addressPool{Name: "pool", Protocol: Layer2, Addresses: test.addresses, RequireDualStack: true}
+			_, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{pool}}, defaultOptions())
+			if !test.expectedErr {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			assertValidationError(t, err)
+		})
+	}
+}
+
+func TestPoolAllocationAlgorithm(t *testing.T) {
+	tests := []struct {
+		desc        string
+		algorithm   string
+		expectedErr bool
+	}{
+		{desc: "default"},
+		{desc: "lowest free", algorithm: config.AllocationLowestFree},
+		{desc: "round robin", algorithm: config.AllocationRoundRobin},
+		{desc: "unknown", algorithm: "Random", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pool := addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}, Algorithm: test.algorithm}
+			pools, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{pool}}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if pools[0].Spec.AllocationAlgorithm != test.algorithm {
+				t.Fatalf("expected allocation algorithm %q, got %q", test.algorithm, pools[0].Spec.AllocationAlgorithm)
+			}
+		})
+	}
+}
+
+func TestPoolMergeCIDRs(t *testing.T) {
+	tests := []struct {
+		desc      string
+		addresses []string
+		expected  []string
+	}{
+		{
+			desc:      "adjacent halves",
+			addresses: []string{"192.0.2.128/25", "192.0.2.0/25"},
+			expected:  []string{"192.0.2.0/24"},
+		},
+		{
+			desc:      "adjacent quarters",
+			addresses: []string{"192.0.2.0/26", "192.0.2.64/26", "192.0.2.128/26", "192.0.2.192/26"},
+			expected:  []string{"192.0.2.0/24"},
+		},
+		{
+			desc:      "adjacent ipv6 halves",
+			addresses: []string{"fc00::/65", "fc00::8000:0:0:0/65"},
+			expected:  []string{"fc00::/64"},
+		},
+		{
+			desc:      "different sizes",
+			addresses: []string{"192.0.2.0/25", "192.0.2.128/26"},
+			expected:  []string{"192.0.2.0/25", "192.0.2.128/26"},
+		},
+		{
+			desc:      "contiguous but not in the same supernet",
+			addresses: []string{"192.0.2.128/25", "192.0.3.0/25"},
+			expected:  []string{"192.0.2.128/25", "192.0.3.0/25"},
+		},
+		{
+			desc:      "ranges and single addresses",
+			addresses: []string{"192.0.2.0/25", "192.168.1.1-192.168.1.5", "192.0.2.128/25", "fc00::1"},
+			expected:  []string{"192.0.2.0/24", "fc00::1/128", "192.168.1.1-192.168.1.5"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pool := addressPool{Name: "pool", Protocol: BGP, Addresses: test.addresses}
+
+			opts := defaultOptions()
+			pools, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{pool}}, opts)
+			if err != nil {
+				t.Fatalf("unexpected error without merging %v", err)
+			}
+			if len(pools[0].Spec.Addresses) != len(test.addresses) {
+				t.Fatalf("expected the addresses not to be merged by default, got %v", pools[0].Spec.Addresses)
+			}
+
+			opts.mergeCIDRs = true
+			pools, err = ipAddressPoolsFor(&configFile{Pools: []addressPool{pool}}, opts)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(test.expected, pools[0].Spec.Addresses) {
+				t.Fatalf("unexpected addresses (-want +got)\n%s", cmp.Diff(test.expected, pools[0].Spec.Addresses))
+			}
+		})
+	}
+
+	// the overlapping addresses are rejected before merging, the merged
+	// ranges are checked anyway.
+	_, err := mergeAdjacentCIDRs("pool", []string{"192.0.2.0/24", "192.0.2.10/32"})
+	assertValidationError(t, err)
+}
+
+func TestPoolOverlappingAddresses(t *testing.T) {
+	tests := []struct {
+		desc        string
+		addresses   []string
+		expectedErr bool
+	}{
+		{
+			desc:      "disjoint",
+			addresses: []string{"192.0.2.0/24", "192.0.3.10", "192.0.4.1-192.0.4.10", "fc00::/64"},
+		},
+		{
+			desc:        "address within a cidr",
+			addresses:   []string{"192.0.2.0/24", "192.0.2.10/32"},
+			expectedErr: true,
+		},
+		{
+			desc:        "range within a cidr",
+			addresses:   []string{"192.0.2.0/24", "192.0.2.10-192.0.2.20"},
+			expectedErr: true,
+		},
+		{
+			desc:        "overlapping ranges",
+			addresses:   []string{"192.0.2.1-192.0.2.10", "192.0.2.5-192.0.2.20"},
+			expectedErr: true,
+		},
+		{
+			desc:        "ipv6",
+			addresses:   []string{"fc00::/64", "fc00::1"},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pool := addressPool{Name: "pool", Protocol: Layer2, Addresses: test.addresses}
+			_, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{pool}}, defaultOptions())
+			if !test.expectedErr {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			assertValidationError(t, err)
+			if !strings.Contains(err.Error(), test.addresses[0]) || !strings.Contains(err.Error(), test.addresses[1]) {
+				t.Fatalf("expected the error to name the overlapping entries, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPoolAddresses(t *testing.T) {
+	tests := []struct {
+		desc        string
+		addresses   []string
+		expectedErr string
+	}{
+		{
+			desc:      "cidr",
+			addresses: []string{"192.0.2.0/24", "fc00:f853:ccd:e799::/124"},
+		},
+		{
+			desc:      "range",
+			addresses: []string{"10.0.0.1-10.0.0.5", "10.0.0.7 - 10.0.0.7"},
+		},
+		{
+			desc:        "invalid prefix length",
+			addresses:   []string{"192.0.2.0/24", "192.0.2.0/33"},
+			expectedErr: `pool my-pool: invalid address "192.0.2.0/33"`,
+		},
+		{
+			desc:        "reversed range",
+			addresses:   []string{"10.0.0.5-10.0.0.1"},
+			expectedErr: `pool my-pool: invalid address "10.0.0.5-10.0.0.1"`,
+		},
+		{
+			desc:        "malformed",
+			addresses:   []string{"10.0.0.foo-10.0.0.5"},
+			expectedErr: `pool my-pool: invalid address "10.0.0.foo-10.0.0.5"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pool := addressPool{Name: "my-pool", Protocol: Layer2, Addresses: test.addresses}
+			_, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{pool}}, defaultOptions())
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			var convErr *config.ConversionError
+			if !errors.As(err, &convErr) || convErr.Kind != config.ParseError || convErr.Name != "my-pool" {
+				t.Fatalf("expected a parse error for my-pool, got %v", err)
+			}
+			if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPoolSingleAddresses(t *testing.T) {
+	pool := addressPool{
+		Name:      "my-pool",
+		Protocol:  Layer2,
+		Addresses: []string{"192.0.2.7", "2001:db8::7", "192.0.2.10-192.0.2.20", "10.0.0.0/24"},
+	}
+	pools, err := ipAddressPoolsFor(&configFile{Pools: []addressPool{pool}}, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{"192.0.2.7/32", "2001:db8::7/128", "192.0.2.10-192.0.2.20", "10.0.0.0/24"}
+	if !cmp.Equal(expected, pools[0].Spec.Addresses) {
+		t.Fatalf("unexpected addresses (-want +got)\n%s", cmp.Diff(expected, pools[0].Spec.Addresses))
+	}
+}
+
+func TestAdvertisementCommunities(t *testing.T) {
+	tests := []struct {
+		desc        string
+		communities []string
+		expectedErr string
+	}{
+		{
+			desc:        "aliases and numeric values",
+			communities: []string{"65000:100", "no-export"},
+		},
+		{
+			desc:        "malformed value",
+			communities: []string{"no-export", "65000:foo"},
+			expectedErr: `invalid community "65000:foo"`,
+		},
+		{
+			desc:        "unknown alias",
+			communities: []string{"no-such-alias"},
+			expectedErr: `invalid community "no-such-alias"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &configFile{
+				BGPCommunities: map[string]string{"no-export": "65535:65281"},
+				Pools: []addressPool{
+					{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+						BGPAdvertisements: []bgpAdvertisement{{Communities: test.communities}}},
+				},
+			}
+			r, err := resourcesFor(c, defaultOptions())
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if !cmp.Equal(test.communities, r.BGPAdvs[0].Spec.Communities) {
+					t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff(test.communities, r.BGPAdvs[0].Spec.Communities))
+				}
+				return
+			}
+			assertValidationError(t, err)
+			if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestAdvertisementNodeSelectors(t *testing.T) {
+	tests := []struct {
+		desc        string
+		selectors   []nodeSelector
+		expected    []metav1.LabelSelector
+		expectedErr bool
+	}{
+		{
+			desc: "no node selectors",
+		},
+		{
+			desc: "node selectors",
+			selectors: []nodeSelector{
+				{MatchLabels: map[string]string{"kubernetes.io/hostname": "node1"}},
+				{MatchExpressions: []selectorRequirements{{Key: "zone", Operator: "In", Values: []string{"east"}}}},
+			},
+			expected: []metav1.LabelSelector{
+				{
+					MatchLabels:      map[string]string{"kubernetes.io/hostname": "node1"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{},
+				},
+				{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"east"}}},
+				},
+			},
+		},
+		{
+			desc: "invalid node selector",
+			selectors: []nodeSelector{
+				{MatchExpressions: []selectorRequirements{{Key: "zone", Operator: "Near"}}},
+			},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &configFile{
+				Pools: []addressPool{
+					{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+						BGPAdvertisements: []bgpAdvertisement{{NodeSelectors: test.selectors}}},
+				},
+			}
+			advs, err := bgpAdvertisementsFor(c, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(test.expected, advs[0].Spec.NodeSelectors) {
+				t.Fatalf("unexpected node selectors (-want +got)\n%s", cmp.Diff(test.expected, advs[0].Spec.NodeSelectors))
+			}
+		})
+	}
+}
+
+func TestWellKnownCommunities(t *testing.T) {
+	for name, expected := range map[string]string{
+		"graceful-shutdown":   "65535:0",
+		"blackhole":           "65535:666",
+		"no-export":           "65535:65281",
+		"no-advertise":        "65535:65282",
+		"no-export-subconfed": "65535:65283",
+		"no-peer":             "65535:65284",
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &configFile{
+				Pools: []addressPool{
+					{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+						BGPAdvertisements: []bgpAdvertisement{{Communities: []string{name}}}},
+				},
+			}
+			advs, err := bgpAdvertisementsFor(c, defaultOptions())
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal([]string{expected}, advs[0].Spec.Communities) {
+				t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff([]string{expected}, advs[0].Spec.Communities))
+			}
+		})
+	}
+}
+
+func TestBlackholeCommunity(t *testing.T) {
+	tests := []struct {
+		desc        string
+		pool        addressPool
+		expected    [][]string
+		expectedErr bool
+	}{
+		{
+			desc: "pool blackhole on the default advertisement",
+			pool: addressPool{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+				BlackholeCommunity: "blackhole"},
+			expected: [][]string{{"65535:666"}},
+		},
+		{
+			desc: "pool blackhole composed with the communities of the advertisements",
+			pool: addressPool{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+				BlackholeCommunity: "65535:666",
+				BGPAdvertisements: []bgpAdvertisement{
+					{Communities: []string{"64512:1"}},
+					{Communities: []string{"bar"}, GracefulShutdown: true},
+				}},
+			expected: [][]string{{"64512:1", "65535:666"}, {"bar", "65535:0", "65535:666"}},
+		},
+		{
+			desc: "advertisement blackhole overriding the pool one",
+			pool: addressPool{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+				BlackholeCommunity: "65535:666",
+				BGPAdvertisements: []bgpAdvertisement{
+					{Communities: []string{"64512:1"}, BlackholeCommunity: "64512:666"},
+				}},
+			expected: [][]string{{"64512:1", "64512:666"}},
+		},
+		{
+			desc: "blackhole already among the communities",
+			pool: addressPool{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{
+					{Communities: []string{"blackhole"}, BlackholeCommunity: "65535:666"},
+				}},
+			expected: [][]string{{"65535:666"}},
+		},
+		{
+			desc: "malformed pool blackhole",
+			pool: addressPool{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+				BlackholeCommunity: "65535:666:1:2"},
+			expectedErr: true,
+		},
+		{
+			desc: "malformed advertisement blackhole",
+			pool: addressPool{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{{BlackholeCommunity: "blackhole-alias"}}},
+			expectedErr: true,
+		},
+		{
+			desc: "blackhole on a layer2 pool",
+			pool: addressPool{Name: "pool", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"},
+				BlackholeCommunity: "65535:666"},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &configFile{
+				BGPCommunities: map[string]string{"bar": "64512:2"},
+				Pools:          []addressPool{test.pool},
+			}
+			r, err := resourcesFor(c, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			communities := [][]string{}
+			for _, adv := range r.BGPAdvs {
+				communities = append(communities, adv.Spec.Communities)
+			}
+			if !cmp.Equal(test.expected, communities) {
+				t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff(test.expected, communities))
+			}
+		})
+	}
+}
+
+func TestWellKnownCommunitiesShadowing(t *testing.T) {
+	tests := []struct {
+		desc        string
+		value       string
+		allow       bool
+		expectedErr bool
+	}{
+		{desc: "same value", value: "65535:65281"},
+		{desc: "same value in hex", value: "0xFFFF:0xFF01"},
+		{desc: "different value", value: "64512:1", expectedErr: true},
+		{desc: "different value allowed", value: "64512:1", allow: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.allowShadowing = test.allow
+			c := &configFile{
+				BGPCommunities: map[string]string{"no-export": test.value},
+				Pools: []addressPool{
+					{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+						BGPAdvertisements: []bgpAdvertisement{{Communities: []string{"no-export"}}}},
+				},
+			}
+			r, err := resourcesFor(c, opts)
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			// the user defined alias takes precedence, and is rendered as is.
+			if !cmp.Equal([]string{"no-export"}, r.BGPAdvs[0].Spec.Communities) {
+				t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff([]string{"no-export"}, r.BGPAdvs[0].Spec.Communities))
+			}
+		})
+	}
+}
+
+func TestLargeCommunities(t *testing.T) {
+	c := &configFile{
+		BGPCommunities: map[string]string{
+			"no-export":   "65535:65281",
+			"large-alias": "64512:1:2",
+		},
+		Pools: []addressPool{
+			{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{{Communities: []string{"large:64512:3:4", "64512:1:1", "large-alias", "no-export", "65000:100"}}}},
+		},
+	}
+	advs, err := bgpAdvertisementsFor(c, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// classic communities are compared as large ones, see community.BGPCommunity.LessThan.
+	expected := []string{"large:64512:1:1", "large-alias", "large:64512:3:4", "65000:100", "no-export"}
+	if !cmp.Equal(expected, advs[0].Spec.Communities) {
+		t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff(expected, advs[0].Spec.Communities))
+	}
+
+	communities, err := communitiesFor(c, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expectedAliases := []v1beta1.CommunityAlias{
+		{Name: "large-alias", Value: "large:64512:1:2"},
+		{Name: "no-export", Value: "65535:65281"},
+	}
+	if !cmp.Equal(expectedAliases, communities[0].Spec.Communities) {
+		t.Fatalf("unexpected aliases (-want +got)\n%s", cmp.Diff(expectedAliases, communities[0].Spec.Communities))
+	}
+}
+
+func TestHexCommunities(t *testing.T) {
+	tests := []struct {
+		desc        string
+		value       string
+		expected    string
+		expectedErr bool
+	}{
+		{desc: "decimal", value: "65000:100", expected: "65000:100"},
+		{desc: "hex", value: "0xFDE8:0x64", expected: "65000:100"},
+		{desc: "uppercase prefix", value: "0XFDE8:0X64", expected: "65000:100"},
+		{desc: "mixed", value: "0xfde8:100", expected: "65000:100"},
+		{desc: "large hex", value: "0xFDE8:0x1:0x2", expected: "large:65000:1:2"},
+		{desc: "large prefixed hex", value: "large:65000:0x1:2", expected: "large:65000:1:2"},
+		{desc: "invalid hex", value: "0xFDEZ:0x64", expectedErr: true},
+		{desc: "hex out of range", value: "0x10000:0x64", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &configFile{
+				BGPCommunities: map[string]string{"alias": test.value},
+				Pools: []addressPool{
+					{Name: "pool", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+						BGPAdvertisements: []bgpAdvertisement{{Communities: []string{test.value}}}},
+				},
+			}
+			communities, err := communitiesFor(c, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				_, err := ipAddressPoolsFor(c, defaultOptions())
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if value := communities[0].Spec.Communities[0].Value; value != test.expected {
+				t.Fatalf("expected alias value %q, got %q", test.expected, value)
+			}
+			advs, err := bgpAdvertisementsFor(c, defaultOptions())
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal([]string{test.expected}, advs[0].Spec.Communities) {
+				t.Fatalf("unexpected communities (-want +got)\n%s", cmp.Diff([]string{test.expected}, advs[0].Spec.Communities))
+			}
+		})
+	}
+}
+
+func TestCommunityAliasValues(t *testing.T) {
+	tests := []struct {
+		desc        string
+		value       string
+		expectedErr bool
+	}{
+		{desc: "classic", value: "64512:1234"},
+		{desc: "large", value: "64512:1:2"},
+		{desc: "large with prefix", value: "large:64512:1:2"},
+		{desc: "malformed", value: "64512", expectedErr: true},
+		{desc: "out of range", value: "70000:1", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			communities, err := communitiesFor(&configFile{BGPCommunities: map[string]string{"alias": test.value}}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				if !strings.Contains(err.Error(), "alias alias") {
+					t.Fatalf("expected the error to name the alias, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(communities) != 1 || len(communities[0].Spec.Communities) != 1 {
+				t.Fatalf("expected one community alias, got %v", communities)
+			}
+		})
+	}
+}
+
+func TestMergeAdvertisements(t *testing.T) {
+	aggregationLength := int32(32)
+	c := &configFile{
+		BGPCommunities: map[string]string{"bar": "64512:1234"},
+		Pools: []addressPool{
+			{Name: "pool1", Protocol: BGP, Addresses: []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{
+					{AggregationLength: &aggregationLength, LocalPref: 100, Communities: []string{"bar", "64512:1"}},
+					{AggregationLength: &aggregationLength, LocalPref: 100, Communities: []string{"64512:2", "bar"}},
+					{AggregationLength: &aggregationLength, LocalPref: 200},
+				}},
+			{Name: "pool2", Protocol: BGP, Addresses: []string{"192.168.2.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{
+					{LocalPref: 100, Communities: []string{"64512:3"}},
+					{LocalPref: 100, Communities: []string{"64512:3"}},
+				}},
+		},
+	}
+
+	opts := defaultOptions()
+	advs, err := bgpAdvertisementsFor(c, opts)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(advs) != 5 {
+		t.Fatalf("expected 5 advertisements without merging, got %d", len(advs))
+	}
+
+	opts.mergeAdvertisements = true
+	advs, err = bgpAdvertisementsFor(c, opts)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	type mergedAdv struct {
+		name        string
+		localPref   uint32
+		communities []string
+	}
+	got := []mergedAdv{}
+	for _, adv := range advs {
+		got = append(got, mergedAdv{name: adv.Name, localPref: adv.Spec.LocalPref, communities: adv.Spec.Communities})
+	}
+	expected := []mergedAdv{
+		{name: "pool1-bgp-0", localPref: 100, communities: []string{"64512:1", "64512:2", "bar"}},
+		{name: "pool1-bgp-2", localPref: 200, communities: []string{}},
+		{name: "pool2-bgp-0", localPref: 100, communities: []string{"64512:3"}},
+	}
+	if !cmp.Equal(expected, got, cmp.AllowUnexported(mergedAdv{})) {
+		t.Fatalf("unexpected advertisements (-want +got)\n%s", cmp.Diff(expected, got, cmp.AllowUnexported(mergedAdv{})))
+	}
+}
+
+func TestPoolBGPAndLayer2(t *testing.T) {
+	tests := []struct {
+		desc        string
+		pool        addressPool
+		expectedBGP []string
+		expectedL2  []string
+		expectedErr bool
+	}{
+		{
+			desc:        "default bgp advertisement",
+			pool:        addressPool{Name: "pool", Protocol: BGPAndLayer2, Addresses: []string{"192.168.1.0/24"}},
+			expectedBGP: []string{"pool-bgp-0"},
+			expectedL2:  []string{"pool-l2-0"},
+		},
+		{
+			desc: "explicit bgp advertisements and layer2 attributes",
+			pool: addressPool{Name: "pool", Protocol: BGPAndLayer2, Addresses: []string{"192.168.1.0/24"},
+				BGPAdvertisements: []bgpAdvertisement{{LocalPref: 100}, {LocalPref: 200}},
+				Interfaces:        []string{"eth0"}, NodeSelection: config.NodeSelectionLeader},
+			expectedBGP: []string{"pool-bgp-0", "pool-bgp-1"},
+			expectedL2:  []string{"pool-l2-0"},
+		},
+		{
+			desc:        "no bgp advertisement",
+			pool:        addressPool{Name: "pool", Protocol: BGPAndLayer2, Addresses: []string{"192.168.1.0/24"}, SkipDefaultAdv: true},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r, err := resourcesFor(&configFile{Pools: []addressPool{test.pool}}, defaultOptions())
+			if test.expectedErr {
+				assertValidationError(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			bgpAdvs := []string{}
+			for _, adv := range r.BGPAdvs {
+				bgpAdvs = append(bgpAdvs, adv.Name)
+			}
+			l2Advs := []string{}
+			for _, adv := range r.L2Advs {
+				l2Advs = append(l2Advs, adv.Name)
+			}
+			if !cmp.Equal(test.expectedBGP, bgpAdvs) {
+				t.Fatalf("unexpected bgp advertisements (-want +got)\n%s", cmp.Diff(test.expectedBGP, bgpAdvs))
+			}
+			if !cmp.Equal(test.expectedL2, l2Advs) {
+				t.Fatalf("unexpected l2 advertisements (-want +got)\n%s", cmp.Diff(test.expectedL2, l2Advs))
+			}
+			if err := config.ValidateResources(r, config.DontValidate); err != nil {
+				t.Fatalf("unexpected error validating the resources %v", err)
+			}
+		})
+	}
+}
+
+func TestSkipDefaultAdv(t *testing.T) {
+	pools := []addressPool{
+		{Name: "default", Protocol: BGP, Addresses: []string{"192.168.1.0/24"}},
+		{Name: "skipped", Protocol: BGP, Addresses: []string{"192.168.2.0/24"}, SkipDefaultAdv: true},
+		{Name: "explicit", Protocol: BGP, Addresses: []string{"192.168.3.0/24"}, SkipDefaultAdv: true,
+			BGPAdvertisements: []bgpAdvertisement{{LocalPref: 100}}},
+	}
+	advs, err := bgpAdvertisementsFor(&configFile{Pools: pools}, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	names := []string{}
+	for _, adv := range advs {
+		names = append(names, adv.Name)
+	}
+	expected := []string{"default-bgp-0", "explicit-bgp-0"}
+	if !cmp.Equal(expected, names) {
+		t.Fatalf("unexpected advertisements (-want +got)\n%s", cmp.Diff(expected, names))
+	}
+}
+
+func TestResourcesSortedByName(t *testing.T) {
+	pools := []addressPool{
+		{Name: "pool-c", Protocol: BGP, Addresses: []string{"192.168.3.0/24"}, BGPAdvertisements: []bgpAdvertisement{{LocalPref: 100}, {LocalPref: 200}}},
+		{Name: "pool-a", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}},
+		{Name: "pool-b", Protocol: BGP, Addresses: []string{"192.168.2.0/24"}},
+		{Name: "pool-d", Protocol: Layer2, Addresses: []string{"192.168.4.0/24"}},
+	}
+	profiles := []bfdProfile{{Name: "profile-b"}, {Name: "profile-a"}}
+	peers := []peer{}
+	for i := 1; i <= 11; i++ {
+		peers = append(peers, peer{MyASN: 42, ASN: 142, Addr: fmt.Sprintf("1.2.3.%d", i)})
+	}
+	configFor := func(pools []addressPool, profiles []bfdProfile) *configFile {
+		return &configFile{
+			Peers:          peers,
+			BGPCommunities: map[string]string{"b": "65000:2", "a": "65000:1"},
+			Pools:          pools,
+			BFDProfiles:    profiles,
+		}
+	}
+
+	res, err := resourcesFor(configFor(pools, profiles), defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	reversedPools := make([]addressPool, 0, len(pools))
+	for i := len(pools) - 1; i >= 0; i-- {
+		reversedPools = append(reversedPools, pools[i])
+	}
+	reversedRes, err := resourcesFor(configFor(reversedPools, []bfdProfile{profiles[1], profiles[0]}), defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !cmp.Equal(res, reversedRes) {
+		t.Fatalf("resources depend on the order of the input (-want +got)\n%s", cmp.Diff(res, reversedRes))
+	}
+
+	names := func(n int, name func(int) string) []string {
+		res := []string{}
+		for i := 0; i < n; i++ {
+			res = append(res, name(i))
+		}
+		return res
+	}
+	sorted := map[string][]string{
+		"pools":       names(len(res.Pools), func(i int) string { return res.Pools[i].Name }),
+		"bgpadvs":     names(len(res.BGPAdvs), func(i int) string { return res.BGPAdvs[i].Name }),
+		"l2advs":      names(len(res.L2Advs), func(i int) string { return res.L2Advs[i].Name }),
+		"profiles":    names(len(res.BFDProfiles), func(i int) string { return res.BFDProfiles[i].Name }),
+		"communities": names(len(res.Communities), func(i int) string { return res.Communities[i].Name }),
+	}
+	for kind, n := range sorted {
+		if !sort.StringsAreSorted(n) {
+			t.Fatalf("%s are not sorted by name: %v", kind, n)
+		}
+	}
+	expectedAdvs := []string{"pool-b-bgp-0", "pool-c-bgp-0", "pool-c-bgp-1"}
+	if !cmp.Equal(expectedAdvs, sorted["bgpadvs"]) {
+		t.Fatalf("unexpected bgp advertisements (-want +got)\n%s", cmp.Diff(expectedAdvs, sorted["bgpadvs"]))
+	}
+	// the peers are sorted too, in natural order.
+	expectedPeers := names(11, func(i int) string { return fmt.Sprintf("peer%d", i+1) })
+	gotPeers := names(len(res.Peers), func(i int) string { return res.Peers[i].Name })
+	if !cmp.Equal(expectedPeers, gotPeers) {
+		t.Fatalf("unexpected peers (-want +got)\n%s", cmp.Diff(expectedPeers, gotPeers))
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "peer2", b: "peer10", expected: true},
+		{a: "peer10", b: "peer2", expected: false},
+		{a: "peer1", b: "peer1", expected: false},
+		{a: "pool-a", b: "pool-b", expected: true},
+		{a: "pool", b: "pool-a", expected: true},
+		{a: "pool-c-bgp-2", b: "pool-c-bgp-10", expected: true},
+		{a: "a10b2", b: "a10b10", expected: true},
+		{a: "peer01", b: "peer2", expected: true},
+	}
+	for _, test := range tests {
+		if got := naturalLess(test.a, test.b); got != test.expected {
+			t.Fatalf("naturalLess(%q, %q): expected %v, got %v", test.a, test.b, test.expected, got)
+		}
+	}
+}
+
+func TestResourcesNamespace(t *testing.T) {
+	c := &configFile{
+		Peers:          []peer{{MyASN: 42, ASN: 142, Addr: "1.2.3.4", Password: "secret"}},
+		BGPCommunities: map[string]string{"alias": "65000:1"},
+		Pools: []addressPool{
+			{Name: "pool-bgp", Protocol: BGP, Addresses: []string{"192.168.1.0/24"}},
+			{Name: "pool-l2", Protocol: Layer2, Addresses: []string{"192.168.2.0/24"}},
+		},
+		BFDProfiles: []bfdProfile{{Name: "profile"}},
+	}
+	opts := defaultOptions()
+	opts.namespace = "metallb-tenant"
+	opts.passwordSecrets = true
+	r, err := resourcesFor(c, opts)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	objects := resourcesToObjects(r)
+	if len(objects) != 8 {
+		t.Fatalf("expected 8 objects, got %d", len(objects))
+	}
+	for _, o := range objects {
+		obj, ok := o.(metav1.Object)
+		if !ok {
+			t.Fatalf("unexpected object %v", o)
+		}
+		if obj.GetNamespace() != "metallb-tenant" {
+			t.Fatalf("expected %s to be in namespace metallb-tenant, got %q", obj.GetName(), obj.GetNamespace())
+		}
+	}
+	if r.Peers[0].Spec.PasswordSecret.Namespace != "metallb-tenant" {
+		t.Fatalf("expected the password secret reference in namespace metallb-tenant, got %q", r.Peers[0].Spec.PasswordSecret.Namespace)
+	}
+}
+
+func TestResourcesNamePrefix(t *testing.T) {
+	c := &configFile{
+		Peers:          []peer{{MyASN: 42, ASN: 142, Addr: "1.2.3.4", Password: "secret", BFDProfile: "profile"}},
+		BGPCommunities: map[string]string{"alias": "65000:1"},
+		Pools: []addressPool{
+			{Name: "pool-bgp", Protocol: BGP, Addresses: []string{"192.168.1.0/24"}},
+			{Name: "pool-l2", Protocol: Layer2, Addresses: []string{"192.168.2.0/24"}},
+		},
+		BFDProfiles: []bfdProfile{{Name: "profile"}},
+	}
+	opts := defaultOptions()
+	opts.passwordSecrets = true
+	opts.namePrefix = "legacy-"
+	r, err := resourcesFor(c, opts)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	objects := resourcesToObjects(r)
+	if len(objects) != 8 {
+		t.Fatalf("expected 8 objects, got %d", len(objects))
+	}
+	for _, o := range objects {
+		obj, ok := o.(metav1.Object)
+		if !ok {
+			t.Fatalf("unexpected object %v", o)
+		}
+		if !strings.HasPrefix(obj.GetName(), "legacy-") {
+			t.Fatalf("expected %s to be prefixed with legacy-", obj.GetName())
+		}
+	}
+	if _, ok := r.PasswordSecrets["legacy-peer1-bgp-password"]; !ok {
+		t.Fatalf("expected the password secret to be indexed by its prefixed name, got %v", r.PasswordSecrets)
+	}
+	// the references between the resources must follow the prefixed names.
+	if err := config.ValidateResources(r, config.DontValidate); err != nil {
+		t.Fatalf("unexpected error validating the prefixed resources %v", err)
+	}
+	if r.Peers[0].Spec.BFDProfile != "legacy-profile" || r.Peers[0].Spec.PasswordSecret.Name != "legacy-peer1-bgp-password" {
+		t.Fatalf("unexpected references of the peer %+v", r.Peers[0].Spec)
+	}
+
+	// unrelated resources named as the unprefixed ones don't collide, the
+	// ones with the prefixed names are still detected.
+	existing := &config.ClusterResources{
+		Peers: []v1beta2.BGPPeer{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1", Namespace: resourcesNameSpace},
+				Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 150, Address: "1.2.3.5"},
+			},
+		},
+		Pools: []v1beta1.IPAddressPool{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "legacy-pool-bgp", Namespace: resourcesNameSpace},
+				Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.0.0.0/24"}},
+			},
+		},
+	}
+	changes, err := conversion.Diff(existing, &r)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	modified := []string{}
+	for _, c := range changes {
+		if c.Type == conversion.Modified {
+			modified = append(modified, c.Kind+"/"+c.Name)
+		}
+	}
+	if !cmp.Equal([]string{"IPAddressPool/legacy-pool-bgp"}, modified) {
+		t.Fatalf("unexpected colliding resources (-want +got)\n%s", cmp.Diff([]string{"IPAddressPool/legacy-pool-bgp"}, modified))
+	}
+
+	opts.namePrefix = "Legacy_"
+	_, err = resourcesFor(c, opts)
+	assertValidationError(t, err)
+}
+
+func TestResourcesNameCollisions(t *testing.T) {
+	tests := []struct {
+		desc         string
+		pools        []addressPool
+		prefix       string
+		expectedName string
+	}{
+		{
+			desc: "distinct names",
+			pools: []addressPool{
+				{Name: "pool-a", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}},
+				{Name: "pool-b", Protocol: Layer2, Addresses: []string{"192.168.2.0/24"}},
+			},
+			prefix: "legacy-",
+		},
+		{
+			desc: "names converted to the same one",
+			pools: []addressPool{
+				{Name: "pool_a", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}},
+				{Name: "pool-a", Protocol: Layer2, Addresses: []string{"192.168.2.0/24"}},
+			},
+			expectedName: "pool-a",
+		},
+		{
+			desc: "names converted to the same one with a prefix",
+			pools: []addressPool{
+				{Name: "Pool-A", Protocol: Layer2, Addresses: []string{"192.168.1.0/24"}},
+				{Name: "pool-a", Protocol: Layer2, Addresses: []string{"192.168.2.0/24"}},
+			},
+			prefix:       "legacy-",
+			expectedName: "legacy-pool-a",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &configFile{Pools: test.pools}
+			if err := convertNamesToK8S(c); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			opts := defaultOptions()
+			opts.namePrefix = test.prefix
+			_, err := resourcesFor(c, opts)
+			if test.expectedName == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if convErr := assertValidationError(t, err); convErr.Name != test.expectedName {
+				t.Fatalf("expected the error for %s, got %v", test.expectedName, err)
+			}
+			if !strings.Contains(err.Error(), "duplicate IPAddressPool name "+test.expectedName) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeConfigFileNamespace(t *testing.T) {
+	raw := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  namespace: metallb-tenant
+  name: config
+data:
+  config: |
+    address-pools:
+    - name: pool
+      protocol: layer2
+      addresses:
+      - 192.168.1.0/24
+`)
+	cf, err := decodeConfigFile(raw, defaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if cf.namespace != "metallb-tenant" {
+		t.Fatalf("expected namespace metallb-tenant, got %q", cf.namespace)
+	}
+	if resourcesNameSpace != "metallb-system" {
+		t.Fatalf("the default namespace was changed to %q", resourcesNameSpace)
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	data := []byte(`peers:
+- my-asn: 64512
+  peer-asn: 64512
+  peer-address: 10.96.0.100
+address-pool:
+- name: my-ip-space
+  protocol: layer2
+  addresses:
+  - 198.51.100.0/24
+`)
+	tests := []struct {
+		desc        string
+		strict      bool
+		expectedErr bool
+	}{
+		{desc: "tolerant", strict: false},
+		{desc: "strict", strict: true, expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.onlyData = true
+			opts.strict = test.strict
+			cf, err := decodeConfigFile(data, opts)
+			if test.expectedErr {
+				var convErr *config.ConversionError
+				if !errors.As(err, &convErr) || convErr.Kind != config.ParseError || convErr.Name != "address-pool" {
+					t.Fatalf("expected a parse error for address-pool, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(cf.Peers) != 1 || len(cf.Pools) != 0 {
+				t.Fatalf("expected 1 peer and no pools, got %d peers and %d pools", len(cf.Peers), len(cf.Pools))
+			}
+		})
+	}
+
+	_, warnings := LintLegacy(corev1.ConfigMap{Data: map[string]string{"config": string(data)}})
+	expected := []string{"unknown top level keys address-pool are ignored"}
+	if !cmp.Equal(expected, warnings) {
+		t.Fatalf("unexpected lint warnings (-want +got)\n%s", cmp.Diff(expected, warnings))
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// LintLegacy runs the parsing and validation of the legacy configuration held
+// by the given ConfigMap without building the custom resources. Differently
+// from the conversion, it doesn't stop at the first issue and returns all
+// the errors and the warnings found.
+func LintLegacy(cm corev1.ConfigMap) (errs, warnings []string) {
+	data := cm.Data["config"]
+	if data == "" {
+		return []string{"bad ConfigMap: no data"}, nil
+	}
+	cf := &configFile{}
+	if err := yaml.Unmarshal([]byte(data), cf); err != nil {
+		return []string{fmt.Sprintf("failed to decode the configuration: %s", err)}, nil
+	}
+	cf.annotations = cm.Annotations
+	if unknown, err := unknownTopLevelKeys([]byte(data)); err == nil && len(unknown) > 0 {
+		warnings = append(warnings, fmt.Sprintf("unknown top level keys %s are ignored", strings.Join(unknown, ", ")))
+	}
+
+	opts := defaultOptions()
+	addError := func(element string, err error) {
+		errs = append(errs, fmt.Sprintf("%s: %s", element, err))
+	}
+
+	bfdValidations := bfdProfileValidations()
+	for _, bfd := range cf.BFDProfiles {
+		for _, validate := range bfdValidations {
+			if err := validate(bfd); err != nil {
+				addError(bfd.Name, err)
+			}
+		}
+	}
+	if err := validateDefaultBFDProfile(cf, opts.defaultBFDProfile); err != nil {
+		addError("default-bfd-profile", err)
+	}
+	for i, p := range cf.Peers {
+		name := fmt.Sprintf("peer%d", i+1)
+		for _, err := range lintPeer(cf, p, opts) {
+			addError(name, err)
+		}
+		if w := privateASNWarning(p); w != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", name, w))
+		}
+	}
+
+	aliases := make([]string, 0, len(cf.BGPCommunities))
+	for alias := range cf.BGPCommunities {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		if err := validateCommunityAlias(alias, cf.BGPCommunities[alias], opts.allowShadowing); err != nil {
+			addError("bgp-communities", err)
+		}
+	}
+	if cf.DefaultCommunity != "" {
+		if err := validateCommunity(cf, cf.DefaultCommunity, "default-community", cf.DefaultCommunity); err != nil {
+			addError("default-community", err)
+		}
+	}
+	if err := validateNamespaceCommunities(cf); err != nil {
+		addError("namespace-communities", err)
+	}
+
+	for _, ap := range cf.Pools {
+		for _, err := range lintPool(cf, ap) {
+			addError(ap.Name, err)
+		}
+		if w := unknownProtocolWarning(ap); w != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", ap.Name, w))
+		}
+	}
+	// the references to the communities are checked on the generated
+	// resources, as the conversion does. The errors building them are the
+	// ones already reported above, the communities being validated once.
+	advs, advsErr := buildBGPAdvertisements(cf, opts)
+	if communities, err := communitiesFor(cf, opts); err == nil && advsErr == nil {
+		if err := validateCommunityRefs(advs, communities); err != nil {
+			addError("bgp-advertisements", err)
+		}
+	}
+
+	// the warnings are recorded by the builders, so they are collected
+	// only after all of them ran.
+	for _, w := range cf.warnings {
+		warnings = append(warnings, fmt.Sprintf("%s: %s", w.Element, w.Message))
+	}
+
+	return errs, warnings
+}
+
+// lintPeer runs all the checks of the peer, with the default bfd profile
+// applied as the conversion does.
+func lintPeer(c *configFile, p peer, opts options) []error {
+	if p.BFDProfile == "" {
+		p.BFDProfile = opts.defaultBFDProfile
+	}
+	errs := []error{}
+	for _, validate := range peerValidations(c, opts) {
+		if err := validate(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// lintPool runs all the checks of the pool, with the addresses of the
+// external block it references, if any, as the conversion does.
+func lintPool(c *configFile, ap addressPool) []error {
+	errs := []error{}
+	if ap.ExternalBlock != "" {
+		addrs, err := externalBlockAddresses(c, ap)
+		if err != nil {
+			return append(errs, err)
+		}
+		ap.Addresses = addrs
+	}
+	for _, validate := range poolValidations(c) {
+		if err := validate(ap); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -3,28 +3,38 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math/big"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/mikioh/ipaddr"
 	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/bgp/community"
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/version"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/yaml"
 )
 
@@ -32,14 +42,84 @@ const (
 	separator      = "---\n"
 	autoGenComment = "# This was autogenerated by MetalLB's custom resource generator.\n"
 	outputFileName = "resources.yaml"
+
+	// kustomizationFileName is the name of the kustomization listing the
+	// files generated with the kustomize output format.
+	kustomizationFileName = "kustomization.yaml"
+
+	// the output formats: a single file with all the resources, or a
+	// kustomize base with a file for each kind.
+	outputSingle    = "single"
+	outputKustomize = "kustomize"
+
+	// deriveAggregationLengthV6Annotation is the ConfigMap annotation to opt-in
+	// the derivation of the IPv6 aggregation length from the IPv4 one for
+	// dual-stack pools.
+	deriveAggregationLengthV6Annotation = "metallb.universe.tf/derive-aggregation-length-v6"
+
+	// gracefulShutdownCommunity is the well-known GRACEFUL_SHUTDOWN community
+	// of RFC 8326, making the routers receiving the routes deprioritize them.
+	gracefulShutdownCommunity = "65535:0"
+
+	// externalBlocksAnnotation is the ConfigMap annotation carrying the
+	// address blocks handed out by an external IPAM, as a map of block names
+	// to their ranges, that the pools can reference with external-block.
+	externalBlocksAnnotation = "metallb.universe.tf/external-blocks"
+
+	// bgpRoleAnnotation is the BGPPeer annotation carrying the BGP role
+	// of the session, as defined by RFC 9234.
+	bgpRoleAnnotation = "metallb.universe.tf/bgp-role"
+
+	// tcpMSSAnnotation is the BGPPeer annotation carrying the TCP MSS
+	// to clamp the session to.
+	tcpMSSAnnotation = "metallb.universe.tf/tcp-mss"
+
+	// disableDefaultVRFAnnotation is the BGPPeer annotation marking a session
+	// that must be established only in its VRF, never in the default one.
+	disableDefaultVRFAnnotation = "metallb.universe.tf/disable-default-vrf"
+
+	// dscpAnnotation and tosAnnotation are the IPAddressPool annotations
+	// carrying the QoS marking hints of the pool.
+	dscpAnnotation = "metallb.universe.tf/dscp"
+	tosAnnotation  = "metallb.universe.tf/tos"
 )
 
+// wellKnownCommunities are the well-known communities that can be used by
+// name in place of their value, see RFC 1997, RFC 3765, RFC 7999 and RFC 8326.
+var wellKnownCommunities = map[string]string{
+	"graceful-shutdown":   gracefulShutdownCommunity,
+	"blackhole":           "65535:666",
+	"no-export":           "65535:65281",
+	"no-advertise":        "65535:65282",
+	"no-export-subconfed": "65535:65283",
+	"no-peer":             "65535:65284",
+}
+
+// defaultPeerHoldTime is the hold time of the peers not setting it, unless
+// overridden by -default-hold-time.
+const defaultPeerHoldTime = 90 * time.Second
+
+// bgpRoles are the BGP roles defined by RFC 9234.
+var bgpRoles = []string{"provider", "rs-server", "rs-client", "customer", "peer"}
+
 var (
 	resourcesNameSpace = "metallb-system"
 	inputDirPath       = "/var/input"
 	source             = flag.String("source", "./config.yaml", "name of the configmap file to convert")
 	onlyData           = flag.Bool("only-data", false, "set this to true if the input file contains only the ConfigMap's data field")
 	stdout             = flag.Bool("stdout", false, "set this to true to write to stdout")
+	passwordSecrets    = flag.Bool("password-secrets", false, "set this to true to store the peers passwords in secrets referenced by the BGPPeers")
+	labelsToSet        = flag.String("labels", "", "comma separated key=value labels to set on all the generated resources, e.g. app.kubernetes.io/managed-by=metallb-conversion")
+	mergeAdvs          = flag.Bool("merge-advertisements", false, "set this to true to merge the bgp advertisements of the same pool differing only by their communities")
+	allowShadowing     = flag.Bool("allow-community-shadowing", false, "set this to true to allow the bgp-communities aliases named as a well-known community, e.g. no-export, to have a different value")
+	localSubnets       = flag.String("local-subnets", "", "comma separated CIDRs of the subnets of the speakers, used to check that the source address of a directly connected peer is in the subnet of the peer address")
+	defaultBFDProfile  = flag.String("default-bfd-profile", "", "name of the bfd profile, among the bfd-profiles, to set on the peers not referencing one")
+	namePrefix         = flag.String("name-prefix", "", "prefix to add to the names of all the generated resources, e.g. legacy- to name the peers legacy-peer1, legacy-peer2 and so on")
+	mergeCIDRs         = flag.Bool("merge-cidrs", false, "set this to true to merge the adjacent CIDRs of each pool, e.g. 192.0.2.0/25 and 192.0.2.128/25 into 192.0.2.0/24")
+	defaultHoldTime    = flag.Duration("default-hold-time", defaultPeerHoldTime, "hold time of the peers not setting it, must be 0 or >=3s")
+	strictDurations    = flag.Bool("strict-durations", false, "set this to true to fail the conversion when a hold, keepalive or connect time has sub-second precision, instead of truncating it to seconds")
+	strict             = flag.Bool("strict", false, "set this to true to fail the conversion when the configuration has unknown top level keys")
+	outputFormat       = flag.String("output-format", outputSingle, "format of the output, single to write all the resources to resources.yaml or kustomize to write a file per kind and a kustomization.yaml listing them")
 )
 
 func main() {
@@ -49,6 +129,21 @@ func main() {
 	log.Printf("MetalLB generator starting. commit: %s branch: %s goversion: %s",
 		version.CommitHash(), version.Branch(), version.GoString())
 
+	switch *outputFormat {
+	case outputSingle:
+	case outputKustomize:
+		if *stdout {
+			log.Fatalf("the %s output format writes multiple files and can't be used with -stdout", outputKustomize)
+		}
+		err = generateKustomize(inputDirPath, *source)
+		if err != nil {
+			log.Printf("failed to generate resources: %s", err)
+		}
+		return
+	default:
+		log.Fatalf("unknown output format %q, must be %s or %s", *outputFormat, outputSingle, outputKustomize)
+	}
+
 	if *stdout {
 		f = os.Stdout
 	} else {
@@ -72,47 +167,81 @@ func main() {
 // generate gets a name of a metallb configmap file, converts it to
 // the matching metallb custom resources yamls, and returns it as a string.
 func generate(w io.Writer, origin string) error {
-	log.Println("Reading configmap")
-	raw, err := readConfig(origin)
+	resources, err := convert(origin)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Decoding configmap")
-	cf, err := decodeConfigFile(raw)
+	log.Println("Creating the output YAML")
+	_, err = w.Write([]byte(autoGenComment))
 	if err != nil {
 		return err
 	}
-
-	log.Println("Converting configmap resources to K8S-compliant names")
-	err = convertNamesToK8S(cf)
+	err = createResourcesYAMLs(w, resources)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Creating custom resources")
-	resources, err := resourcesFor(cf)
+	return nil
+}
+
+// generateKustomize gets a name of a metallb configmap file, converts it
+// to the matching metallb custom resources and writes them to the given
+// directory as a kustomize base, with a file for each kind.
+func generateKustomize(dir, origin string) error {
+	resources, err := convert(origin)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Checking the resources are parsed correctly")
-	_, err = config.For(resources, config.DontValidate)
+	log.Println("Creating the kustomize base")
+	return createKustomization(dir, resources)
+}
+
+// convert reads the metallb configmap file with the given name and
+// converts it to the matching metallb custom resources.
+func convert(origin string) (config.ClusterResources, error) {
+	log.Println("Reading configmap")
+	raw, err := readConfig(origin)
 	if err != nil {
-		return err
+		return config.ClusterResources{}, err
 	}
 
-	log.Println("Creating the output YAML")
-	_, err = w.Write([]byte(autoGenComment))
+	opts, err := optionsFromFlags()
 	if err != nil {
-		return err
+		return config.ClusterResources{}, err
 	}
-	err = createResourcesYAMLs(w, resources)
+
+	log.Println("Decoding configmap")
+	cf, err := decodeConfigFile(raw, opts)
 	if err != nil {
-		return err
+		return config.ClusterResources{}, err
 	}
 
-	return nil
+	log.Println("Converting configmap resources to K8S-compliant names")
+	err = convertNamesToK8S(cf)
+	if err != nil {
+		return config.ClusterResources{}, err
+	}
+
+	// the resources are created in the namespace of the ConfigMap, if known.
+	if cf.namespace != "" {
+		opts.namespace = cf.namespace
+	}
+
+	log.Println("Creating custom resources")
+	resources, err := resourcesFor(cf, opts)
+	if err != nil {
+		return config.ClusterResources{}, err
+	}
+
+	log.Println("Checking the resources are parsed correctly")
+	err = config.ValidateResources(resources, config.DontValidate)
+	if err != nil {
+		return config.ClusterResources{}, err
+	}
+
+	return resources, nil
 }
 
 func readConfig(origin string) ([]byte, error) {
@@ -140,22 +269,120 @@ func readConfig(origin string) ([]byte, error) {
 }
 
 // decodeConfigFile gets metallb configmap raw bytes and decodes it into
-// a configFile object.
-func decodeConfigFile(raw []byte) (*configFile, error) {
-	data, err := getConfigMapData(raw)
+// a configFile object, according to the onlyData and strict options.
+func decodeConfigFile(raw []byte, opts options) (*configFile, error) {
+	data, meta, err := getConfigMapData(raw, opts.onlyData)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.strict {
+		unknown, err := unknownTopLevelKeys(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(unknown) > 0 {
+			return nil, &config.ConversionError{
+				Kind:   config.ParseError,
+				Name:   unknown[0],
+				Reason: fmt.Sprintf("unknown top level keys %s", strings.Join(unknown, ", ")),
+			}
+		}
+	}
+
 	cf := &configFile{}
 	err = yaml.Unmarshal(data, cf)
 	if err != nil {
 		return nil, err
 	}
+	cf.annotations = meta.Annotations
+	cf.namespace = meta.Namespace
 
 	return cf, nil
 }
 
+// unknownTopLevelKeys returns the sorted top level keys of the configuration
+// not matching any field of the configFile, usually because of typos such as
+// address-pool in place of address-pools, which are otherwise ignored.
+func unknownTopLevelKeys(data []byte) ([]string, error) {
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	t := reflect.TypeOf(configFile{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		known[name] = true
+	}
+	res := []string{}
+	for k := range raw {
+		if !known[k] {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// options are the settings of the conversion of a configFile to resources.
+type options struct {
+	onlyData            bool
+	strict              bool
+	namespace           string
+	labels              map[string]string
+	namePrefix          string
+	passwordSecrets     bool
+	mergeAdvertisements bool
+	mergeCIDRs          bool
+	allowShadowing      bool
+	strictDurations     bool
+	defaultHoldTime     time.Duration
+	defaultBFDProfile   string
+	localSubnets        []*net.IPNet
+}
+
+// defaultOptions returns the options matching the defaults of the flags.
+func defaultOptions() options {
+	return options{
+		namespace:       resourcesNameSpace,
+		defaultHoldTime: defaultPeerHoldTime,
+	}
+}
+
+// optionsFromFlags returns the options set by the command line flags.
+func optionsFromFlags() (options, error) {
+	labels, err := parseLabels(*labelsToSet)
+	if err != nil {
+		return options{}, err
+	}
+	subnets, err := parseLocalSubnets(*localSubnets)
+	if err != nil {
+		return options{}, err
+	}
+	opts := defaultOptions()
+	opts.onlyData = *onlyData
+	opts.strict = *strict
+	opts.labels = labels
+	opts.namePrefix = *namePrefix
+	opts.passwordSecrets = *passwordSecrets
+	opts.mergeAdvertisements = *mergeAdvs
+	opts.mergeCIDRs = *mergeCIDRs
+	opts.allowShadowing = *allowShadowing
+	opts.strictDurations = *strictDurations
+	opts.defaultHoldTime = *defaultHoldTime
+	opts.defaultBFDProfile = *defaultBFDProfile
+	opts.localSubnets = subnets
+	return opts, nil
+}
+
 // convertNamesToK8S gets a configFile object and converts all names
 // in it to names compatible with K8S resources, if necessary.
 func convertNamesToK8S(cf *configFile) error {
@@ -218,7 +445,11 @@ func formatToK8S(name string, kind string) (string, error) {
 	firstLetterRegex := regexp.MustCompile("[a-z]")
 	firstLetter := firstLetterRegex.FindStringIndex(noUnderscore)
 	if len(firstLetter) == 0 {
-		return "", fmt.Errorf("failed to make %s K8S compatible: %s", kind, name)
+		return "", &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   name,
+			Reason: fmt.Sprintf("failed to make %s K8S compatible: %s", kind, name),
+		}
 	}
 	final := noUnderscore[firstLetter[0]:]
 
@@ -231,23 +462,24 @@ func formatToK8S(name string, kind string) (string, error) {
 }
 
 // getConfigMapData gets raw bytes representing a ConfigMap and returns the
-// data of the configmap.
-func getConfigMapData(raw []byte) ([]byte, error) {
-	if *onlyData {
-		return raw, nil
+// data and the metadata of the configmap. When onlyData is set, the raw bytes
+// are the data field alone.
+func getConfigMapData(raw []byte, onlyData bool) ([]byte, metav1.ObjectMeta, error) {
+	if onlyData {
+		return raw, metav1.ObjectMeta{}, nil
 	}
 
 	cm, err := parseConfigMap(raw)
 	if err != nil {
-		return nil, err
+		return nil, metav1.ObjectMeta{}, err
 	}
 
 	data := []byte(cm.Data["config"])
 	if len(data) == 0 {
-		return nil, fmt.Errorf("bad ConfigMap: no data")
+		return nil, metav1.ObjectMeta{}, fmt.Errorf("bad ConfigMap: no data")
 	}
 
-	return data, nil
+	return data, cm.ObjectMeta, nil
 }
 
 // parseConfigMap gets raw bytes representing a ConfigMap, parse it
@@ -270,60 +502,406 @@ func parseConfigMap(raw []byte) (*corev1.ConfigMap, error) {
 		return nil, fmt.Errorf("not a configmap")
 	}
 
-	// set the namespace for the resources
-	if config.Namespace != "" {
-		resourcesNameSpace = config.Namespace
+	return config, nil
+}
+
+// resourcesFor builds the resources matching the legacy configuration in the
+// given namespace, setting the given labels on all of them. The warnings found
+// are logged.
+func resourcesFor(cf *configFile, opts options) (config.ClusterResources, error) {
+	r, warnings, err := resourcesWithWarningsFor(cf, opts)
+	for _, w := range warnings {
+		log.Printf("Warning: %s", w.Message)
 	}
+	return r, err
+}
 
-	return config, nil
+// resourcesWithWarningsFor is like resourcesFor, but returns the warnings found
+// instead of logging them. On error, the warnings found up to it are returned.
+func resourcesWithWarningsFor(cf *configFile, opts options) (config.ClusterResources, []Warning, error) {
+	cf.warnings = nil
+	r, err := buildResources(cf, opts)
+	return r, cf.warnings, err
 }
 
-func resourcesFor(cf *configFile) (config.ClusterResources, error) {
+// buildResources builds the resources, recording the warnings in the
+// configuration.
+func buildResources(cf *configFile, opts options) (config.ClusterResources, error) {
 	var r config.ClusterResources
 	var err error
 
-	r.BFDProfiles = bfdProfileFor(cf)
-	r.Communities = communitiesFor(cf)
-	r.Peers, err = peersFor(cf)
+	r.BFDProfiles, err = bfdProfileFor(cf, opts.namespace)
+	if err != nil {
+		return config.ClusterResources{}, err
+	}
+	r.Communities, err = communitiesFor(cf, opts)
+	if err != nil {
+		return config.ClusterResources{}, err
+	}
+	r.Peers, r.PasswordSecrets, err = peersFor(cf, opts)
 	if err != nil {
 		return config.ClusterResources{}, err
 	}
 
-	r.Pools = ipAddressPoolsFor(cf)
-	r.BGPAdvs = bgpAdvertisementsFor(cf)
-	r.L2Advs = l2AdvertisementsFor(cf)
+	if err := resolveExternalBlocks(cf); err != nil {
+		return config.ClusterResources{}, err
+	}
+	r.Pools, err = ipAddressPoolsFor(cf, opts)
+	if err != nil {
+		return config.ClusterResources{}, err
+	}
+	r.BGPAdvs, err = bgpAdvertisementsFor(cf, opts)
+	if err != nil {
+		return config.ClusterResources{}, err
+	}
+	if err := validateCommunityRefs(r.BGPAdvs, r.Communities); err != nil {
+		return config.ClusterResources{}, err
+	}
+	r.L2Advs, err = l2AdvertisementsFor(cf, opts.namespace)
+	if err != nil {
+		return config.ClusterResources{}, err
+	}
+	for _, pool := range poolsWithoutAdvertisements(r) {
+		cf.warn(pool, "pool %s has no advertisements, its IPs are allocated but not announced", pool)
+	}
+	for _, pool := range unreachablePools(r) {
+		cf.warn(pool, "pool %s has auto-assign disabled and no advertisements, no service can use it", pool)
+	}
+	if err := setNamePrefix(&r, opts.namePrefix); err != nil {
+		return config.ClusterResources{}, err
+	}
+	if err := validateUniqueNames(r); err != nil {
+		return config.ClusterResources{}, err
+	}
+	setCommonLabels(&r, opts.labels)
+	sortResources(&r)
 
 	return r, nil
 }
 
-func bfdProfileFor(c *configFile) []v1beta1.BFDProfile {
+// poolsWithoutAdvertisements returns the names of the generated pools not
+// referenced by any of the generated advertisements, e.g. the BGP pools with
+// skip-default-advertisement and no bgp-advertisements.
+func poolsWithoutAdvertisements(r config.ClusterResources) []string {
+	advertised := map[string]bool{}
+	for _, adv := range r.BGPAdvs {
+		for _, p := range adv.Spec.IPAddressPools {
+			advertised[p] = true
+		}
+	}
+	for _, adv := range r.L2Advs {
+		for _, p := range adv.Spec.IPAddressPools {
+			advertised[p] = true
+		}
+	}
+	res := []string{}
+	for _, p := range r.Pools {
+		if !advertised[p.Name] {
+			res = append(res, p.Name)
+		}
+	}
+	return res
+}
+
+// unreachablePools returns the names of the generated pools with auto-assign
+// disabled and no advertisements: their IPs are assigned only to the services
+// requesting them explicitly, but are never announced, so the pools are of
+// no use.
+func unreachablePools(r config.ClusterResources) []string {
+	withoutAdvertisements := map[string]bool{}
+	for _, p := range poolsWithoutAdvertisements(r) {
+		withoutAdvertisements[p] = true
+	}
+	res := []string{}
+	for _, p := range r.Pools {
+		if p.Spec.AutoAssign != nil && !*p.Spec.AutoAssign && withoutAdvertisements[p.Name] {
+			res = append(res, p.Name)
+		}
+	}
+	return res
+}
+
+// sortResources sorts the resources of each kind by name, so that the output
+// doesn't depend on the order of the elements in the ConfigMap and can be
+// stored in git without spurious diffs. The names are compared in natural order,
+// so that peer2 comes before peer10 and the peers stay in the ConfigMap order.
+func sortResources(r *config.ClusterResources) {
+	sort.SliceStable(r.Peers, func(i, j int) bool { return naturalLess(r.Peers[i].Name, r.Peers[j].Name) })
+	sort.SliceStable(r.BFDProfiles, func(i, j int) bool { return naturalLess(r.BFDProfiles[i].Name, r.BFDProfiles[j].Name) })
+	sort.SliceStable(r.Communities, func(i, j int) bool { return naturalLess(r.Communities[i].Name, r.Communities[j].Name) })
+	sort.SliceStable(r.Pools, func(i, j int) bool { return naturalLess(r.Pools[i].Name, r.Pools[j].Name) })
+	sort.SliceStable(r.BGPAdvs, func(i, j int) bool { return naturalLess(r.BGPAdvs[i].Name, r.BGPAdvs[j].Name) })
+	sort.SliceStable(r.L2Advs, func(i, j int) bool { return naturalLess(r.L2Advs[i].Name, r.L2Advs[j].Name) })
+}
+
+// naturalLess compares the two names comparing the runs of digits by their
+// numeric value, e.g. peer2 is less than peer10.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		digitsA, digitsB := leadingDigits(a), leadingDigits(b)
+		if digitsA == "" || digitsB == "" {
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		numA, numB := strings.TrimLeft(digitsA, "0"), strings.TrimLeft(digitsB, "0")
+		if len(numA) != len(numB) {
+			return len(numA) < len(numB)
+		}
+		if numA != numB {
+			return numA < numB
+		}
+		a, b = a[len(digitsA):], b[len(digitsB):]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// parseLabels parses the labels to be set on the generated resources,
+// expressed as comma separated key=value pairs.
+func parseLabels(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	res, err := labels.ConvertSelectorToLabelsMap(s)
+	if err != nil {
+		return nil, &config.ConversionError{
+			Kind:   config.ParseError,
+			Name:   s,
+			Reason: fmt.Sprintf("invalid labels %q: %s", s, err),
+		}
+	}
+	return res, nil
+}
+
+// setCommonLabels sets the given labels on all the resources, so that the
+// ones generated by a conversion can be selected together. Labels already
+// set on a resource are left untouched.
+func setCommonLabels(r *config.ClusterResources, commonLabels map[string]string) {
+	if len(commonLabels) == 0 {
+		return
+	}
+	for i := range r.Peers {
+		addLabels(&r.Peers[i].ObjectMeta, commonLabels)
+	}
+	for i := range r.BFDProfiles {
+		addLabels(&r.BFDProfiles[i].ObjectMeta, commonLabels)
+	}
+	for i := range r.Communities {
+		addLabels(&r.Communities[i].ObjectMeta, commonLabels)
+	}
+	for i := range r.Pools {
+		addLabels(&r.Pools[i].ObjectMeta, commonLabels)
+	}
+	for i := range r.BGPAdvs {
+		addLabels(&r.BGPAdvs[i].ObjectMeta, commonLabels)
+	}
+	for i := range r.L2Advs {
+		addLabels(&r.L2Advs[i].ObjectMeta, commonLabels)
+	}
+	for name, s := range r.PasswordSecrets {
+		addLabels(&s.ObjectMeta, commonLabels)
+		r.PasswordSecrets[name] = s
+	}
+}
+
+// validateUniqueNames checks that no two generated resources of the same kind
+// share a name, as it happens when different legacy names are converted to
+// the same K8S-compliant one, e.g. pool_a and pool-a, and one would overwrite
+// the other once applied.
+func validateUniqueNames(r config.ClusterResources) error {
+	names := map[string][]string{}
+	for _, p := range r.Peers {
+		names["BGPPeer"] = append(names["BGPPeer"], p.Name)
+	}
+	for _, b := range r.BFDProfiles {
+		names["BFDProfile"] = append(names["BFDProfile"], b.Name)
+	}
+	for _, c := range r.Communities {
+		names["Community"] = append(names["Community"], c.Name)
+	}
+	for _, p := range r.Pools {
+		names["IPAddressPool"] = append(names["IPAddressPool"], p.Name)
+	}
+	for _, a := range r.BGPAdvs {
+		names["BGPAdvertisement"] = append(names["BGPAdvertisement"], a.Name)
+	}
+	for _, a := range r.L2Advs {
+		names["L2Advertisement"] = append(names["L2Advertisement"], a.Name)
+	}
+
+	for _, kind := range []string{"BGPPeer", "BFDProfile", "Community", "IPAddressPool", "BGPAdvertisement", "L2Advertisement"} {
+		seen := map[string]bool{}
+		for _, name := range names[kind] {
+			if seen[name] {
+				return &config.ConversionError{
+					Kind:   config.ValidationError,
+					Name:   name,
+					Reason: fmt.Sprintf("duplicate %s name %s", kind, name),
+				}
+			}
+			seen[name] = true
+		}
+	}
+	return nil
+}
+
+// setNamePrefix adds the given prefix to the names of all the resources and
+// to the references between them, so that the converted resources don't
+// collide with unrelated ones named the same, e.g. peer1. An error is returned
+// if a prefixed name is not a valid resource name.
+func setNamePrefix(r *config.ClusterResources, prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	metas := []*metav1.ObjectMeta{}
+	for i := range r.Peers {
+		metas = append(metas, &r.Peers[i].ObjectMeta)
+		if r.Peers[i].Spec.BFDProfile != "" {
+			r.Peers[i].Spec.BFDProfile = prefix + r.Peers[i].Spec.BFDProfile
+		}
+		if r.Peers[i].Spec.PasswordSecret.Name != "" {
+			r.Peers[i].Spec.PasswordSecret.Name = prefix + r.Peers[i].Spec.PasswordSecret.Name
+		}
+	}
+	for i := range r.BFDProfiles {
+		metas = append(metas, &r.BFDProfiles[i].ObjectMeta)
+	}
+	for i := range r.Communities {
+		metas = append(metas, &r.Communities[i].ObjectMeta)
+	}
+	for i := range r.Pools {
+		metas = append(metas, &r.Pools[i].ObjectMeta)
+	}
+	for i := range r.BGPAdvs {
+		metas = append(metas, &r.BGPAdvs[i].ObjectMeta)
+		for j := range r.BGPAdvs[i].Spec.IPAddressPools {
+			r.BGPAdvs[i].Spec.IPAddressPools[j] = prefix + r.BGPAdvs[i].Spec.IPAddressPools[j]
+		}
+	}
+	for i := range r.L2Advs {
+		metas = append(metas, &r.L2Advs[i].ObjectMeta)
+		for j := range r.L2Advs[i].Spec.IPAddressPools {
+			r.L2Advs[i].Spec.IPAddressPools[j] = prefix + r.L2Advs[i].Spec.IPAddressPools[j]
+		}
+	}
+	secrets := make([]*corev1.Secret, 0, len(r.PasswordSecrets))
+	for _, s := range r.PasswordSecrets {
+		s := s
+		metas = append(metas, &s.ObjectMeta)
+		secrets = append(secrets, &s)
+	}
+
+	for _, meta := range metas {
+		meta.Name = prefix + meta.Name
+		if errs := validation.IsDNS1123Subdomain(meta.Name); len(errs) > 0 {
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   meta.Name,
+				Reason: fmt.Sprintf("invalid name %q with prefix %q: %s", meta.Name, prefix, strings.Join(errs, ", ")),
+			}
+		}
+	}
+	if len(secrets) > 0 {
+		r.PasswordSecrets = make(map[string]corev1.Secret, len(secrets))
+		for _, s := range secrets {
+			r.PasswordSecrets[s.Name] = *s
+		}
+	}
+	return nil
+}
+
+func addLabels(meta *metav1.ObjectMeta, toAdd map[string]string) {
+	for k, v := range toAdd {
+		if _, ok := meta.Labels[k]; ok {
+			continue
+		}
+		metav1.SetMetaDataLabel(meta, k, v)
+	}
+}
+
+func bfdProfileFor(c *configFile, namespace string) ([]v1beta1.BFDProfile, error) {
 	ret := make([]v1beta1.BFDProfile, len(c.BFDProfiles))
 
+	validations := bfdProfileValidations()
 	for i, bfd := range c.BFDProfiles {
+		for _, validate := range validations {
+			if err := validate(bfd); err != nil {
+				return nil, err
+			}
+		}
 		b := v1beta1.BFDProfile{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      bfd.Name,
-				Namespace: resourcesNameSpace,
+				Namespace: namespace,
 			},
 			Spec: v1beta1.BFDProfileSpec{
-				ReceiveInterval:  bfd.ReceiveInterval,
-				TransmitInterval: bfd.TransmitInterval,
-				DetectMultiplier: bfd.DetectMultiplier,
-				EchoInterval:     bfd.EchoInterval,
-				EchoMode:         &c.BFDProfiles[i].EchoMode,
-				PassiveMode:      &c.BFDProfiles[i].PassiveMode,
-				MinimumTTL:       bfd.MinimumTTL,
+				ReceiveInterval:     bfd.ReceiveInterval,
+				TransmitInterval:    bfd.TransmitInterval,
+				DetectMultiplier:    bfd.DetectMultiplier,
+				EchoInterval:        bfd.EchoInterval,
+				EchoReceiveInterval: bfd.EchoReceiveInterval,
+				EchoMode:            &c.BFDProfiles[i].EchoMode,
+				PassiveMode:         &c.BFDProfiles[i].PassiveMode,
+				MinimumTTL:          bfd.MinimumTTL,
 			},
 		}
 		ret[i] = b
 	}
-	return ret
+	return ret, nil
+}
+
+// bfdProfileValidations returns the checks to run on each bfd profile, in
+// the order of the configuration. The checks are shared by the conversion
+// and the lint, and must be requested again for each pass over the profiles,
+// as the one of the duplicate names remembers the profiles already seen.
+func bfdProfileValidations() []func(bfdProfile) error {
+	names := map[string]bool{}
+	return []func(bfdProfile) error{
+		func(bfd bfdProfile) error {
+			if names[bfd.Name] {
+				return &config.ConversionError{
+					Kind:   config.ValidationError,
+					Name:   bfd.Name,
+					Reason: fmt.Sprintf("duplicate bfd profile name %s", bfd.Name),
+				}
+			}
+			names[bfd.Name] = true
+			return nil
+		},
+		validateEchoReceiveInterval,
+	}
+}
+
+// validateEchoReceiveInterval checks that the echo receive interval of the
+// given bfd profile, if set, is in the range accepted by the BFDProfile.
+func validateEchoReceiveInterval(bfd bfdProfile) error {
+	if bfd.EchoReceiveInterval == nil {
+		return nil
+	}
+	if *bfd.EchoReceiveInterval < 10 || *bfd.EchoReceiveInterval > 60000 {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   bfd.Name,
+			Reason: fmt.Sprintf("bfd profile %s: invalid echo-receive-interval %d, must be in 10-60000 range", bfd.Name, *bfd.EchoReceiveInterval),
+		}
+	}
+	return nil
 }
 
 // communitiesFor aggregates all the community aliases into one community resource.
-func communitiesFor(cf *configFile) []v1beta1.Community {
+func communitiesFor(cf *configFile, opts options) ([]v1beta1.Community, error) {
 	if len(cf.BGPCommunities) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	communitiesAliases := make([]v1beta1.CommunityAlias, 0)
@@ -336,9 +914,12 @@ func communitiesFor(cf *configFile) []v1beta1.Community {
 	sort.Strings(sortedCommunities)
 
 	for _, v := range sortedCommunities {
+		if err := validateCommunityAlias(v, cf.BGPCommunities[v], opts.allowShadowing); err != nil {
+			return nil, err
+		}
 		communityAlias := v1beta1.CommunityAlias{
 			Name:  v,
-			Value: cf.BGPCommunities[v],
+			Value: largeCommunityFor(cf.BGPCommunities[v]),
 		}
 		communitiesAliases = append(communitiesAliases, communityAlias)
 	}
@@ -346,160 +927,1748 @@ func communitiesFor(cf *configFile) []v1beta1.Community {
 	res := v1beta1.Community{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "communities",
-			Namespace: resourcesNameSpace,
+			Namespace: opts.namespace,
 		},
 		Spec: v1beta1.CommunitySpec{
 			Communities: communitiesAliases,
 		},
 	}
-	return []v1beta1.Community{res}
+	return []v1beta1.Community{res}, nil
 }
 
-func peersFor(c *configFile) ([]v1beta2.BGPPeer, error) {
+// peersFor converts the legacy peers. When withSecrets is set, the passwords
+// are moved to basic-auth secrets referenced by the peers, which are returned
+// indexed by name.
+func peersFor(c *configFile, opts options) ([]v1beta2.BGPPeer, map[string]corev1.Secret, error) {
 	res := make([]v1beta2.BGPPeer, 0)
+	var secrets map[string]corev1.Secret
+	if err := validateDefaultBFDProfile(c, opts.defaultBFDProfile); err != nil {
+		return nil, nil, err
+	}
 	for i, peer := range c.Peers {
-		p, err := parsePeer(peer)
+		if peer.BFDProfile == "" {
+			peer.BFDProfile = opts.defaultBFDProfile
+		}
+		p, err := parsePeer(c, peer, opts)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		p.Name = fmt.Sprintf("peer%d", i+1)
-		p.Namespace = resourcesNameSpace
+		p.Namespace = opts.namespace
+		if opts.passwordSecrets && p.Spec.Password != "" {
+			if secrets == nil {
+				secrets = map[string]corev1.Secret{}
+			}
+			secret := passwordSecretFor(p)
+			secrets[secret.Name] = secret
+			p.Spec.PasswordSecret = corev1.SecretReference{Name: secret.Name, Namespace: secret.Namespace}
+			p.Spec.Password = ""
+		}
+		if w := privateASNWarning(peer); w != "" {
+			c.warn(p.Name, "%s: %s", p.Name, w)
+		}
+		if d, err := time.ParseDuration(peer.HoldTime); err == nil && d != p.Spec.HoldTime.Duration {
+			c.warn(p.Name, "%s: hold time %s rounded to %s", p.Name, peer.HoldTime, p.Spec.HoldTime.Duration)
+		}
 		res = append(res, *p)
 	}
-	return res, nil
+	return res, secrets, nil
 }
 
-func parsePeer(p peer) (*v1beta2.BGPPeer, error) {
-	holdTime, err := parseHoldTime(p.HoldTime)
-	if err != nil {
-		return nil, err
+// validateBFDProfileRef checks that the bfd profile referenced by the
+// given peer, if any, is one of the bfd profiles of the configuration.
+func validateBFDProfileRef(c *configFile, p peer) error {
+	if p.BFDProfile == "" {
+		return nil
 	}
+	for _, bfd := range c.BFDProfiles {
+		if bfd.Name == p.BFDProfile {
+			return nil
+		}
+	}
+	return &config.ConversionError{
+		Kind:   config.ValidationError,
+		Name:   peerName(p),
+		Reason: fmt.Sprintf("peer %s: bfd profile %s not found", peerName(p), p.BFDProfile),
+	}
+}
 
-	nodeSels := make([]metav1.LabelSelector, 0)
-	for _, sel := range p.NodeSelectors {
-		s := parseNodeSelector(sel)
-		nodeSels = append(nodeSels, s)
+// validateDefaultBFDProfile checks that the bfd profile set on the peers not
+// referencing one, if any, is among the bfd profiles of the configuration.
+func validateDefaultBFDProfile(c *configFile, name string) error {
+	if name == "" {
+		return nil
+	}
+	for _, bfd := range c.BFDProfiles {
+		if bfd.Name == name {
+			return nil
+		}
+	}
+	return &config.ConversionError{
+		Kind:   config.ValidationError,
+		Name:   name,
+		Reason: fmt.Sprintf("default bfd profile %s not found", name),
 	}
+}
 
-	res := &v1beta2.BGPPeer{
+// validateCommunityRefs checks that the communities of the advertisements
+// that are not literal communities are aliases defined in the generated
+// community resources, so that the advertisements can be rendered.
+func validateCommunityRefs(advs []v1beta1.BGPAdvertisement, communities []v1beta1.Community) error {
+	aliases := map[string]bool{}
+	for _, c := range communities {
+		for _, alias := range c.Spec.Communities {
+			aliases[alias.Name] = true
+		}
+	}
+	for _, adv := range advs {
+		for _, comm := range adv.Spec.Communities {
+			if _, err := community.New(comm); err == nil || aliases[comm] {
+				continue
+			}
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   adv.Name,
+				Reason: fmt.Sprintf("bgp advertisement %s: community %s not found", adv.Name, comm),
+			}
+		}
+	}
+	return nil
+}
+
+func passwordSecretFor(p *v1beta2.BGPPeer) corev1.Secret {
+	return corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: resourcesNameSpace,
+			Name:      fmt.Sprintf("%s-bgp-password", p.Name),
+			Namespace: p.Namespace,
 		},
-		Spec: v1beta2.BGPPeerSpec{
+		Type: corev1.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			"password": []byte(p.Spec.Password),
+		},
+	}
+}
+
+// privateASNWarning returns a non empty warning when the given peer is an
+// eBGP peer where only one of the two sides uses a private ASN, which is
+// usually a misconfiguration when peering with a public router.
+func privateASNWarning(p peer) string {
+	if p.MyASN == p.ASN || p.DynamicASN != "" {
+		return ""
+	}
+	myPrivate, peerPrivate := isPrivateASN(p.MyASN), isPrivateASN(p.ASN)
+	switch {
+	case myPrivate && !peerPrivate:
+		return fmt.Sprintf("local ASN %d is private while the eBGP peer ASN %d is public", p.MyASN, p.ASN)
+	case !myPrivate && peerPrivate:
+		return fmt.Sprintf("eBGP peer ASN %d is private while the local ASN %d is public", p.ASN, p.MyASN)
+	}
+	return ""
+}
+
+// isPrivateASN tells if the given ASN belongs to one of the ranges reserved
+// for private use by RFC 6996.
+func isPrivateASN(asn uint32) bool {
+	return (asn >= 64512 && asn <= 65534) || (asn >= 4200000000 && asn <= 4294967294)
+}
+
+// peerValidations returns the checks of a legacy peer, in the order the
+// conversion runs them. The conversion stops at the first failing one while
+// the lint reports all of them.
+func peerValidations(c *configFile, opts options) []func(peer) error {
+	return []func(peer) error{
+		validatePeerAddress,
+		validatePeerPort,
+		validateTTLSecurity,
+		validateEBGPMultiHopTTL,
+		validatePeerAuth,
+		validateDynamicASN,
+		validateSourceAddress,
+		validateSourceAddresses,
+		validateRouterID,
+		func(p peer) error {
+			_, err := parseHoldTime(p, opts)
+			return err
+		},
+		func(p peer) error {
+			_, err := parseKeepaliveTime(p, opts)
+			return err
+		},
+		func(p peer) error {
+			_, err := parseConnectTime(p, opts)
+			return err
+		},
+		func(p peer) error {
+			_, err := parseBGPRole(p)
+			return err
+		},
+		validateTCPMSS,
+		func(p peer) error {
+			_, err := parseGracefulRestart(p)
+			return err
+		},
+		validateVRFName,
+		validateDisableDefaultVRF,
+		validatePeerNodeSelectors,
+		func(p peer) error {
+			return validateBFDProfileRef(c, p)
+		},
+		func(p peer) error {
+			return validateSourceSubnet(p, opts.localSubnets)
+		},
+	}
+}
+
+func parsePeer(c *configFile, p peer, opts options) (*v1beta2.BGPPeer, error) {
+	for _, validate := range peerValidations(c, opts) {
+		if err := validate(p); err != nil {
+			return nil, err
+		}
+	}
+
+	holdTime, err := parseHoldTime(p, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeSels := make([]metav1.LabelSelector, 0)
+	for _, sel := range p.NodeSelectors {
+		s := parseNodeSelector(sel)
+		nodeSels = append(nodeSels, s)
+	}
+
+	res := &v1beta2.BGPPeer{
+		Spec: v1beta2.BGPPeerSpec{
 			MyASN:         p.MyASN,
 			ASN:           p.ASN,
+			DynamicASN:    p.DynamicASN,
 			Address:       p.Addr,
+			Interface:     p.Interface,
 			SrcAddress:    p.SrcAddr,
-			Port:          p.Port,
+			SrcAddresses:  p.SrcAddrs,
+			Port:          uint16(p.Port),
 			HoldTime:      metav1.Duration{Duration: holdTime},
 			RouterID:      p.RouterID,
 			NodeSelectors: nodeSels,
 			Password:      p.Password,
 			BFDProfile:    p.BFDProfile,
 			EBGPMultiHop:  p.EBGPMultiHop,
+			TTLSecurity:   p.TTLSecurity,
+			Disabled:      p.Disabled,
+		},
+	}
+	if p.EBGPMultiHopTTL != 0 {
+		res.Spec.EBGPMultiHopTTL = uint32(p.EBGPMultiHopTTL)
+	}
+	res.Spec.AuthAlgorithm = p.AuthAlgorithm
+	res.Spec.AuthKeyID = uint32(p.AuthKeyID)
+	keepaliveTime, err := parseKeepaliveTime(p, opts)
+	if err != nil {
+		return nil, err
+	}
+	if keepaliveTime != 0 {
+		res.Spec.KeepaliveTime = metav1.Duration{Duration: keepaliveTime}
+	}
+	connectTime, err := parseConnectTime(p, opts)
+	if err != nil {
+		return nil, err
+	}
+	if p.ConnectTime != "" {
+		res.Spec.ConnectTime = &metav1.Duration{Duration: connectTime}
+	}
+	role, err := parseBGPRole(p)
+	if err != nil {
+		return nil, err
+	}
+	if role != "" {
+		metav1.SetMetaDataAnnotation(&res.ObjectMeta, bgpRoleAnnotation, role)
+	}
+	if p.TCPMSS != nil {
+		metav1.SetMetaDataAnnotation(&res.ObjectMeta, tcpMSSAnnotation, strconv.Itoa(*p.TCPMSS))
+	}
+	gr, err := parseGracefulRestart(p)
+	if err != nil {
+		return nil, err
+	}
+	res.Spec.GracefulRestart = gr
+	res.Spec.VRFName = p.VRFName
+	if p.NoDefaultVRF {
+		metav1.SetMetaDataAnnotation(&res.ObjectMeta, disableDefaultVRFAnnotation, "true")
+	}
+
+	return res, nil
+}
+
+// validateDisableDefaultVRF checks that a peer disabling the default VRF
+// is pinned to a named, non default, VRF.
+func validateDisableDefaultVRF(p peer) error {
+	if !p.NoDefaultVRF {
+		return nil
+	}
+	if p.VRFName == "" || p.VRFName == "default" {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: "disable-default-vrf requires the peer to set a vrf other than the default one",
+		}
+	}
+	return nil
+}
+
+// validatePeerNodeSelectors checks that the node selectors of the peer are
+// valid label selectors.
+func validatePeerNodeSelectors(p peer) error {
+	for _, sel := range p.NodeSelectors {
+		s := parseNodeSelector(sel)
+		if _, err := metav1.LabelSelectorAsSelector(&s); err != nil {
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   peerName(p),
+				Reason: fmt.Sprintf("peer %s: invalid node selector: %s", peerName(p), err),
+			}
+		}
+	}
+	return nil
+}
+
+// peerName returns the name identifying the legacy peer in the errors, its
+// address or its interface, as the legacy peers are not named.
+func peerName(p peer) string {
+	if p.Addr != "" {
+		return p.Addr
+	}
+	return p.Interface
+}
+
+// validatePeerAddress checks that the peer is identified either by its
+// address or by an interface.
+func validatePeerAddress(p peer) error {
+	if p.Addr != "" && p.Interface != "" {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("peer can't have both peer-address %q and interface %q", p.Addr, p.Interface),
+		}
+	}
+	if p.Addr == "" && p.Interface == "" {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: "peer must have either peer-address or interface",
+		}
+	}
+	return nil
+}
+
+// validatePeerPort checks that the port of the peer, if set, is a valid
+// TCP port. Zero means the default BGP port.
+func validatePeerPort(p peer) error {
+	if p.Port < 0 || p.Port > 65535 {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("peer %s: invalid port %d: must be in 0-65535 range, 0 meaning the default 179", peerName(p), p.Port),
+		}
+	}
+	return nil
+}
+
+// validateSourceAddress checks that the source address of the peer, if set, is
+// a valid IP of the same family as the peer address. Whether the address
+// belongs to the speaker nodes can be checked only against the cluster.
+func validateSourceAddress(p peer) error {
+	if p.SrcAddr == "" {
+		return nil
+	}
+	src := net.ParseIP(p.SrcAddr)
+	if src == nil {
+		return &config.ConversionError{
+			Kind:   config.ParseError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid source IP %q", p.SrcAddr),
+		}
+	}
+	addr := net.ParseIP(p.Addr)
+	if addr != nil && (addr.To4() == nil) != (src.To4() == nil) {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("source address %s and peer address %s are of different families", p.SrcAddr, p.Addr),
+		}
+	}
+	return nil
+}
+
+// parseLocalSubnets parses the comma separated CIDRs of the subnets of the
+// speakers.
+func parseLocalSubnets(s string) ([]*net.IPNet, error) {
+	if s == "" {
+		return nil, nil
+	}
+	res := []*net.IPNet{}
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		_, subnet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, &config.ConversionError{
+				Kind:   config.ParseError,
+				Name:   c,
+				Reason: fmt.Sprintf("invalid local subnet %q: %s", c, err),
+			}
+		}
+		res = append(res, subnet)
+	}
+	return res, nil
+}
+
+// validateSourceSubnet checks that the source addresses of a directly
+// connected peer are in the subnet of the peer address, when the subnet is
+// among the given subnets of the speakers. Nothing is checked for the peers
+// outside of them, as their subnet is not known.
+func validateSourceSubnet(p peer, subnets []*net.IPNet) error {
+	addr := net.ParseIP(p.Addr)
+	if addr == nil || p.EBGPMultiHop {
+		return nil
+	}
+	var subnet *net.IPNet
+	for _, s := range subnets {
+		if s.Contains(addr) {
+			subnet = s
+			break
+		}
+	}
+	if subnet == nil {
+		return nil
+	}
+	sources := p.SrcAddrs
+	if p.SrcAddr != "" {
+		sources = []string{p.SrcAddr}
+	}
+	for _, a := range sources {
+		src := net.ParseIP(a)
+		// invalid addresses and the ones of the other family, among the
+		// source-addresses, are checked by validateSourceAddress(es).
+		if src == nil || (src.To4() == nil) != (addr.To4() == nil) {
+			continue
+		}
+		if !subnet.Contains(src) {
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   peerName(p),
+				Reason: fmt.Sprintf("source address %s is not in the subnet %s of the directly connected peer %s", a, subnet, p.Addr),
+			}
+		}
+	}
+	return nil
+}
+
+// validateSourceAddresses checks that the source addresses of the peer, if
+// set, are valid IPs, at most one per family, and that they are not combined
+// with a single source address. The speakers use the one of the same family
+// as the peer address.
+func validateSourceAddresses(p peer) error {
+	if len(p.SrcAddrs) == 0 {
+		return nil
+	}
+	if p.SrcAddr != "" {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: "source-address and source-addresses are mutually exclusive",
+		}
+	}
+	if p.Addr == "" {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: "source-addresses can't be set for a peer without peer-address",
+		}
+	}
+	var v4, v6 string
+	for _, a := range p.SrcAddrs {
+		src := net.ParseIP(a)
+		if src == nil {
+			return &config.ConversionError{
+				Kind:   config.ParseError,
+				Name:   peerName(p),
+				Reason: fmt.Sprintf("invalid source IP %q", a),
+			}
+		}
+		family := &v6
+		if src.To4() != nil {
+			family = &v4
+		}
+		if *family != "" {
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   peerName(p),
+				Reason: fmt.Sprintf("source addresses %s and %s are of the same family", *family, a),
+			}
+		}
+		*family = a
+	}
+	return nil
+}
+
+// validateRouterID checks that the router id, if set, is in the dotted-quad
+// format of an IPv4 address.
+func validateRouterID(p peer) error {
+	id := p.RouterID
+	if id == "" {
+		return nil
+	}
+	ip := net.ParseIP(id)
+	if ip == nil {
+		return &config.ConversionError{
+			Kind:   config.ParseError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid router id %q", id),
+		}
+	}
+	if ip.To4() == nil {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid router id %q: must be an IPv4 address", id),
+		}
+	}
+	return nil
+}
+
+// validateTTLSecurity checks that ttl security is requested only for
+// directly connected peers.
+func validateTTLSecurity(p peer) error {
+	if p.TTLSecurity && p.EBGPMultiHop {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: "ttl-security can't be set for an ebgp-multihop peer",
+		}
+	}
+	return nil
+}
+
+// validateEBGPMultiHopTTL checks that the multihop ttl, if set, is in the
+// 2-255 range and is requested only for multihop peers.
+func validateEBGPMultiHopTTL(p peer) error {
+	if p.EBGPMultiHopTTL == 0 {
+		return nil
+	}
+	if !p.EBGPMultiHop {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: "ebgp-multihop-ttl can be set only for an ebgp-multihop peer",
+		}
+	}
+	if p.EBGPMultiHopTTL < 2 || p.EBGPMultiHopTTL > 255 {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid ebgp-multihop-ttl %d: must be in 2-255 range", p.EBGPMultiHopTTL),
+		}
+	}
+	return nil
+}
+
+// validateDynamicASN checks that the dynamic ASN, if set, is a known one
+// and that it's not set together with a fixed peer ASN.
+func validateDynamicASN(p peer) error {
+	invalid := func(reason string) error {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: reason,
+		}
+	}
+	switch p.DynamicASN {
+	case "":
+	case config.DynamicASNInternal, config.DynamicASNExternal:
+		if p.ASN != 0 {
+			return invalid(fmt.Sprintf("peer-asn %d and dynamic-asn %s are mutually exclusive", p.ASN, p.DynamicASN))
+		}
+	default:
+		return invalid(fmt.Sprintf("unknown dynamic-asn %q, must be one of %s, %s",
+			p.DynamicASN, config.DynamicASNInternal, config.DynamicASNExternal))
+	}
+	return nil
+}
+
+// validatePeerAuth checks that the authentication algorithm, if set, is a known
+// one used together with a password, and that the key id is set only for TCP-AO.
+func validatePeerAuth(p peer) error {
+	invalid := func(reason string) error {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: reason,
+		}
+	}
+	switch p.AuthAlgorithm {
+	case "":
+	case config.AuthTCPMD5, config.AuthTCPAOHMACSHA196, config.AuthTCPAOAES128CMAC96:
+		if p.Password == "" {
+			return invalid(fmt.Sprintf("auth-algorithm %s requires a password", p.AuthAlgorithm))
+		}
+	default:
+		return invalid(fmt.Sprintf("unknown auth-algorithm %q, must be one of %s, %s, %s",
+			p.AuthAlgorithm, config.AuthTCPMD5, config.AuthTCPAOHMACSHA196, config.AuthTCPAOAES128CMAC96))
+	}
+	if p.AuthKeyID == 0 {
+		return nil
+	}
+	if p.AuthAlgorithm != config.AuthTCPAOHMACSHA196 && p.AuthAlgorithm != config.AuthTCPAOAES128CMAC96 {
+		return invalid("auth-key-id can be set only with a TCP-AO auth-algorithm")
+	}
+	if p.AuthKeyID < 0 || p.AuthKeyID > 255 {
+		return invalid(fmt.Sprintf("invalid auth-key-id %d: must be in 0-255 range, 0 meaning not set", p.AuthKeyID))
+	}
+	return nil
+}
+
+func validateTCPMSS(p peer) error {
+	if p.TCPMSS == nil {
+		return nil
+	}
+	if mss := *p.TCPMSS; mss < 536 || mss > 65495 {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid tcp mss %d: must be in 536-65495 range", mss),
+		}
+	}
+	return nil
+}
+
+// validateVRFName checks that the name can be used as a linux interface
+// name, which is what a VRF is backed by on the host.
+func validateVRFName(p peer) error {
+	name := p.VRFName
+	if name == "" {
+		return nil
+	}
+	invalid := func(reason string) error {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid vrf name %q: %s", name, reason),
+		}
+	}
+	// IFNAMSIZ is 16, including the trailing NUL.
+	if len(name) > 15 {
+		return invalid("must be at most 15 characters long")
+	}
+	if name == "." || name == ".." {
+		return invalid("must not be . or ..")
+	}
+	if strings.ContainsAny(name, "/:") {
+		return invalid("must not contain / or :")
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return invalid("must not contain whitespaces or non printable characters")
+		}
+	}
+	return nil
+}
+
+func parseGracefulRestart(p peer) (*v1beta2.GracefulRestart, error) {
+	if p.GracefulRestart == nil {
+		return nil, nil
+	}
+	gr := *p.GracefulRestart
+	res := &v1beta2.GracefulRestart{Enabled: gr.Enabled}
+	if gr.RestartTime == "" {
+		return res, nil
+	}
+	d, err := time.ParseDuration(gr.RestartTime)
+	if err != nil {
+		return nil, &config.ConversionError{
+			Kind:   config.ParseError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid restart time %q: %s", gr.RestartTime, err),
+		}
+	}
+	// The restart time is carried as a 12 bits field in seconds, per RFC4724.
+	rounded := time.Duration(int(d.Seconds())) * time.Second
+	if rounded < time.Second || rounded > 4095*time.Second {
+		return nil, &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid restart time %q: must be in 1s-4095s range", gr.RestartTime),
+		}
+	}
+	res.RestartTime = metav1.Duration{Duration: rounded}
+	return res, nil
+}
+
+func parseBGPRole(p peer) (string, error) {
+	role := p.BGPRole
+	if role == "" {
+		return "", nil
+	}
+	r := strings.ToLower(role)
+	for _, known := range bgpRoles {
+		if r == known {
+			return r, nil
+		}
+	}
+	return "", &config.ConversionError{
+		Kind:   config.ValidationError,
+		Name:   peerName(p),
+		Reason: fmt.Sprintf("invalid bgp role %q: must be one of %s", role, strings.Join(bgpRoles, ", ")),
+	}
+}
+
+func parseNodeSelector(sel nodeSelector) metav1.LabelSelector {
+	res := metav1.LabelSelector{}
+
+	res.MatchLabels = sel.MatchLabels
+	res.MatchExpressions = []metav1.LabelSelectorRequirement{}
+
+	for _, m := range sel.MatchExpressions {
+		matchExp := metav1.LabelSelectorRequirement{
+			Key:      m.Key,
+			Operator: metav1.LabelSelectorOperator(m.Operator),
+			Values:   m.Values,
+		}
+		matchExp.Values = make([]string, len(m.Values))
+		copy(matchExp.Values, m.Values)
+		res.MatchExpressions = append(res.MatchExpressions, matchExp)
+	}
+	return res
+}
+
+// parseLabelSelectors converts the given legacy selectors of the pool and
+// checks they are valid label selectors.
+func parseLabelSelectors(poolName, element string, sels []nodeSelector) ([]metav1.LabelSelector, error) {
+	res := make([]metav1.LabelSelector, 0, len(sels))
+	for _, sel := range sels {
+		s := parseNodeSelector(sel)
+		if _, err := metav1.LabelSelectorAsSelector(&s); err != nil {
+			return nil, &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   poolName,
+				Reason: fmt.Sprintf("pool %s: invalid %s: %s", poolName, element, err),
+			}
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// parseHoldTime parses the hold time of a peer, falling back to the
+// default hold time when it's not set. The same constraints apply to both.
+func parseHoldTime(p peer, opts options) (time.Duration, error) {
+	ht := p.HoldTime
+	if ht == "" {
+		ht = opts.defaultHoldTime.String()
+	}
+	d, err := time.ParseDuration(ht)
+	if err != nil {
+		return 0, &config.ConversionError{
+			Kind:   config.ParseError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid hold time %q: %s", ht, err),
+		}
+	}
+	rounded, err := roundDuration(p, opts, "hold time", ht, d)
+	if err != nil {
+		return 0, err
+	}
+	if rounded != 0 && rounded < 3*time.Second {
+		return 0, &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid hold time %q: must be 0 or >=3s", ht),
+		}
+	}
+	return rounded, nil
+}
+
+// parseKeepaliveTime parses the keepalive time of a peer, zero when it's not
+// set.
+func parseKeepaliveTime(p peer, opts options) (time.Duration, error) {
+	ka := p.KeepaliveTime
+	if ka == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(ka)
+	if err != nil {
+		return 0, &config.ConversionError{
+			Kind:   config.ParseError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid keepalive time %q: %s", ka, err),
+		}
+	}
+	return roundDuration(p, opts, "keepalive time", ka, d)
+}
+
+// parseConnectTime parses the connect time of a peer, zero when it's not set.
+func parseConnectTime(p peer, opts options) (time.Duration, error) {
+	ct := p.ConnectTime
+	if ct == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(ct)
+	if err != nil {
+		return 0, &config.ConversionError{
+			Kind:   config.ParseError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid connect time %q: %s", ct, err),
+		}
+	}
+	rounded, err := roundDuration(p, opts, "connect time", ct, d)
+	if err != nil {
+		return 0, err
+	}
+	if rounded == 0 && d != 0 {
+		return 0, &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid connect time %q: must be at least 1s", ct),
+		}
+	}
+	return rounded, nil
+}
+
+// roundDuration truncates the given duration to seconds, as the CRs don't
+// support a finer precision. With strict durations, a duration with
+// sub-second precision is an error instead.
+func roundDuration(p peer, opts options, desc, value string, d time.Duration) (time.Duration, error) {
+	rounded := time.Duration(int(d.Seconds())) * time.Second
+	if opts.strictDurations && rounded != d {
+		return 0, &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   peerName(p),
+			Reason: fmt.Sprintf("invalid %s %q: sub-second precision is not supported", desc, value),
+		}
+	}
+	return rounded, nil
+}
+
+func ipAddressPoolsFor(c *configFile, opts options) ([]v1beta1.IPAddressPool, error) {
+	res := make([]v1beta1.IPAddressPool, len(c.Pools))
+	for i, addresspool := range c.Pools {
+		var ap v1beta1.IPAddressPool
+		ap.Name = addresspool.Name
+		ap.Namespace = opts.namespace
+		for _, validate := range poolValidations(c) {
+			if err := validate(addresspool); err != nil {
+				return nil, err
+			}
+		}
+		if w := unknownProtocolWarning(addresspool); w != "" {
+			c.warn(ap.Name, "pool %s: %s", ap.Name, w)
+		}
+		ap.Spec.Addresses = make([]string, len(addresspool.Addresses))
+		for j, addr := range addresspool.Addresses {
+			ap.Spec.Addresses[j] = singleIPToCIDR(addr)
+		}
+		if opts.mergeCIDRs {
+			merged, err := mergeAdjacentCIDRs(addresspool.Name, ap.Spec.Addresses)
+			if err != nil {
+				return nil, err
+			}
+			ap.Spec.Addresses = merged
+		}
+		if addresspool.AvoidBuggyIPs != nil {
+			ap.Spec.AvoidBuggyIPs = *addresspool.AvoidBuggyIPs
+		}
+		ap.Spec.AutoAssign = addresspool.AutoAssign
+		if len(addresspool.Namespaces) > 0 {
+			ap.Spec.AllocateTo = &v1beta1.ServiceAllocation{
+				Namespaces: make([]string, len(addresspool.Namespaces)),
+			}
+			copy(ap.Spec.AllocateTo.Namespaces, addresspool.Namespaces)
+		}
+		if len(addresspool.NamespaceSelectors) > 0 {
+			sels, err := parseLabelSelectors(addresspool.Name, "namespace-selectors", addresspool.NamespaceSelectors)
+			if err != nil {
+				return nil, err
+			}
+			if ap.Spec.AllocateTo == nil {
+				ap.Spec.AllocateTo = &v1beta1.ServiceAllocation{}
+			}
+			ap.Spec.AllocateTo.NamespaceSelectors = sels
+		}
+		if len(addresspool.ServiceSelectors) > 0 {
+			sels, err := parseLabelSelectors(addresspool.Name, "service-selectors", addresspool.ServiceSelectors)
+			if err != nil {
+				return nil, err
+			}
+			if ap.Spec.AllocateTo == nil {
+				ap.Spec.AllocateTo = &v1beta1.ServiceAllocation{}
+			}
+			ap.Spec.AllocateTo.ServiceSelectors = sels
+		}
+		if addresspool.Priority != 0 {
+			if ap.Spec.AllocateTo == nil {
+				ap.Spec.AllocateTo = &v1beta1.ServiceAllocation{}
+			}
+			ap.Spec.AllocateTo.Priority = addresspool.Priority
+		}
+		ap.Spec.AllocationAlgorithm = addresspool.Algorithm
+		err := setQoSAnnotations(&ap, addresspool)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = ap
+	}
+	return res, nil
+}
+
+// poolValidations returns the checks of a legacy pool, in the order the
+// conversion runs them. The conversion stops at the first failing one while
+// the lint reports all of them.
+func poolValidations(c *configFile) []func(addressPool) error {
+	return []func(addressPool) error{
+		validatePoolAddresses,
+		validatePoolOverlaps,
+		validatePoolNamespaces,
+		func(ap addressPool) error {
+			_, err := parseLabelSelectors(ap.Name, "namespace-selectors", ap.NamespaceSelectors)
+			return err
+		},
+		func(ap addressPool) error {
+			_, err := parseLabelSelectors(ap.Name, "service-selectors", ap.ServiceSelectors)
+			return err
+		},
+		validatePoolPriority,
+		validateAllocationAlgorithm,
+		validateDualStack,
+		func(ap addressPool) error {
+			// the annotations are set on a scratch pool, only the validation matters here.
+			return setQoSAnnotations(&v1beta1.IPAddressPool{}, ap)
+		},
+		validateBGPAndLayer2,
+		func(ap addressPool) error {
+			return validateBlackholeCommunity(c, ap)
 		},
+		func(ap addressPool) error {
+			return validateBGPAdvertisements(c, ap)
+		},
+		validateLayer2Attributes,
+	}
+}
+
+// unknownProtocolWarning returns a non empty warning when the given pool
+// has none of the known protocols, as it is converted to a pool that is
+// not announced.
+func unknownProtocolWarning(ap addressPool) string {
+	switch ap.Protocol {
+	case BGP, Layer2, BGPAndLayer2:
+		return ""
+	}
+	return fmt.Sprintf("unknown protocol %q, the pool is not announced", ap.Protocol)
+}
+
+// validatePoolNamespaces checks that the namespaces the pool is allocated
+// to are valid namespace names.
+func validatePoolNamespaces(ap addressPool) error {
+	for _, ns := range ap.Namespaces {
+		if err := validateNamespaceName(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveExternalBlocks sets the addresses of the pools referencing an
+// external block to the ranges of the block, so that they are converted
+// and validated as the inline ones.
+func resolveExternalBlocks(c *configFile) error {
+	for i, ap := range c.Pools {
+		if ap.ExternalBlock == "" {
+			continue
+		}
+		addrs, err := externalBlockAddresses(c, ap)
+		if err != nil {
+			return err
+		}
+		c.Pools[i].Addresses = addrs
+	}
+	return nil
+}
+
+// externalBlockAddresses returns the ranges of the external block referenced
+// by the pool, read from the externalBlocksAnnotation of the ConfigMap.
+func externalBlockAddresses(c *configFile, ap addressPool) ([]string, error) {
+	if len(ap.Addresses) > 0 {
+		return nil, &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ap.Name,
+			Reason: fmt.Sprintf("pool %s: addresses and external-block are mutually exclusive", ap.Name),
+		}
+	}
+	data, ok := c.annotations[externalBlocksAnnotation]
+	if !ok {
+		return nil, &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ap.Name,
+			Reason: fmt.Sprintf("pool %s: external block %s referenced but the %s annotation is not set", ap.Name, ap.ExternalBlock, externalBlocksAnnotation),
+		}
+	}
+	blocks := map[string][]string{}
+	if err := yaml.Unmarshal([]byte(data), &blocks); err != nil {
+		return nil, &config.ConversionError{
+			Kind:   config.ParseError,
+			Name:   externalBlocksAnnotation,
+			Reason: fmt.Sprintf("invalid %s annotation: %s", externalBlocksAnnotation, err),
+		}
+	}
+	addrs, ok := blocks[ap.ExternalBlock]
+	if !ok {
+		return nil, &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ap.Name,
+			Reason: fmt.Sprintf("pool %s: external block %s not found", ap.Name, ap.ExternalBlock),
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ap.Name,
+			Reason: fmt.Sprintf("pool %s: external block %s has no addresses", ap.Name, ap.ExternalBlock),
+		}
+	}
+	return addrs, nil
+}
+
+// validatePoolAddresses checks that the pool has addresses and that each
+// of them is either a CIDR or a start-end range with start lower or equal
+// to end.
+func validatePoolAddresses(addresspool addressPool) error {
+	if len(addresspool.Addresses) == 0 {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   addresspool.Name,
+			Reason: fmt.Sprintf("pool %s: no addresses", addresspool.Name),
+		}
+	}
+	for _, addr := range addresspool.Addresses {
+		if _, err := config.ParseCIDR(addr); err != nil {
+			return &config.ConversionError{
+				Kind:   config.ParseError,
+				Name:   addresspool.Name,
+				Reason: fmt.Sprintf("pool %s: invalid address %q: %s", addresspool.Name, addr, err),
+			}
+		}
+	}
+	return nil
+}
+
+// validatePoolOverlaps checks that the addresses of the pool don't overlap
+// with each other, as the overlapping IPs would be counted twice.
+func validatePoolOverlaps(addresspool addressPool) error {
+	cidrs := make([][]*net.IPNet, len(addresspool.Addresses))
+	for i, addr := range addresspool.Addresses {
+		parsed, err := config.ParseCIDR(addr)
+		if err != nil {
+			// invalid addresses are reported by validatePoolAddresses.
+			continue
+		}
+		cidrs[i] = parsed
+	}
+	for i := range cidrs {
+		for j := i + 1; j < len(cidrs); j++ {
+			if !cidrsOverlap(cidrs[i], cidrs[j]) {
+				continue
+			}
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   addresspool.Name,
+				Reason: fmt.Sprintf("pool %s: address %q overlaps with %q", addresspool.Name, addresspool.Addresses[j], addresspool.Addresses[i]),
+			}
+		}
+	}
+	return nil
+}
+
+// cidrsOverlap tells if any of the cidrs of a overlaps with one of b.
+func cidrsOverlap(a, b []*net.IPNet) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x.Contains(y.IP) || y.Contains(x.IP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// singleIPToCIDR returns the /32 or /128 CIDR of the given address when
+// it is a single IP, and the address as is otherwise.
+func singleIPToCIDR(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+// mergeAdjacentCIDRs merges the adjacent CIDRs of the given, already
+// validated, addresses of the pool into the smallest list of CIDRs covering
+// them, sorted by family and address. The start-end ranges are kept as they
+// are, after the CIDRs. An error is returned if a merged CIDR doesn't cover
+// exactly the addresses it's made of, e.g. because of overlapping entries.
+func mergeAdjacentCIDRs(poolName string, addrs []string) ([]string, error) {
+	v4, v6 := []ipaddr.Prefix{}, []ipaddr.Prefix{}
+	ranges := []string{}
+	for _, addr := range addrs {
+		_, cidr, err := net.ParseCIDR(addr)
+		if err != nil {
+			ranges = append(ranges, addr)
+			continue
+		}
+		if cidr.IP.To4() != nil {
+			v4 = append(v4, *ipaddr.NewPrefix(cidr))
+			continue
+		}
+		v6 = append(v6, *ipaddr.NewPrefix(cidr))
+	}
+
+	res := []string{}
+	for _, declared := range [][]ipaddr.Prefix{v4, v6} {
+		for _, merged := range ipaddr.Aggregate(declared) {
+			covered := big.NewInt(0)
+			for _, d := range declared {
+				if merged.Contains(&d) || merged.Equal(&d) {
+					covered.Add(covered, prefixSize(d))
+				}
+			}
+			if covered.Cmp(prefixSize(merged)) != 0 {
+				return nil, &config.ConversionError{
+					Kind:   config.ValidationError,
+					Name:   poolName,
+					Reason: fmt.Sprintf("pool %s: merged range %s doesn't match the declared addresses", poolName, merged.String()),
+				}
+			}
+			res = append(res, merged.String())
+		}
+	}
+	return append(res, ranges...), nil
+}
+
+// prefixSize returns the number of addresses of the prefix.
+func prefixSize(p ipaddr.Prefix) *big.Int {
+	ones, bits := p.Mask.Size()
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+}
+
+// validatePoolPriority checks that the priority of the pool is not negative
+// and that the pool is scoped to namespaces or services, as the priority
+// applies only to the pools matching a service.
+func validatePoolPriority(addresspool addressPool) error {
+	if addresspool.Priority < 0 {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   addresspool.Name,
+			Reason: fmt.Sprintf("invalid priority %d for pool %s: must be positive", addresspool.Priority, addresspool.Name),
+		}
+	}
+	if addresspool.Priority > 0 && len(addresspool.Namespaces) == 0 &&
+		len(addresspool.NamespaceSelectors) == 0 && len(addresspool.ServiceSelectors) == 0 {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   addresspool.Name,
+			Reason: fmt.Sprintf("pool %s: priority can be set only with namespaces, namespace-selectors or service-selectors", addresspool.Name),
+		}
+	}
+	return nil
+}
+
+// validateAllocationAlgorithm checks that the algorithm picking the IPs of
+// the pool is one supported by the IPAddressPool.
+func validateAllocationAlgorithm(addresspool addressPool) error {
+	switch addresspool.Algorithm {
+	case "", config.AllocationLowestFree, config.AllocationRoundRobin:
+		return nil
+	}
+	return &config.ConversionError{
+		Kind:   config.ValidationError,
+		Name:   addresspool.Name,
+		Reason: fmt.Sprintf("pool %s: invalid allocation-algorithm %q: must be %s or %s", addresspool.Name, addresspool.Algorithm, config.AllocationLowestFree, config.AllocationRoundRobin),
+	}
+}
+
+// setQoSAnnotations validates the DSCP / ToS marking hints of the legacy pool
+// and sets them as annotations of the given pool.
+func setQoSAnnotations(ap *v1beta1.IPAddressPool, addresspool addressPool) error {
+	if addresspool.DSCP != nil && addresspool.ToS != nil {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   addresspool.Name,
+			Reason: fmt.Sprintf("pool %s can't have both dscp and tos set", addresspool.Name),
+		}
+	}
+	if addresspool.DSCP != nil {
+		if *addresspool.DSCP < 0 || *addresspool.DSCP > 63 {
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   addresspool.Name,
+				Reason: fmt.Sprintf("invalid dscp %d for pool %s: must be in 0-63 range", *addresspool.DSCP, addresspool.Name),
+			}
+		}
+		metav1.SetMetaDataAnnotation(&ap.ObjectMeta, dscpAnnotation, strconv.Itoa(*addresspool.DSCP))
+	}
+	if addresspool.ToS != nil {
+		if *addresspool.ToS < 0 || *addresspool.ToS > 255 {
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   addresspool.Name,
+				Reason: fmt.Sprintf("invalid tos %d for pool %s: must be in 0-255 range", *addresspool.ToS, addresspool.Name),
+			}
+		}
+		metav1.SetMetaDataAnnotation(&ap.ObjectMeta, tosAnnotation, strconv.Itoa(*addresspool.ToS))
 	}
-	if p.KeepaliveTime != "" {
-		keepaliveTime, err := parseKeepaliveTime(p.KeepaliveTime)
-		if err != nil {
+	return nil
+}
+
+func bgpAdvertisementsFor(c *configFile, opts options) ([]v1beta1.BGPAdvertisement, error) {
+	if c.DefaultCommunity != "" {
+		if err := validateCommunity(c, c.DefaultCommunity, "default-community", c.DefaultCommunity); err != nil {
 			return nil, err
 		}
-		res.Spec.KeepaliveTime = metav1.Duration{Duration: keepaliveTime}
 	}
+	if err := validateNamespaceCommunities(c); err != nil {
+		return nil, err
+	}
+	return buildBGPAdvertisements(c, opts)
+}
 
+// buildBGPAdvertisements builds the advertisements of the pools, assuming the
+// default community and the namespace communities are already validated.
+func buildBGPAdvertisements(c *configFile, opts options) ([]v1beta1.BGPAdvertisement, error) {
+	namespace := opts.namespace
+	res := make([]v1beta1.BGPAdvertisement, 0)
+	for _, ap := range c.Pools {
+		// the index is per pool, so that the names of the advertisements
+		// don't depend on the other pools.
+		index := 0
+		for _, bgpAdv := range ap.BGPAdvertisements {
+			var b v1beta1.BGPAdvertisement
+			b.Name = bgpAdvName(ap.Name, index)
+			index++
+			b.Namespace = namespace
+			b.Spec.Communities = sortedCommunities(c, bgpAdv.Communities)
+			if len(b.Spec.Communities) == 0 && c.DefaultCommunity != "" {
+				b.Spec.Communities = sortedCommunities(c, []string{c.DefaultCommunity})
+			}
+			if bgpAdv.GracefulShutdown {
+				b.Spec.Communities = withGracefulShutdown(c, b.Spec.Communities)
+			}
+			if blackhole := blackholeCommunityFor(ap, bgpAdv); blackhole != "" {
+				b.Spec.Communities = withCommunity(c, b.Spec.Communities, blackhole)
+			}
+			b.Spec.AggregationLength = bgpAdv.AggregationLength
+			b.Spec.AggregationLengthV6 = aggregationLengthV6For(c, ap, bgpAdv)
+			b.Spec.LocalPref = bgpAdv.LocalPref
+			b.Spec.NextHop = bgpAdv.NextHop
+			if len(bgpAdv.NodeSelectors) > 0 {
+				sels, err := parseLabelSelectors(ap.Name, "node-selectors", bgpAdv.NodeSelectors)
+				if err != nil {
+					return nil, err
+				}
+				b.Spec.NodeSelectors = sels
+			}
+			b.Spec.IPAddressPools = []string{ap.Name}
+			res = append(res, b)
+		}
+		if len(ap.BGPAdvertisements) == 0 && ap.Protocol.announcesBGP() && !ap.SkipDefaultAdv {
+			adv := emptyBGPAdv(ap.Name, index, namespace)
+			if c.DefaultCommunity != "" {
+				adv.Spec.Communities = sortedCommunities(c, []string{c.DefaultCommunity})
+			}
+			if ap.BlackholeCommunity != "" {
+				adv.Spec.Communities = withCommunity(c, adv.Spec.Communities, ap.BlackholeCommunity)
+			}
+			res = append(res, adv)
+			index++
+		}
+		if !ap.Protocol.announcesBGP() {
+			continue
+		}
+		// Advertisements can't select the services by namespace, so the communities
+		// are exact only for pools allocated to a single namespace.
+		if len(ap.Namespaces) > 1 && hasNamespaceCommunities(c, ap.Namespaces) {
+			c.warn(ap.Name, "pool %s is allocated to namespaces %s, the communities of each of them "+
+				"are advertised for all the addresses of the pool", ap.Name, strings.Join(ap.Namespaces, ", "))
+		}
+		for _, ns := range ap.Namespaces {
+			communities, ok := c.NamespaceCommunities[ns]
+			if !ok {
+				continue
+			}
+			adv := emptyBGPAdv(ap.Name, index, namespace)
+			adv.Spec.Communities = sortedCommunities(c, communities)
+			if ap.BlackholeCommunity != "" {
+				adv.Spec.Communities = withCommunity(c, adv.Spec.Communities, ap.BlackholeCommunity)
+			}
+			res = append(res, adv)
+			index++
+		}
+	}
+	if opts.mergeAdvertisements {
+		res = mergeBGPAdvertisements(c, res)
+	}
 	return res, nil
 }
 
-func parseNodeSelector(sel nodeSelector) metav1.LabelSelector {
-	res := metav1.LabelSelector{}
+// mergeBGPAdvertisements merges the advertisements differing only by their
+// communities into one carrying all of them, dropping the duplicated ones.
+// The merged advertisement keeps the name of the first one.
+func mergeBGPAdvertisements(c *configFile, advs []v1beta1.BGPAdvertisement) []v1beta1.BGPAdvertisement {
+	res := make([]v1beta1.BGPAdvertisement, 0, len(advs))
+	for _, adv := range advs {
+		merged := false
+		for i := range res {
+			if !sameAdvertisement(res[i], adv) {
+				continue
+			}
+			communities := append([]string{}, res[i].Spec.Communities...)
+			seen := make(map[string]bool, len(communities))
+			for _, comm := range communities {
+				seen[comm] = true
+			}
+			for _, comm := range adv.Spec.Communities {
+				if !seen[comm] {
+					communities = append(communities, comm)
+					seen[comm] = true
+				}
+			}
+			res[i].Spec.Communities = sortedCommunities(c, communities)
+			merged = true
+			break
+		}
+		if !merged {
+			res = append(res, adv)
+		}
+	}
+	return res
+}
 
-	res.MatchLabels = sel.MatchLabels
-	res.MatchExpressions = []metav1.LabelSelectorRequirement{}
+// sameAdvertisement tells if the two advertisements are the same
+// apart from their name and their communities.
+func sameAdvertisement(a, b v1beta1.BGPAdvertisement) bool {
+	a.Spec.Communities = nil
+	b.Spec.Communities = nil
+	return reflect.DeepEqual(a.Spec, b.Spec)
+}
 
-	for _, m := range sel.MatchExpressions {
-		matchExp := metav1.LabelSelectorRequirement{
-			Key:      m.Key,
-			Operator: metav1.LabelSelectorOperator(m.Operator),
-			Values:   m.Values,
+// validateCommunity checks that the given value of the element is
+// either a valid community or one of the bgp-communities aliases. The
+// name is the one of the configuration element the value belongs to.
+func validateCommunity(c *configFile, name, element, value string) error {
+	if _, ok := c.BGPCommunities[value]; ok {
+		return nil
+	}
+	if _, err := community.New(largeCommunityFor(value)); err != nil {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   name,
+			Reason: fmt.Sprintf("invalid %s %q: %s", element, value, err),
 		}
-		matchExp.Values = make([]string, len(m.Values))
-		copy(matchExp.Values, m.Values)
-		res.MatchExpressions = append(res.MatchExpressions, matchExp)
 	}
-	return res
+	return nil
 }
 
-func parseHoldTime(ht string) (time.Duration, error) {
-	if ht == "" {
-		return 90 * time.Second, nil
+// withGracefulShutdown returns the given, already validated, communities with
+// the graceful shutdown one added, unless one of them, or the value of one of
+// the aliases, is already it.
+func withGracefulShutdown(c *configFile, communities []string) []string {
+	return withCommunity(c, communities, gracefulShutdownCommunity)
+}
+
+// withCommunity returns the given, already validated, communities with the
+// given one, numeric or alias, added, unless one of them has the same value.
+func withCommunity(c *configFile, communities []string, toAdd string) []string {
+	valueOf := func(comm string) string {
+		value, isAlias := c.BGPCommunities[comm]
+		if !isAlias {
+			value = comm
+		}
+		parsed, err := community.New(largeCommunityFor(value))
+		if err != nil {
+			return ""
+		}
+		return parsed.String()
 	}
-	d, err := time.ParseDuration(ht)
-	if err != nil {
-		return 0, fmt.Errorf("invalid hold time %q: %s", ht, err)
+	added := valueOf(toAdd)
+	for _, comm := range communities {
+		if v := valueOf(comm); v != "" && v == added {
+			return communities
+		}
 	}
-	rounded := time.Duration(int(d.Seconds())) * time.Second
-	if rounded != 0 && rounded < 3*time.Second {
-		return 0, fmt.Errorf("invalid hold time %q: must be 0 or >=3s", ht)
+	return sortedCommunities(c, append(communities, toAdd))
+}
+
+// blackholeCommunityFor returns the blackhole community of the advertisement,
+// falling back to the one of the pool.
+func blackholeCommunityFor(ap addressPool, adv bgpAdvertisement) string {
+	if adv.BlackholeCommunity != "" {
+		return adv.BlackholeCommunity
 	}
-	return rounded, nil
+	return ap.BlackholeCommunity
 }
 
-func parseKeepaliveTime(ka string) (time.Duration, error) {
-	d, err := time.ParseDuration(ka)
+// validateBlackholeCommunity checks that the blackhole communities of the pool
+// and of its advertisements are valid communities, and that the pool is
+// announced via BGP when setting one.
+func validateBlackholeCommunity(c *configFile, ap addressPool) error {
+	if ap.BlackholeCommunity == "" {
+		return nil
+	}
+	if !ap.Protocol.announcesBGP() {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ap.Name,
+			Reason: fmt.Sprintf("pool %s: blackhole-community is a bgp only attribute and can't be set on a layer2 pool", ap.Name),
+		}
+	}
+	return validateCommunity(c, ap.Name, "blackhole-community", ap.BlackholeCommunity)
+}
+
+// validateCommunityAlias checks that the value of a bgp-communities alias
+// is a valid classic or large community, and that an alias named as a
+// well-known community has its value, unless shadowing is allowed.
+func validateCommunityAlias(alias, value string, allowShadowing bool) error {
+	parsed, err := community.New(largeCommunityFor(value))
 	if err != nil {
-		return 0, fmt.Errorf("invalid keepalive time %q: %s", ka, err)
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   alias,
+			Reason: fmt.Sprintf("invalid community %q for alias %s: %s", value, alias, err),
+		}
 	}
-	rounded := time.Duration(int(d.Seconds())) * time.Second
-	return rounded, nil
+	wellKnown, ok := wellKnownCommunities[alias]
+	if !ok || allowShadowing {
+		return nil
+	}
+	if expected, _ := community.New(wellKnown); parsed.String() != expected.String() {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   alias,
+			Reason: fmt.Sprintf("alias %s with value %q shadows the well-known community %s, set -allow-community-shadowing to allow it", alias, value, wellKnown),
+		}
+	}
+	return nil
 }
 
-func ipAddressPoolsFor(c *configFile) []v1beta1.IPAddressPool {
-	res := make([]v1beta1.IPAddressPool, len(c.Pools))
-	for i, addresspool := range c.Pools {
-		var ap v1beta1.IPAddressPool
-		ap.Name = addresspool.Name
-		ap.Namespace = resourcesNameSpace
-		ap.Spec.Addresses = make([]string, len(addresspool.Addresses))
-		copy(ap.Spec.Addresses, addresspool.Addresses)
-		if addresspool.AvoidBuggyIPs != nil {
-			ap.Spec.AvoidBuggyIPs = *addresspool.AvoidBuggyIPs
+// largeCommunityFor returns the given community in the large:<asn>:<function>:<parameter>
+// format expected by the custom resources when it is a large community written as
+// <asn>:<function>:<parameter>, and unchanged otherwise. Well-known names are
+// replaced by their value and hex components are converted to decimal in both
+// cases, see decimalCommunity.
+func largeCommunityFor(value string) string {
+	if wellKnown, ok := wellKnownCommunities[value]; ok {
+		value = wellKnown
+	}
+	value = decimalCommunity(value)
+	fields := strings.Split(value, ":")
+	if len(fields) != 3 {
+		return value
+	}
+	for _, f := range fields {
+		if _, err := strconv.ParseUint(f, 10, 32); err != nil {
+			return value
+		}
+	}
+	return "large:" + value
+}
+
+// decimalCommunity returns the given community with its hex components,
+// e.g. 0xFDE8:0x64, converted to decimal, e.g. 65000:100. The community is
+// returned unchanged if one of the hex components is invalid, so that it's
+// rejected by the validation.
+func decimalCommunity(value string) string {
+	prefix := ""
+	if strings.HasPrefix(value, "large:") {
+		prefix = "large:"
+		value = strings.TrimPrefix(value, prefix)
+	}
+	fields := strings.Split(value, ":")
+	for i, f := range fields {
+		if !strings.HasPrefix(f, "0x") && !strings.HasPrefix(f, "0X") {
+			continue
+		}
+		n, err := strconv.ParseUint(f[2:], 16, 32)
+		if err != nil {
+			return prefix + value
+		}
+		fields[i] = strconv.FormatUint(n, 10)
+	}
+	return prefix + strings.Join(fields, ":")
+}
+
+// sortedCommunities returns a copy of the given, already validated, communities with
+// the large ones in the format expected by the custom resources, sorted by their value
+// so that classic and large communities are rendered in a stable order. Aliases are
+// sorted by the value they refer to.
+func sortedCommunities(c *configFile, communities []string) []string {
+	res := make([]string, len(communities))
+	values := make(map[string]community.BGPCommunity, len(communities))
+	for i, comm := range communities {
+		res[i] = comm
+		value, isAlias := c.BGPCommunities[comm]
+		if !isAlias {
+			res[i] = largeCommunityFor(comm)
+			value = comm
+		}
+		// aliases with invalid values are left in place, they are reported
+		// when the resources are parsed.
+		if parsed, err := community.New(largeCommunityFor(value)); err == nil {
+			values[res[i]] = parsed
 		}
-		ap.Spec.AutoAssign = addresspool.AutoAssign
-		res[i] = ap
 	}
+	sort.SliceStable(res, func(i, j int) bool {
+		a, okA := values[res[i]]
+		b, okB := values[res[j]]
+		if !okA || !okB {
+			return false
+		}
+		return a.LessThan(b)
+	})
 	return res
 }
 
-func bgpAdvertisementsFor(c *configFile) []v1beta1.BGPAdvertisement {
-	res := make([]v1beta1.BGPAdvertisement, 0)
-	index := 1
+// validateNamespaceCommunities checks the namespace-communities mappings, and
+// warns about the namespaces not used by any bgp pool.
+func validateNamespaceCommunities(c *configFile) error {
+	used := map[string]bool{}
 	for _, ap := range c.Pools {
-		for _, bgpAdv := range ap.BGPAdvertisements {
-			var b v1beta1.BGPAdvertisement
-			b.Name = fmt.Sprintf("bgpadvertisement%d", index)
-			index++
-			b.Namespace = resourcesNameSpace
-			b.Spec.Communities = make([]string, len(bgpAdv.Communities))
-			copy(b.Spec.Communities, bgpAdv.Communities)
-			b.Spec.AggregationLength = bgpAdv.AggregationLength
-			b.Spec.AggregationLengthV6 = bgpAdv.AggregationLengthV6
-			b.Spec.LocalPref = bgpAdv.LocalPref
-			b.Spec.IPAddressPools = []string{ap.Name}
-			res = append(res, b)
+		if !ap.Protocol.announcesBGP() {
+			continue
 		}
-		if len(ap.BGPAdvertisements) == 0 && ap.Protocol == BGP {
-			res = append(res, emptyBGPAdv(ap.Name, index))
-			index++
+		for _, ns := range ap.Namespaces {
+			used[ns] = true
 		}
 	}
-	return res
+
+	namespaces := make([]string, 0, len(c.NamespaceCommunities))
+	for ns := range c.NamespaceCommunities {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	for _, ns := range namespaces {
+		if err := validateNamespaceName(ns); err != nil {
+			return err
+		}
+		communities := c.NamespaceCommunities[ns]
+		if len(communities) == 0 {
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   ns,
+				Reason: fmt.Sprintf("namespace %s has no communities", ns),
+			}
+		}
+		for _, comm := range communities {
+			if err := validateCommunity(c, ns, "namespace-communities", comm); err != nil {
+				return err
+			}
+		}
+		if !used[ns] {
+			c.warn("namespace-communities", "namespace %s has communities but no bgp pool is allocated to it", ns)
+		}
+	}
+	return nil
+}
+
+func hasNamespaceCommunities(c *configFile, namespaces []string) bool {
+	for _, ns := range namespaces {
+		if _, ok := c.NamespaceCommunities[ns]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateNamespaceName(ns string) error {
+	if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ns,
+			Reason: fmt.Sprintf("invalid namespace %q: %s", ns, strings.Join(errs, ", ")),
+		}
+	}
+	return nil
+}
+
+// validateBGPAdvertisements checks that the pool has bgp advertisements only
+// when announced via BGP, and that their communities, next hops and node
+// selectors are valid.
+func validateBGPAdvertisements(c *configFile, ap addressPool) error {
+	for _, adv := range ap.BGPAdvertisements {
+		if ap.Protocol == Layer2 {
+			return &config.ConversionError{
+				Kind:   config.ValidationError,
+				Name:   ap.Name,
+				Reason: fmt.Sprintf("pool %s: %s", ap.Name, bgpOnlyAttributeError(adv)),
+			}
+		}
+		for _, comm := range adv.Communities {
+			if err := validateCommunity(c, ap.Name, "community", comm); err != nil {
+				return err
+			}
+		}
+		if adv.BlackholeCommunity != "" {
+			if err := validateCommunity(c, ap.Name, "blackhole-community", adv.BlackholeCommunity); err != nil {
+				return err
+			}
+		}
+		if adv.NextHop != "" {
+			if err := validateNextHop(ap, adv.NextHop); err != nil {
+				return err
+			}
+		}
+		if _, err := parseLabelSelectors(ap.Name, "node-selectors", adv.NodeSelectors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bgpOnlyAttributeError describes why the given advertisement can't be part
+// of a layer2 pool.
+func bgpOnlyAttributeError(adv bgpAdvertisement) string {
+	switch {
+	case adv.LocalPref != 0:
+		return "localpref is a bgp only attribute and can't be set on a layer2 pool"
+	case len(adv.Communities) > 0:
+		return "communities is a bgp only attribute and can't be set on a layer2 pool"
+	case adv.AggregationLength != nil || adv.AggregationLengthV6 != nil:
+		return "aggregation length is a bgp only attribute and can't be set on a layer2 pool"
+	case adv.NextHop != "":
+		return "next-hop is a bgp only attribute and can't be set on a layer2 pool"
+	case len(adv.NodeSelectors) > 0:
+		return "node-selectors of a bgp advertisement can't be set on a layer2 pool"
+	case adv.BlackholeCommunity != "":
+		return "blackhole-community is a bgp only attribute and can't be set on a layer2 pool"
+	}
+	return "cannot have bgp-advertisements configuration element in a layer2 address pool"
+}
+
+// validateNextHop checks that the next hop is an IP of the same family
+// as the addresses of the pool.
+func validateNextHop(ap addressPool, nextHop string) error {
+	invalid := func(reason string) error {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ap.Name,
+			Reason: fmt.Sprintf("pool %s: invalid next-hop %q: %s", ap.Name, nextHop, reason),
+		}
+	}
+	ip := net.ParseIP(nextHop)
+	if ip == nil {
+		return invalid("not a valid IP")
+	}
+	hasV4, hasV6 := false, false
+	for _, addr := range ap.Addresses {
+		// invalid addresses are reported later, when the resources are parsed.
+		cidrs, err := config.ParseCIDR(addr)
+		if err != nil {
+			continue
+		}
+		for _, cidr := range cidrs {
+			if cidr.IP.To4() != nil {
+				hasV4 = true
+				continue
+			}
+			hasV6 = true
+		}
+	}
+	if hasV4 && hasV6 {
+		return invalid("a single next-hop can't be set on a dual-stack pool")
+	}
+	isV4 := ip.To4() != nil
+	if (isV4 && hasV6) || (!isV4 && hasV4) {
+		return invalid("must be of the same family as the pool")
+	}
+	return nil
+}
+
+// aggregationLengthV6For returns the IPv6 aggregation length of the given advertisement.
+// When only the IPv4 aggregation length is set on a dual-stack pool, the IPv6 one is
+// derived keeping the same number of host bits if the ConfigMap opts in via the
+// deriveAggregationLengthV6Annotation, otherwise a warning is logged and the
+// advertisement is left as is.
+func aggregationLengthV6For(c *configFile, ap addressPool, bgpAdv bgpAdvertisement) *int32 {
+	if bgpAdv.AggregationLength == nil || bgpAdv.AggregationLengthV6 != nil {
+		return bgpAdv.AggregationLengthV6
+	}
+	lowestV6, dualStack := dualStackV6Mask(ap.Addresses)
+	if !dualStack {
+		return nil
+	}
+	if c.annotations[deriveAggregationLengthV6Annotation] != "true" {
+		c.warn(ap.Name, "pool %s is dual-stack but only the IPv4 aggregation length is set, "+
+			"IPv6 addresses will be advertised with /128", ap.Name)
+		return nil
+	}
+
+	derived := *bgpAdv.AggregationLength + 96
+	if derived < lowestV6 {
+		derived = lowestV6
+	}
+	log.Printf("Deriving IPv6 aggregation length %d for pool %s", derived, ap.Name)
+	return &derived
+}
+
+// validateDualStack checks that a pool requiring to be dual-stack
+// contains both IPv4 and IPv6 addresses.
+func validateDualStack(ap addressPool) error {
+	if !ap.RequireDualStack {
+		return nil
+	}
+	if _, dualStack := dualStackV6Mask(ap.Addresses); !dualStack {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ap.Name,
+			Reason: fmt.Sprintf("pool %s requires dual-stack but doesn't contain both IPv4 and IPv6 addresses", ap.Name),
+		}
+	}
+	return nil
+}
+
+// dualStackV6Mask tells if the given addresses contain both IPv4 and IPv6
+// addresses, and returns the lowest mask among the IPv6 ones.
+func dualStackV6Mask(addresses []string) (int32, bool) {
+	hasV4 := false
+	lowestV6 := int32(-1)
+	for _, addr := range addresses {
+		// invalid addresses are reported later, when the resources are parsed.
+		cidrs, err := config.ParseCIDR(addr)
+		if err != nil {
+			continue
+		}
+		for _, cidr := range cidrs {
+			if cidr.IP.To4() != nil {
+				hasV4 = true
+				continue
+			}
+			ones, _ := cidr.Mask.Size()
+			if lowestV6 == -1 || int32(ones) < lowestV6 {
+				lowestV6 = int32(ones)
+			}
+		}
+	}
+	return lowestV6, hasV4 && lowestV6 != -1
+}
+
+// bgpAdvName returns the name of the index-th BGPAdvertisement of the given pool.
+func bgpAdvName(addressPoolName string, index int) string {
+	return fmt.Sprintf("%s-bgp-%d", addressPoolName, index)
 }
 
-func emptyBGPAdv(addressPoolName string, index int) v1beta1.BGPAdvertisement {
+func emptyBGPAdv(addressPoolName string, index int, namespace string) v1beta1.BGPAdvertisement {
 	return v1beta1.BGPAdvertisement{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("bgpadvertisement%d", index),
-			Namespace: resourcesNameSpace,
+			Name:      bgpAdvName(addressPoolName, index),
+			Namespace: namespace,
 		},
 		Spec: v1beta1.BGPAdvertisementSpec{
 			IPAddressPools: []string{addressPoolName},
@@ -507,29 +2676,134 @@ func emptyBGPAdv(addressPoolName string, index int) v1beta1.BGPAdvertisement {
 	}
 }
 
-func l2AdvertisementsFor(c *configFile) []v1beta1.L2Advertisement {
+func l2AdvertisementsFor(c *configFile, namespace string) ([]v1beta1.L2Advertisement, error) {
 	res := make([]v1beta1.L2Advertisement, 0)
-	index := 1
 	for _, addresspool := range c.Pools {
-		if addresspool.Protocol == Layer2 {
+		if addresspool.Protocol.announcesLayer2() {
 			l2Adv := v1beta1.L2Advertisement{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:      fmt.Sprintf("l2advertisement%d", index),
-					Namespace: resourcesNameSpace,
+					Name:      fmt.Sprintf("%s-l2-0", addresspool.Name),
+					Namespace: namespace,
 				},
 				Spec: v1beta1.L2AdvertisementSpec{
 					IPAddressPools: []string{addresspool.Name},
 				},
 			}
-			index++
+			l2Adv.Spec.Interfaces = append(l2Adv.Spec.Interfaces, addresspool.Interfaces...)
+			for _, sel := range addresspool.NodeSelectors {
+				l2Adv.Spec.NodeSelectors = append(l2Adv.Spec.NodeSelectors, parseNodeSelector(sel))
+			}
+			l2Adv.Spec.NodeSelectionPolicy = addresspool.NodeSelection
 			res = append(res, l2Adv)
 		}
 	}
-	return res
+	return res, nil
+}
+
+// validateLayer2Attributes checks that the interfaces, the node selectors
+// and the node selection policy of the pool are valid, and that they are
+// set only on the pools announced via layer2.
+func validateLayer2Attributes(ap addressPool) error {
+	invalid := func(reason string) error {
+		return &config.ConversionError{
+			Kind:   config.ValidationError,
+			Name:   ap.Name,
+			Reason: fmt.Sprintf("pool %s: %s", ap.Name, reason),
+		}
+	}
+	if !ap.Protocol.announcesLayer2() {
+		switch {
+		case len(ap.Interfaces) > 0:
+			return invalid("interfaces is a layer2 only attribute and can't be set on a bgp pool")
+		case len(ap.NodeSelectors) > 0:
+			return invalid("node-selectors is a layer2 only attribute and can't be set on a bgp pool")
+		case ap.NodeSelection != "":
+			return invalid("node-selection-policy is a layer2 only attribute and can't be set on a bgp pool")
+		}
+		return nil
+	}
+	for _, intf := range ap.Interfaces {
+		if strings.TrimSpace(intf) == "" {
+			return invalid("interface names can't be empty")
+		}
+	}
+	for _, sel := range ap.NodeSelectors {
+		s := parseNodeSelector(sel)
+		if _, err := metav1.LabelSelectorAsSelector(&s); err != nil {
+			return invalid(fmt.Sprintf("invalid node selector: %s", err))
+		}
+	}
+	return validateNodeSelectionPolicy(ap)
+}
+
+// validateBGPAndLayer2 checks that a pool announced both via BGP and via
+// layer2 gets a BGP advertisement, as it would be announced only via layer2
+// otherwise.
+func validateBGPAndLayer2(ap addressPool) error {
+	if ap.Protocol != BGPAndLayer2 || !ap.SkipDefaultAdv || len(ap.BGPAdvertisements) > 0 {
+		return nil
+	}
+	return &config.ConversionError{
+		Kind:   config.ValidationError,
+		Name:   ap.Name,
+		Reason: fmt.Sprintf("pool %s: protocol %s with skip-default-advertisement needs bgp-advertisements, use %s to announce it only via layer2", ap.Name, BGPAndLayer2, Layer2),
+	}
+}
+
+// validateNodeSelectionPolicy checks that the policy choosing the node announcing
+// the IPs of the pool is one of the known ones. Empty keeps the default one.
+func validateNodeSelectionPolicy(ap addressPool) error {
+	switch ap.NodeSelection {
+	case "", config.NodeSelectionHash, config.NodeSelectionLeader:
+		return nil
+	}
+	return &config.ConversionError{
+		Kind:   config.ValidationError,
+		Name:   ap.Name,
+		Reason: fmt.Sprintf("pool %s: invalid node-selection-policy %q, must be %s or %s", ap.Name, ap.NodeSelection, config.NodeSelectionHash, config.NodeSelectionLeader),
+	}
 }
 
 func createResourcesYAMLs(w io.Writer, resources config.ClusterResources) error {
-	objects := resourcesToObjects(resources)
+	return writeObjects(w, resourcesToObjects(resources))
+}
+
+// createKustomization writes the given resources to the given directory as
+// a kustomize base: a file for each kind of resource, skipping the kinds with
+// no resources, and a kustomization.yaml listing them.
+func createKustomization(dir string, resources config.ClusterResources) error {
+	files := []string{}
+	for _, k := range objectsByKind(resources) {
+		if len(k.objects) == 0 {
+			continue
+		}
+		buf := bytes.NewBufferString(autoGenComment)
+		if err := writeObjects(buf, k.objects); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, k.fileName), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		files = append(files, k.fileName)
+	}
+
+	kustomization := struct {
+		APIVersion string   `json:"apiVersion"`
+		Kind       string   `json:"kind"`
+		Resources  []string `json:"resources"`
+	}{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  files,
+	}
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, kustomizationFileName), append([]byte(autoGenComment), data...), 0644)
+}
+
+func writeObjects(w io.Writer, objects []runtime.Object) error {
 	schema, err := initSchema()
 	if err != nil {
 		return err
@@ -567,25 +2841,64 @@ func createResourcesYAMLs(w io.Writer, resources config.ClusterResources) error
 
 func resourcesToObjects(resources config.ClusterResources) []runtime.Object {
 	objects := make([]runtime.Object, 0)
+	for _, k := range objectsByKind(resources) {
+		objects = append(objects, k.objects...)
+	}
+	return objects
+}
+
+// kindObjects are the generated objects of a kind, with the name of the
+// file they are written to with the kustomize output format.
+type kindObjects struct {
+	fileName string
+	objects  []runtime.Object
+}
+
+func objectsByKind(resources config.ClusterResources) []kindObjects {
+	peers := make([]runtime.Object, 0)
 	for _, peer := range resources.Peers {
-		objects = append(objects, peer.DeepCopy())
+		peers = append(peers, peer.DeepCopy())
 	}
+	pools := make([]runtime.Object, 0)
 	for _, p := range resources.Pools {
-		objects = append(objects, p.DeepCopy())
+		pools = append(pools, p.DeepCopy())
 	}
+	bgpAdvs := make([]runtime.Object, 0)
 	for _, bgpAdv := range resources.BGPAdvs {
-		objects = append(objects, bgpAdv.DeepCopy())
+		bgpAdvs = append(bgpAdvs, bgpAdv.DeepCopy())
 	}
+	l2Advs := make([]runtime.Object, 0)
 	for _, l2Adv := range resources.L2Advs {
-		objects = append(objects, l2Adv.DeepCopy())
+		l2Advs = append(l2Advs, l2Adv.DeepCopy())
 	}
+	bfdProfiles := make([]runtime.Object, 0)
 	for _, b := range resources.BFDProfiles {
-		objects = append(objects, b.DeepCopy())
+		bfdProfiles = append(bfdProfiles, b.DeepCopy())
 	}
+	communities := make([]runtime.Object, 0)
 	for _, c := range resources.Communities {
-		objects = append(objects, c.DeepCopy())
+		communities = append(communities, c.DeepCopy())
+	}
+	// in order to make the rendering stable, the secrets are sorted by name.
+	secretNames := make([]string, 0, len(resources.PasswordSecrets))
+	for n := range resources.PasswordSecrets {
+		secretNames = append(secretNames, n)
+	}
+	sort.Strings(secretNames)
+	secrets := make([]runtime.Object, 0)
+	for _, n := range secretNames {
+		s := resources.PasswordSecrets[n]
+		secrets = append(secrets, s.DeepCopy())
+	}
+	return []kindObjects{
+		{fileName: "bgppeers.yaml", objects: peers},
+		{fileName: "ipaddresspools.yaml", objects: pools},
+		{fileName: "bgpadvertisements.yaml", objects: bgpAdvs},
+		{fileName: "l2advertisements.yaml", objects: l2Advs},
+		{fileName: "bfdprofiles.yaml", objects: bfdProfiles},
+		{fileName: "communities.yaml", objects: communities},
+		{fileName: "secrets.yaml", objects: secrets},
 	}
-	return objects
 }
 
 func initSchema() (*runtime.Scheme, error) {
@@ -8,6 +8,6 @@ import (
 
 func FuzzDecodeConfigFile(f *testing.F) {
 	f.Fuzz(func(t *testing.T, input []byte) {
-		_, _ = decodeConfigFile(input)
+		_, _ = decodeConfigFile(input, defaultOptions())
 	})
 }
@@ -2,26 +2,75 @@
 
 package main
 
+import "fmt"
+
 type configFile struct {
 	Peers          []peer
 	BGPCommunities map[string]string `json:"bgp-communities"`
 	Pools          []addressPool     `json:"address-pools"`
 	BFDProfiles    []bfdProfile      `json:"bfd-profiles"`
+	// DefaultCommunity is applied to the BGP advertisements that
+	// don't have any community.
+	DefaultCommunity string `json:"default-community"`
+	// NamespaceCommunities maps a namespace to the communities to be
+	// advertised for the pools allocated to that namespace.
+	NamespaceCommunities map[string][]string `json:"namespace-communities"`
+	// annotations are the annotations of the ConfigMap the config
+	// was read from, if any.
+	annotations map[string]string
+	// namespace is the namespace of the ConfigMap the config was
+	// read from, if any.
+	namespace string
+	// warnings are the non fatal issues found while converting the config.
+	warnings []Warning
+}
+
+// Warning is a non fatal issue found while converting the configuration,
+// such as a value that is adjusted or an element that has no effect.
+type Warning struct {
+	// Element is the name of the element of the configuration the warning
+	// is about, e.g. the name of a pool.
+	Element string
+	Message string
+}
+
+// warn records a warning about the given element of the configuration.
+func (c *configFile) warn(element, format string, args ...interface{}) {
+	c.warnings = append(c.warnings, Warning{Element: element, Message: fmt.Sprintf(format, args...)})
 }
 
 type peer struct {
-	MyASN         uint32         `json:"my-asn"`
-	ASN           uint32         `json:"peer-asn"`
-	Addr          string         `json:"peer-address"`
-	SrcAddr       string         `json:"source-address"`
-	Port          uint16         `json:"peer-port"`
-	HoldTime      string         `json:"hold-time"`
-	KeepaliveTime string         `json:"keepalive-time"`
-	RouterID      string         `json:"router-id"`
-	NodeSelectors []nodeSelector `json:"node-selectors"`
-	Password      string         `json:"password"`
-	BFDProfile    string         `json:"bfd-profile"`
-	EBGPMultiHop  bool           `json:"ebgp-multihop"`
+	MyASN           uint32           `json:"my-asn"`
+	ASN             uint32           `json:"peer-asn"`
+	DynamicASN      string           `json:"dynamic-asn"`
+	Addr            string           `json:"peer-address"`
+	Interface       string           `json:"interface"`
+	SrcAddr         string           `json:"source-address"`
+	SrcAddrs        []string         `json:"source-addresses"`
+	Port            int              `json:"peer-port"`
+	HoldTime        string           `json:"hold-time"`
+	KeepaliveTime   string           `json:"keepalive-time"`
+	ConnectTime     string           `json:"connect-time"`
+	RouterID        string           `json:"router-id"`
+	NodeSelectors   []nodeSelector   `json:"node-selectors"`
+	Password        string           `json:"password"`
+	AuthAlgorithm   string           `json:"auth-algorithm"`
+	AuthKeyID       int              `json:"auth-key-id"`
+	BFDProfile      string           `json:"bfd-profile"`
+	EBGPMultiHop    bool             `json:"ebgp-multihop"`
+	EBGPMultiHopTTL int              `json:"ebgp-multihop-ttl"`
+	TTLSecurity     bool             `json:"ttl-security"`
+	BGPRole         string           `json:"bgp-role"`
+	TCPMSS          *int             `json:"tcp-mss"`
+	GracefulRestart *gracefulRestart `json:"graceful-restart"`
+	VRFName         string           `json:"vrf"`
+	NoDefaultVRF    bool             `json:"disable-default-vrf"`
+	Disabled        bool             `json:"disabled"`
+}
+
+type gracefulRestart struct {
+	Enabled     bool   `json:"enabled"`
+	RestartTime string `json:"restart-time"`
 }
 
 type nodeSelector struct {
@@ -36,12 +85,26 @@ type selectorRequirements struct {
 }
 
 type addressPool struct {
-	Protocol          Proto              `json:"protocol"`
-	Name              string             `json:"name"`
-	Addresses         []string           `json:"addresses"`
-	AutoAssign        *bool              `json:"auto-assign"`
-	AvoidBuggyIPs     *bool              `json:"avoid-buggy-ips"`
-	BGPAdvertisements []bgpAdvertisement `json:"bgp-advertisements"`
+	Protocol           Proto              `json:"protocol"`
+	Name               string             `json:"name"`
+	Addresses          []string           `json:"addresses"`
+	AutoAssign         *bool              `json:"auto-assign"`
+	AvoidBuggyIPs      *bool              `json:"avoid-buggy-ips"`
+	BGPAdvertisements  []bgpAdvertisement `json:"bgp-advertisements"`
+	DSCP               *int               `json:"dscp"`
+	ToS                *int               `json:"tos"`
+	Namespaces         []string           `json:"namespaces"`
+	NamespaceSelectors []nodeSelector     `json:"namespace-selectors"`
+	ServiceSelectors   []nodeSelector     `json:"service-selectors"`
+	Priority           int                `json:"priority"`
+	RequireDualStack   bool               `json:"require-dual-stack"`
+	Interfaces         []string           `json:"interfaces"`
+	NodeSelectors      []nodeSelector     `json:"node-selectors"`
+	SkipDefaultAdv     bool               `json:"skip-default-advertisement"`
+	NodeSelection      string             `json:"node-selection-policy"`
+	ExternalBlock      string             `json:"external-block"`
+	Algorithm          string             `json:"allocation-algorithm"`
+	BlackholeCommunity string             `json:"blackhole-community"`
 }
 
 // Proto holds the protocol we are speaking.
@@ -51,22 +114,39 @@ type Proto string
 const (
 	BGP    Proto = "bgp"
 	Layer2 Proto = "layer2"
+	// BGPAndLayer2 pools are announced both via BGP and via layer2.
+	BGPAndLayer2 Proto = "bgp+layer2"
 )
 
+// announcesBGP tells if the pools with the protocol are announced via BGP.
+func (p Proto) announcesBGP() bool {
+	return p == BGP || p == BGPAndLayer2
+}
+
+// announcesLayer2 tells if the pools with the protocol are announced via layer2.
+func (p Proto) announcesLayer2() bool {
+	return p == Layer2 || p == BGPAndLayer2
+}
+
 type bgpAdvertisement struct {
-	AggregationLength   *int32   `json:"aggregation-length"`
-	AggregationLengthV6 *int32   `json:"aggregation-length-v6"`
-	LocalPref           uint32   `json:"localpref"`
-	Communities         []string `json:"communities"`
+	AggregationLength   *int32         `json:"aggregation-length"`
+	AggregationLengthV6 *int32         `json:"aggregation-length-v6"`
+	LocalPref           uint32         `json:"localpref"`
+	Communities         []string       `json:"communities"`
+	NextHop             string         `json:"next-hop"`
+	GracefulShutdown    bool           `json:"graceful-shutdown"`
+	NodeSelectors       []nodeSelector `json:"node-selectors"`
+	BlackholeCommunity  string         `json:"blackhole-community"`
 }
 
 type bfdProfile struct {
-	Name             string  `json:"name"`
-	ReceiveInterval  *uint32 `json:"receive-interval"`
-	TransmitInterval *uint32 `json:"transmit-interval"`
-	DetectMultiplier *uint32 `json:"detect-multiplier"`
-	EchoInterval     *uint32 `json:"echo-interval"`
-	EchoMode         bool    `json:"echo-mode"`
-	PassiveMode      bool    `json:"passive-mode"`
-	MinimumTTL       *uint32 `json:"minimum-ttl"`
+	Name                string  `json:"name"`
+	ReceiveInterval     *uint32 `json:"receive-interval"`
+	TransmitInterval    *uint32 `json:"transmit-interval"`
+	DetectMultiplier    *uint32 `json:"detect-multiplier"`
+	EchoInterval        *uint32 `json:"echo-interval"`
+	EchoReceiveInterval *uint32 `json:"echo-receive-interval"`
+	EchoMode            bool    `json:"echo-mode"`
+	PassiveMode         bool    `json:"passive-mode"`
+	MinimumTTL          *uint32 `json:"minimum-ttl"`
 }
@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
+	"time"
 
 	"go.universe.tf/metallb/internal/allocator"
 	"go.universe.tf/metallb/internal/config"
@@ -143,6 +145,7 @@ func main() {
 		logLevel            = flag.String("log-level", "info", fmt.Sprintf("log level. must be one of: [%s]", logging.Levels.String()))
 		disableEpSlices     = flag.Bool("disable-epslices", false, "Disable the usage of EndpointSlices and default to Endpoints instead of relying on the autodiscovery mechanism")
 		enablePprof         = flag.Bool("enable-pprof", false, "Enable pprof profiling")
+		enableConfigDump    = flag.Bool("enable-config-dump", false, "Expose the applied configuration on /debug/config of the metrics endpoint")
 		disableCertRotation = flag.Bool("disable-cert-rotation", false, "disable automatic generation and rotation of webhook TLS certificates/keys")
 		certDir             = flag.String("cert-dir", "/tmp/k8s-webhook-server/serving-certs", "The directory where certs are stored")
 		certServiceName     = flag.String("cert-service-name", "webhook-service", "The service name used to generate the TLS cert's hostname")
@@ -150,6 +153,14 @@ func main() {
 		webhookMode         = flag.String("webhook-mode", "enabled", "webhook mode: can be enabled, disabled or only webhook if we want the controller to act as webhook endpoint only")
 		webhookSecretName   = flag.String("webhook-secret", "webhook-server-cert", "webhook secret: the name of webhook secret, default is webhook-server-cert")
 		webhookHTTP2        = flag.Bool("webhook-http2", false, "enables http2 for the webhook endpoint")
+		poolResyncPeriod    = flag.Duration("pool-resync-period", 0, "interval after which the pools are pushed again even if nothing changed, 0 disables it")
+		poolDryRun          = flag.Bool("pool-dry-run", false, "only log how the pools would change, without applying the configuration")
+		poolObserveOnly     = flag.Bool("pool-observe-only", false, "keep tracking the pools as if they were applied, only logging how they change, without applying the configuration")
+		poolRetryBaseDelay  = flag.Duration("pool-retry-base-delay", 0, "initial delay before retrying when applying the pools fails, doubled at each consecutive failure. 0 uses the default backoff")
+		poolRetryMaxDelay   = flag.Duration("pool-retry-max-delay", 5*time.Minute, "maximum delay before retrying when applying the pools fails")
+		poolRetryJitter     = flag.Float64("pool-retry-jitter", 0, "maximum random increase of the pool retry delay, as a fraction of it (e.g. 0.2 for up to 20%)")
+		legacyPrecedence    = flag.Bool("legacy-precedence", false, "when an AddressPool and an IPAddressPool have the same name, use the AddressPool instead of the IPAddressPool")
+		poolWatchNamespaces = flag.String("pool-watch-namespaces", "", "comma separated list of the namespaces the pools can be allocated to via namespace selectors. Empty means all the namespaces")
 	)
 	flag.Parse()
 
@@ -182,11 +193,12 @@ func main() {
 	validation := config.ValidationFor(bgpType)
 
 	cfg := &k8s.Config{
-		ProcessName:     "metallb-controller",
-		MetricsPort:     *port,
-		EnablePprof:     *enablePprof,
-		Logger:          logger,
-		DisableEpSlices: *disableEpSlices,
+		ProcessName:      "metallb-controller",
+		MetricsPort:      *port,
+		EnablePprof:      *enablePprof,
+		EnableConfigDump: *enableConfigDump,
+		Logger:           logger,
+		DisableEpSlices:  *disableEpSlices,
 
 		Namespace: *namespace,
 		Listener: k8s.Listener{
@@ -201,6 +213,17 @@ func main() {
 		CertDir:             *certDir,
 		CertServiceName:     *certServiceName,
 		LoadBalancerClass:   *loadBalancerClass,
+		PoolResyncPeriod:    *poolResyncPeriod,
+		PoolDryRun:          *poolDryRun,
+		PoolObserveOnly:     *poolObserveOnly,
+		PoolRetryBaseDelay:  *poolRetryBaseDelay,
+		PoolRetryMaxDelay:   *poolRetryMaxDelay,
+		PoolRetryJitter:     *poolRetryJitter,
+		LegacyPrecedence:    *legacyPrecedence,
+		PoolsInUse:          c.ips.PoolsInUse,
+	}
+	if *poolWatchNamespaces != "" {
+		cfg.PoolWatchNamespaces = strings.Split(*poolWatchNamespaces, ",")
 	}
 	switch *webhookMode {
 	case "enabled":
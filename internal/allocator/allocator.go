@@ -27,6 +27,9 @@ type Allocator struct {
 	portsInUse      map[string]map[Port]string // ip.String() -> Port -> svc
 	servicesOnIP    map[string]map[string]bool // ip.String() -> svc -> allocated?
 	poolIPsInUse    map[string]map[string]int  // poolName -> ip.String() -> number of users
+
+	// poolName -> family -> last IP allocated, used by the round robin pools.
+	lastAllocated map[string]map[ipfamily.Family]net.IP
 }
 
 // Port represents one port in use by a service.
@@ -62,6 +65,7 @@ func New() *Allocator {
 		portsInUse:      map[string]map[Port]string{},
 		servicesOnIP:    map[string]map[string]bool{},
 		poolIPsInUse:    map[string]map[string]int{},
+		lastAllocated:   map[string]map[ipfamily.Family]net.IP{},
 	}
 }
 
@@ -72,6 +76,7 @@ func (a *Allocator) SetPools(pools *config.Pools) {
 			stats.poolCapacity.DeleteLabelValues(n)
 			stats.poolActive.DeleteLabelValues(n)
 			stats.poolAllocated.DeleteLabelValues(n)
+			delete(a.lastAllocated, n)
 		}
 	}
 
@@ -239,16 +244,36 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 		ipfamilySel[serviceIPFamily] = true
 	}
 
-	for _, cidr := range pool.CIDR {
-		cidrIPFamily := ipfamily.ForCIDR(cidr)
-		if _, ok := ipfamilySel[cidrIPFamily]; !ok {
-			// Not the right ip-family
-			continue
+	if pool.AllocationAlgorithm == config.AllocationRoundRobin {
+		// Go through all the cidrs of each family, starting after the last
+		// IP allocated from the pool.
+		cidrsByFamily := map[ipfamily.Family][]*net.IPNet{}
+		for _, cidr := range pool.CIDR {
+			cidrIPFamily := ipfamily.ForCIDR(cidr)
+			cidrsByFamily[cidrIPFamily] = append(cidrsByFamily[cidrIPFamily], cidr)
 		}
-		ip := a.getIPFromCIDR(cidr, pool.AvoidBuggyIPs, svcKey, ports, sharingKey, backendKey)
-		if ip != nil {
-			ips = append(ips, ip)
-			delete(ipfamilySel, cidrIPFamily)
+		for _, family := range []ipfamily.Family{ipfamily.IPv4, ipfamily.IPv6} {
+			if _, ok := ipfamilySel[family]; !ok || len(cidrsByFamily[family]) == 0 {
+				continue
+			}
+			ip := a.getIPFromCIDRs(cidrsByFamily[family], a.lastAllocated[poolName][family], pool.AvoidBuggyIPs, svcKey, ports, sharingKey, backendKey)
+			if ip != nil {
+				ips = append(ips, ip)
+				delete(ipfamilySel, family)
+			}
+		}
+	} else {
+		for _, cidr := range pool.CIDR {
+			cidrIPFamily := ipfamily.ForCIDR(cidr)
+			if _, ok := ipfamilySel[cidrIPFamily]; !ok {
+				// Not the right ip-family
+				continue
+			}
+			ip := a.getIPFromCIDRs([]*net.IPNet{cidr}, nil, pool.AvoidBuggyIPs, svcKey, ports, sharingKey, backendKey)
+			if ip != nil {
+				ips = append(ips, ip)
+				delete(ipfamilySel, cidrIPFamily)
+			}
 		}
 	}
 
@@ -260,6 +285,12 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 	if err != nil {
 		return nil, err
 	}
+	if a.lastAllocated[poolName] == nil {
+		a.lastAllocated[poolName] = map[ipfamily.Family]net.IP{}
+	}
+	for _, ip := range ips {
+		a.lastAllocated[poolName][ipfamily.ForAddress(ip)] = ip
+	}
 	return ips, nil
 }
 
@@ -277,10 +308,7 @@ func (a *Allocator) Allocate(svcKey string, svc *v1.Service, serviceIPFamily ipf
 			return ips, nil
 		}
 	}
-	for _, pool := range a.pools.ByName {
-		if !pool.AutoAssign || pool.ServiceAllocations != nil {
-			continue
-		}
+	for _, pool := range a.unpinnedPools() {
 		if ips, err := a.AllocateFromPool(svcKey, svc, serviceIPFamily, pool.Name, ports, sharingKey, backendKey); err == nil {
 			return ips, nil
 		}
@@ -317,6 +345,27 @@ func (a *Allocator) pinnedPoolsForService(svc *v1.Service) []*config.Pool {
 	return pools
 }
 
+// unpinnedPools returns the auto assignable pools not pinned to any
+// namespace or service, sorted by allocation priority. Pools with no priority
+// come after the ones with priority, and pools with the same priority are
+// returned in random order.
+func (a *Allocator) unpinnedPools() []*config.Pool {
+	var pools []*config.Pool
+	for _, pool := range a.pools.ByName {
+		if !pool.AutoAssign || pool.ServiceAllocations != nil {
+			continue
+		}
+		pools = append(pools, pool)
+	}
+	sort.SliceStable(pools, func(i, j int) bool {
+		if pools[i].AllocationPriority > 0 && pools[j].AllocationPriority > 0 {
+			return pools[i].AllocationPriority < pools[j].AllocationPriority
+		}
+		return pools[i].AllocationPriority > 0 && pools[j].AllocationPriority == 0
+	})
+	return pools
+}
+
 func (a *Allocator) isPoolCompatibleWithService(p *config.Pool, svc *v1.Service) bool {
 	if p.ServiceAllocations != nil && p.ServiceAllocations.Namespaces.Len() > 0 &&
 		!p.ServiceAllocations.Namespaces.Has(svc.Namespace) {
@@ -343,6 +392,19 @@ func (a *Allocator) Pool(svc string) string {
 	return ""
 }
 
+// PoolsInUse returns the sorted names of the pools having at least one
+// IP allocated to a service.
+func (a *Allocator) PoolsInUse() []string {
+	res := []string{}
+	for pool, ips := range a.poolIPsInUse {
+		if len(ips) > 0 {
+			res = append(res, pool)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
 // IPs returns the allocated IPs of a service.
 func (a *Allocator) IPs(svc string) []net.IP {
 	if alloc := a.allocated[svc]; alloc != nil {
@@ -359,18 +421,18 @@ func (a *Allocator) PoolForIP(ips []net.IP) *config.Pool {
 func sortPools(pools []*config.Pool) {
 	// A lower value for pool priority equals a higher priority and sort
 	// pools from higher to low priority. when no priority (0) set on
-	// the pool, then that is considered as lowest priority.
+	// the pool, then that is considered as lowest priority. Pools with
+	// the same priority are sorted by name, so that a service matched by
+	// more than one of them always gets the same pool.
 	sort.Slice(pools, func(i, j int) bool {
-		if pools[i].ServiceAllocations.Priority > 0 &&
-			pools[j].ServiceAllocations.Priority > 0 {
-			return pools[i].ServiceAllocations.Priority <
-				pools[j].ServiceAllocations.Priority
-		}
-		if pools[i].ServiceAllocations.Priority == 0 &&
-			pools[j].ServiceAllocations.Priority > 0 {
-			return false
+		pi, pj := pools[i].ServiceAllocations.Priority, pools[j].ServiceAllocations.Priority
+		if pi != pj {
+			if pi == 0 || pj == 0 {
+				return pj == 0
+			}
+			return pi < pj
 		}
-		return true
+		return pools[i].Name < pools[j].Name
 	})
 }
 
@@ -462,20 +524,50 @@ func ipConfusesBuggyFirmwares(ip net.IP) bool {
 	return ip[3] == 0 || ip[3] == 255
 }
 
-func (a *Allocator) getIPFromCIDR(cidr *net.IPNet, avoidBuggyIPs bool, svc string, ports []Port, sharingKey, backendKey string) net.IP {
+// getIPFromCIDRs returns the first available IP of the cidrs. If after is
+// set, the search starts right after it and wraps around to the first IP.
+func (a *Allocator) getIPFromCIDRs(cidrs []*net.IPNet, after net.IP, avoidBuggyIPs bool, svc string, ports []Port, sharingKey, backendKey string) net.IP {
 	sk := &key{
 		sharing: sharingKey,
 		backend: backendKey,
 	}
-	c := ipaddr.NewCursor([]ipaddr.Prefix{*ipaddr.NewPrefix(cidr)})
+	prefixes := []ipaddr.Prefix{}
+	for _, cidr := range cidrs {
+		prefixes = append(prefixes, *ipaddr.NewPrefix(cidr))
+	}
+	available := func(ip net.IP) bool {
+		if avoidBuggyIPs && ipConfusesBuggyFirmwares(ip) {
+			return false
+		}
+		return a.checkSharing(svc, ip.String(), ports, sk) == nil
+	}
+
+	if after != nil {
+		c := ipaddr.NewCursor(prefixes)
+		for _, p := range c.List() {
+			if !p.IPNet.Contains(after) {
+				continue
+			}
+			if c.Set(&ipaddr.Position{IP: after, Prefix: p}) != nil {
+				break
+			}
+			for pos := c.Next(); pos != nil; pos = c.Next() {
+				if available(pos.IP) {
+					return pos.IP
+				}
+			}
+			break
+		}
+	}
+
+	c := ipaddr.NewCursor(prefixes)
 	for pos := c.First(); pos != nil; pos = c.Next() {
-		if avoidBuggyIPs && ipConfusesBuggyFirmwares(pos.IP) {
-			continue
+		if available(pos.IP) {
+			return pos.IP
 		}
-		if a.checkSharing(svc, pos.IP.String(), ports, sk) != nil {
-			continue
+		if after != nil && pos.IP.Equal(after) {
+			break
 		}
-		return pos.IP
 	}
 	return nil
 }
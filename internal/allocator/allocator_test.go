@@ -1885,6 +1885,170 @@ func TestAutoAssign(t *testing.T) {
 	}
 }
 
+func TestAllocationPriority(t *testing.T) {
+	alloc := New()
+	alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"premium": {
+			Name:               "premium",
+			AutoAssign:         true,
+			AllocationPriority: 20,
+			CIDR:               []*net.IPNet{ipnet("1.2.3.10/31")},
+		},
+		"cheap": {
+			Name:               "cheap",
+			AutoAssign:         true,
+			AllocationPriority: 10,
+			CIDR:               []*net.IPNet{ipnet("1.2.3.4/31")},
+		},
+		"no-priority": {
+			Name:       "no-priority",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.20/31")},
+		},
+	}})
+
+	tests := []struct {
+		svcKey       string
+		expectedPool string
+		wantErr      bool
+	}{
+		{svcKey: "s1", expectedPool: "cheap"},
+		{svcKey: "s2", expectedPool: "cheap"},
+		{svcKey: "s3", expectedPool: "premium"},
+		{svcKey: "s4", expectedPool: "premium"},
+		{svcKey: "s5", expectedPool: "no-priority"},
+		{svcKey: "s6", expectedPool: "no-priority"},
+		{svcKey: "s7", wantErr: true},
+	}
+
+	for i, test := range tests {
+		_, err := alloc.Allocate(test.svcKey, svc, ipfamily.IPv4, nil, "", "")
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("#%d should have caused an error, but did not", i+1)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d Allocate(%q): %s", i+1, test.svcKey, err)
+		}
+		if pool := alloc.Pool(test.svcKey); pool != test.expectedPool {
+			t.Errorf("#%d expected %q to be allocated from pool %q, got %q", i+1, test.svcKey, test.expectedPool, pool)
+		}
+	}
+
+	// Freeing an IP of the cheap pool makes it the preferred one again.
+	alloc.Unassign("s1")
+	if _, err := alloc.Allocate("s8", svc, ipfamily.IPv4, nil, "", ""); err != nil {
+		t.Fatalf("Allocate(\"s8\"): %s", err)
+	}
+	if pool := alloc.Pool("s8"); pool != "cheap" {
+		t.Fatalf("expected s8 to be allocated from pool cheap, got %q", pool)
+	}
+}
+
+func TestAllocationAlgorithm(t *testing.T) {
+	alloc := New()
+	alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"lowest": {
+			Name:                "lowest",
+			AllocationAlgorithm: config.AllocationLowestFree,
+			CIDR:                []*net.IPNet{ipnet("1.2.3.4/31"), ipnet("1.2.3.10/31")},
+		},
+		"roundrobin": {
+			Name:                "roundrobin",
+			AllocationAlgorithm: config.AllocationRoundRobin,
+			CIDR:                []*net.IPNet{ipnet("1.2.4.4/31"), ipnet("1.2.4.10/31")},
+		},
+	}})
+
+	tests := []struct {
+		svcKey   string
+		unassign string
+		lowest   string
+		rr       string
+	}{
+		{svcKey: "s1", lowest: "1.2.3.4", rr: "1.2.4.4"},
+		{svcKey: "s2", lowest: "1.2.3.5", rr: "1.2.4.5"},
+		// The lowest free pool gives the freed IP back, the round robin
+		// one moves on to the next cidr.
+		{svcKey: "s3", unassign: "s1", lowest: "1.2.3.4", rr: "1.2.4.10"},
+		{svcKey: "s4", lowest: "1.2.3.10", rr: "1.2.4.11"},
+		// The round robin pool wraps around to the freed IP.
+		{svcKey: "s5", lowest: "1.2.3.11", rr: "1.2.4.4"},
+	}
+
+	for i, test := range tests {
+		if test.unassign != "" {
+			alloc.Unassign("lowest-" + test.unassign)
+			alloc.Unassign("rr-" + test.unassign)
+		}
+		for pool, want := range map[string]string{"lowest": test.lowest, "roundrobin": test.rr} {
+			key := "lowest-" + test.svcKey
+			if pool == "roundrobin" {
+				key = "rr-" + test.svcKey
+			}
+			ips, err := alloc.AllocateFromPool(key, svc, ipfamily.IPv4, pool, nil, "", "")
+			if err != nil {
+				t.Fatalf("#%d AllocateFromPool(%q, %q): %s", i+1, key, pool, err)
+			}
+			if len(ips) != 1 || ips[0].String() != want {
+				t.Errorf("#%d expected %q to be allocated %s from pool %q, got %v", i+1, key, want, pool, ips)
+			}
+		}
+	}
+}
+
+func TestSortPools(t *testing.T) {
+	pool := func(name string, priority int) *config.Pool {
+		return &config.Pool{Name: name, ServiceAllocations: &config.ServiceAllocation{Priority: priority}}
+	}
+	expected := []string{"high", "low-a", "low-b", "none-a", "none-b"}
+	// try all the rotations, so the result doesn't depend on the input order.
+	for i := range expected {
+		pools := []*config.Pool{pool("none-b", 0), pool("low-b", 20), pool("high", 10), pool("none-a", 0), pool("low-a", 20)}
+		pools = append(pools[i:], pools[:i]...)
+		sortPools(pools)
+		got := []string{}
+		for _, p := range pools {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Fatalf("expected pools sorted as %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestPoolsInUse(t *testing.T) {
+	alloc := New()
+	alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"pool1": {
+			Name:       "pool1",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.4/32")},
+		},
+		"pool2": {
+			Name:       "pool2",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.10/32")},
+		},
+	}})
+
+	if inUse := alloc.PoolsInUse(); len(inUse) != 0 {
+		t.Fatalf("expected no pools in use, got %v", inUse)
+	}
+	if _, err := alloc.AllocateFromPool("s1", svc, ipfamily.IPv4, "pool2", nil, "", ""); err != nil {
+		t.Fatalf("AllocateFromPool(\"s1\"): %s", err)
+	}
+	if inUse := alloc.PoolsInUse(); !reflect.DeepEqual(inUse, []string{"pool2"}) {
+		t.Fatalf("expected pool2 to be in use, got %v", inUse)
+	}
+	alloc.Unassign("s1")
+	if inUse := alloc.PoolsInUse(); len(inUse) != 0 {
+		t.Fatalf("expected no pools in use after unassign, got %v", inUse)
+	}
+}
+
 func TestPoolCount(t *testing.T) {
 	tests := []struct {
 		desc string
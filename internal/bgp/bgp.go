@@ -25,6 +25,9 @@ type Advertisement struct {
 	// Used to declare the intent of announcing IPs
 	// only to the BGPPeers in this list.
 	Peers []string
+	// The next hop to advertise the prefix with, nil means the
+	// local address of the session.
+	NextHop net.IP
 }
 
 // Equal returns true if a and b are equivalent advertisements.
@@ -35,6 +38,9 @@ func (a *Advertisement) Equal(b *Advertisement) bool {
 	if a.LocalPref != b.LocalPref {
 		return false
 	}
+	if !a.NextHop.Equal(b.NextHop) {
+		return false
+	}
 
 	if !reflect.DeepEqual(a.Peers, b.Peers) {
 		return false
@@ -67,14 +73,24 @@ type SessionParameters struct {
 	MyASN         uint32
 	RouterID      net.IP
 	PeerASN       uint32
+	DynamicASN    string
 	HoldTime      time.Duration
 	KeepAliveTime time.Duration
+	ConnectTime   time.Duration
 	Password      string
 	CurrentNode   string
 	BFDProfile    string
 	EBGPMultiHop  bool
+	TTLSecurity   bool
 	VRFName       string
 	SessionName   string
+	// EBGPMultiHopTTL is the TTL of the packets of a multi-hops session,
+	// zero means the default of the implementation.
+	EBGPMultiHopTTL uint32
+	// GracefulRestart advertises the graceful restart capability, with the
+	// given restart time. A zero time means the default of the implementation.
+	GracefulRestart     bool
+	GracefulRestartTime time.Duration
 }
 type SessionManager interface {
 	NewSession(logger log.Logger, args SessionParameters) (Session, error)
@@ -16,6 +16,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+	metallbconfig "go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/ipfamily"
 )
 
@@ -49,33 +50,42 @@ type routerConfig struct {
 	VRF          string
 	IPV4Prefixes []string
 	IPV6Prefixes []string
+	// RestartTime is the graceful restart time in seconds, zero means
+	// the FRR default.
+	RestartTime uint64
 }
 
 type BFDProfile struct {
-	Name             string
-	ReceiveInterval  *uint32
-	TransmitInterval *uint32
-	DetectMultiplier *uint32
-	EchoInterval     *uint32
-	EchoMode         bool
-	PassiveMode      bool
-	MinimumTTL       *uint32
+	Name                string
+	ReceiveInterval     *uint32
+	TransmitInterval    *uint32
+	DetectMultiplier    *uint32
+	EchoInterval        *uint32
+	EchoReceiveInterval *uint32
+	EchoMode            bool
+	PassiveMode         bool
+	MinimumTTL          *uint32
 }
 
 type neighborConfig struct {
 	IPFamily            ipfamily.Family
 	Name                string
 	ASN                 uint32
+	DynamicASN          string
 	Addr                string
 	SrcAddr             string
 	Port                uint16
 	HoldTime            uint64
 	KeepaliveTime       uint64
+	ConnectTime         uint64
 	Password            string
 	Advertisements      []*advertisementConfig
 	BFDProfile          string
 	EBGPMultiHop        bool
+	EBGPMultiHopTTL     uint32
+	TTLSecurity         bool
 	VRFName             string
+	GracefulRestart     bool
 	HasV4Advertisements bool
 	HasV6Advertisements bool
 }
@@ -93,6 +103,7 @@ type advertisementConfig struct {
 	Communities      []string
 	LargeCommunities []string
 	LocalPref        uint32
+	NextHop          string
 }
 
 // routerName() defines the format of the key of the "Routers" map in the
@@ -128,6 +139,9 @@ func templateConfig(data interface{}) (string, error) {
 			"localPrefPrefixList": func(neighbor *neighborConfig, localPreference uint32) string {
 				return fmt.Sprintf("%s-%d-%s-localpref-prefixes", neighbor.ID(), localPreference, neighbor.IPFamily)
 			},
+			"nextHopPrefixList": func(neighbor *neighborConfig, nextHop string) string {
+				return fmt.Sprintf("%s-%s-%s-nexthop-prefixes", neighbor.ID(), nextHop, neighbor.IPFamily)
+			},
 			"communityPrefixList": func(neighbor *neighborConfig, community string) string {
 				return fmt.Sprintf("%s-%s-%s-community-prefixes", neighbor.ID(), community, neighbor.IPFamily)
 			},
@@ -137,9 +151,10 @@ func templateConfig(data interface{}) (string, error) {
 			"allowedPrefixList": func(neighbor *neighborConfig) string {
 				return fmt.Sprintf("%s-pl-%s", neighbor.ID(), neighbor.IPFamily)
 			},
-			"mustDisableConnectedCheck": func(ipFamily ipfamily.Family, myASN, asn uint32, eBGPMultiHop bool) bool {
+			"mustDisableConnectedCheck": func(ipFamily ipfamily.Family, myASN, asn uint32, dynamicASN string, eBGPMultiHop bool) bool {
 				// return true only for IPv6 eBGP sessions
-				if ipFamily == "ipv6" && myASN != asn && !eBGPMultiHop {
+				ebgp := dynamicASN == metallbconfig.DynamicASNExternal || (dynamicASN == "" && myASN != asn)
+				if ipFamily == "ipv6" && ebgp && !eBGPMultiHop {
 					return true
 				}
 				return false
@@ -209,6 +209,7 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 		vrf          string
 		ipV4Prefixes map[string]string
 		ipV6Prefixes map[string]string
+		restartTime  uint64
 	}
 
 	routers := make(map[string]*router)
@@ -254,17 +255,27 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 			family := ipfamily.ForAddress(net.ParseIP(host))
 
 			neighbor = &neighborConfig{
-				IPFamily:       family,
-				ASN:            s.PeerASN,
-				Addr:           host,
-				Port:           uint16(portUint),
-				HoldTime:       uint64(s.HoldTime / time.Second),
-				KeepaliveTime:  uint64(s.KeepAliveTime / time.Second),
-				Password:       s.Password,
-				Advertisements: make([]*advertisementConfig, 0),
-				BFDProfile:     s.BFDProfile,
-				EBGPMultiHop:   s.EBGPMultiHop,
-				VRFName:        s.VRFName,
+				IPFamily:        family,
+				ASN:             s.PeerASN,
+				DynamicASN:      s.DynamicASN,
+				Addr:            host,
+				Port:            uint16(portUint),
+				HoldTime:        uint64(s.HoldTime / time.Second),
+				KeepaliveTime:   uint64(s.KeepAliveTime / time.Second),
+				ConnectTime:     uint64(s.ConnectTime / time.Second),
+				Password:        s.Password,
+				Advertisements:  make([]*advertisementConfig, 0),
+				BFDProfile:      s.BFDProfile,
+				EBGPMultiHop:    s.EBGPMultiHop,
+				EBGPMultiHopTTL: s.EBGPMultiHopTTL,
+				TTLSecurity:     s.TTLSecurity,
+				VRFName:         s.VRFName,
+				GracefulRestart: s.GracefulRestart,
+			}
+			// FRR sets the graceful restart time per router, so the
+			// longest one of the neighbors sharing the router is used.
+			if restartTime := uint64(s.GracefulRestartTime / time.Second); s.GracefulRestart && restartTime > rout.restartTime {
+				rout.restartTime = restartTime
 			}
 			if s.SourceAddress != nil {
 				neighbor.SrcAddr = s.SourceAddress.String()
@@ -302,6 +313,9 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 				LargeCommunities: sort.StringSlice(largeCommunities),
 				LocalPref:        adv.LocalPref,
 			}
+			if adv.NextHop != nil {
+				advConfig.NextHop = adv.NextHop.String()
+			}
 
 			neighbor.Advertisements = append(neighbor.Advertisements, &advConfig)
 			switch family {
@@ -324,6 +338,7 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 			Neighbors:    sortMap(r.neighbors),
 			IPV4Prefixes: sortMap(r.ipV4Prefixes),
 			IPV6Prefixes: sortMap(r.ipV6Prefixes),
+			RestartTime:  r.restartTime,
 		}
 		config.Routers = append(config.Routers, toAdd)
 	}
@@ -422,6 +437,7 @@ func configBFDProfileToFRR(p *metallbconfig.BFDProfile) *BFDProfile {
 	res.TransmitInterval = p.TransmitInterval
 	res.DetectMultiplier = p.DetectMultiplier
 	res.EchoInterval = p.EchoInterval
+	res.EchoReceiveInterval = p.EchoReceiveInterval
 	res.EchoMode = p.EchoMode
 	res.PassiveMode = p.PassiveMode
 	res.MinimumTTL = p.MinimumTTL
@@ -471,6 +487,9 @@ func sortAdvertiesements(toSort []*advertisementConfig) {
 		if toSort[i].LocalPref != toSort[j].LocalPref {
 			return toSort[i].LocalPref < toSort[j].LocalPref
 		}
+		if toSort[i].NextHop != toSort[j].NextHop {
+			return toSort[i].NextHop < toSort[j].NextHop
+		}
 		if len(toSort[i].Communities) != len(toSort[j].Communities) {
 			return len(toSort[i].Communities) < len(toSort[j].Communities)
 		}
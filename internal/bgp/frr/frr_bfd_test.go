@@ -55,6 +55,31 @@ func TestBFDProfileNoSessions(t *testing.T) {
 	}
 }
 
+func TestBFDProfileEchoReceiveInterval(t *testing.T) {
+	testSetup(t)
+
+	pp := map[string]*config.BFDProfile{
+		"foo": {
+			Name:                "foo",
+			ReceiveInterval:     pointer.Uint32Ptr(60),
+			TransmitInterval:    pointer.Uint32Ptr(70),
+			EchoInterval:        pointer.Uint32Ptr(90),
+			EchoReceiveInterval: pointer.Uint32Ptr(120),
+			EchoMode:            true,
+		},
+	}
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+
+	err := sessionManager.SyncBFDProfiles(pp)
+	if err != nil {
+		t.Fatalf("Failed to sync bfd profiles: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
 func TestBFDProfileCornerCases(t *testing.T) {
 	testSetup(t)
 
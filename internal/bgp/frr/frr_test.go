@@ -1065,6 +1065,153 @@ func TestLoggingConfigurationDebug(t *testing.T) {
 	testCheckConfigFile(t)
 }
 
+func TestEBGPMultiHopTTL(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:     "10.2.2.254:179",
+			SourceAddress:   net.ParseIP("10.1.1.254"),
+			MyASN:           100,
+			RouterID:        net.ParseIP("10.1.1.254"),
+			PeerASN:         200,
+			HoldTime:        time.Second,
+			KeepAliveTime:   time.Second,
+			EBGPMultiHop:    true,
+			EBGPMultiHopTTL: 5,
+			CurrentNode:     "hostname",
+			SessionName:     "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	testCheckConfigFile(t)
+}
+
+func TestTTLSecurity(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			TTLSecurity:   true,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	testCheckConfigFile(t)
+}
+
+func TestConnectTime(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			ConnectTime:   10 * time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	testCheckConfigFile(t)
+}
+
+func TestGracefulRestart(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	for i, restartTime := range []time.Duration{time.Minute, 2 * time.Minute} {
+		session, err := sessionManager.NewSession(l,
+			bgp.SessionParameters{
+				PeerAddress:         fmt.Sprintf("10.2.2.%d:179", 253+i),
+				SourceAddress:       net.ParseIP("10.1.1.254"),
+				MyASN:               100,
+				RouterID:            net.ParseIP("10.1.1.254"),
+				PeerASN:             200,
+				HoldTime:            time.Second,
+				KeepAliveTime:       time.Second,
+				CurrentNode:         "hostname",
+				SessionName:         fmt.Sprintf("test-peer%d", i),
+				GracefulRestart:     true,
+				GracefulRestartTime: restartTime})
+		if err != nil {
+			t.Fatalf("Could not create session: %s", err)
+		}
+		defer session.Close()
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestNextHop(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	adv1 := &bgp.Advertisement{
+		Prefix:  &net.IPNet{IP: net.ParseIP("172.16.1.10"), Mask: classCMask},
+		NextHop: net.ParseIP("10.1.1.1"),
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix:  &net.IPNet{IP: net.ParseIP("2001:db8::10"), Mask: net.CIDRMask(128, 128)},
+		NextHop: net.ParseIP("2001:db8::1"),
+	}
+
+	err = session.Set(adv1, adv2)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
 func TestLoggingConfigurationOverrideByEnvironmentVar(t *testing.T) {
 	testSetup(t)
 
@@ -307,6 +307,9 @@ func sendUpdate(w io.Writer, asn uint32, ibgp, fbasn bool, nextHop net.IP, adv *
 		return err
 	}
 	l := b.Len()
+	if adv.NextHop != nil {
+		nextHop = adv.NextHop.To4()
+	}
 	if err := encodePathAttrs(&b, asn, ibgp, fbasn, nextHop, adv); err != nil {
 		return err
 	}
@@ -127,7 +127,7 @@ func (s *session) sendUpdates() bool {
 		return true
 	}
 
-	ibgp := s.MyASN == s.PeerASN
+	ibgp := s.MyASN == s.PeerASN || s.DynamicASN == config.DynamicASNInternal
 	fbasn := s.peerFBASNSupport
 
 	if s.new != nil {
@@ -245,9 +245,9 @@ func (s *session) connect() error {
 		conn.Close()
 		return fmt.Errorf("read OPEN from %q: %s", s.PeerAddress, err)
 	}
-	if op.asn != s.PeerASN {
+	if err := s.checkPeerASN(op.asn); err != nil {
 		conn.Close()
-		return fmt.Errorf("unexpected peer ASN %d, want %d", op.asn, s.PeerASN)
+		return err
 	}
 	s.peerFBASNSupport = op.fbasn
 	if s.MyASN > 65536 && !s.peerFBASNSupport {
@@ -692,3 +692,23 @@ func localAddressExists(ifs []net.Interface, addr net.IP) bool {
 
 	return false
 }
+
+// checkPeerASN checks the ASN received in the OPEN message of the peer,
+// which must be the configured one or match the dynamic ASN kind.
+func (s *session) checkPeerASN(asn uint32) error {
+	switch s.DynamicASN {
+	case config.DynamicASNInternal:
+		if asn != s.MyASN {
+			return fmt.Errorf("unexpected peer ASN %d, want internal ASN %d", asn, s.MyASN)
+		}
+	case config.DynamicASNExternal:
+		if asn == s.MyASN {
+			return fmt.Errorf("unexpected peer ASN %d, want an external ASN", asn)
+		}
+	default:
+		if asn != s.PeerASN {
+			return fmt.Errorf("unexpected peer ASN %d, want %d", asn, s.PeerASN)
+		}
+	}
+	return nil
+}
@@ -94,8 +94,14 @@ type Peer struct {
 	MyASN uint32
 	// AS number to expect from the remote end of the session.
 	ASN uint32
+	// Accept any internal or external AS number from the remote end of
+	// the session, set in place of ASN.
+	DynamicASN string
 	// Address to dial when establishing the session.
 	Addr net.IP
+	// Interface to establish an unnumbered session on, set in
+	// place of Addr.
+	Interface string
 	// Source address to use when establishing the session.
 	SrcAddr net.IP
 	// Port to dial when establishing the session.
@@ -104,6 +110,9 @@ type Peer struct {
 	HoldTime time.Duration
 	// Requested BGP keepalive time, per RFC4271.
 	KeepaliveTime time.Duration
+	// Time to wait between the attempts to connect to the peer, zero
+	// means the default of the BGP implementation.
+	ConnectTime time.Duration
 	// BGP router ID to advertise to the peer
 	RouterID net.IP
 	// Only connect to this peer on nodes that match one of these
@@ -111,12 +120,30 @@ type Peer struct {
 	NodeSelectors []labels.Selector
 	// Authentication password for routers enforcing TCP MD5 authenticated sessions
 	Password string
+	// The mechanism used to authenticate the session with the password, empty means TCP-MD5
+	AuthAlgorithm string
+	// The id of the TCP-AO key, valid only with a TCP-AO AuthAlgorithm
+	AuthKeyID uint32
 	// The optional BFD profile to be used for this BGP session
 	BFDProfile string
 	// Optional ebgp peer is multi-hops away.
 	EBGPMultiHop bool
+	// The TTL of the packets sent to a multi-hops away peer, zero
+	// means the default of the BGP implementation.
+	EBGPMultiHopTTL uint32
+	// If set, only the packets with TTL 255 are accepted from the peer,
+	// per RFC5082.
+	TTLSecurity bool
 	// Optional name of the vrf to establish the session from
 	VRF string
+	// If set, the peer is administratively down and the session
+	// must not be established.
+	Disabled bool
+	// If set, the graceful restart capability is advertised to the peer.
+	GracefulRestart bool
+	// The time the peer should wait for the session to be re-established
+	// after a restart. Zero means the default of the BGP implementation.
+	GracefulRestartTime time.Duration
 	// TODO: more BGP session settings
 }
 
@@ -147,8 +174,21 @@ type Pool struct {
 	cidrsPerAddresses map[string][]*net.IPNet
 
 	ServiceAllocations *ServiceAllocation
+
+	// The priority of the pool when assigning IPs to services not
+	// pinned to any pool. Lower is preferred, zero means no priority.
+	AllocationPriority int
+
+	// How the IPs of the pool are picked, empty means lowest free.
+	AllocationAlgorithm string
 }
 
+// Algorithms used to pick the IPs of the pools.
+const (
+	AllocationLowestFree = "LowestFree"
+	AllocationRoundRobin = "RoundRobin"
+)
+
 // ServiceAllocation makes ip pool allocation to specific namespace and/or service.
 type ServiceAllocation struct {
 	// The priority of ip pool for a given service allocation.
@@ -181,6 +221,9 @@ type BGPAdvertisement struct {
 	// Used to declare the intent of announcing IPs
 	// only to the BGPPeers in this list.
 	Peers []string
+	// The address to advertise as next hop in place of the address
+	// of the speaker. Optional, nil means the speaker's address.
+	NextHop net.IP
 }
 
 type L2Advertisement struct {
@@ -190,18 +233,40 @@ type L2Advertisement struct {
 	Interfaces []string
 	// AllInterfaces tells if all the interfaces are allowed for this advertisement
 	AllInterfaces bool
+	// NodeSelectionPolicy tells how the node announcing an IP is chosen, empty means hash
+	NodeSelectionPolicy string
 }
 
+// Dynamic AS numbers accepted from the remote end of the BGP sessions.
+const (
+	DynamicASNInternal = "internal"
+	DynamicASNExternal = "external"
+)
+
+// Mechanisms to authenticate the BGP sessions.
+const (
+	AuthTCPMD5            = "TCP-MD5"
+	AuthTCPAOHMACSHA196   = "TCP-AO-HMAC-SHA-1-96"
+	AuthTCPAOAES128CMAC96 = "TCP-AO-AES-128-CMAC-96"
+)
+
+// Policies to choose the node announcing an IP via Layer2.
+const (
+	NodeSelectionHash   = "Hash"
+	NodeSelectionLeader = "Leader"
+)
+
 // BFDProfile describes a BFD profile to be applied to a set of peers.
 type BFDProfile struct {
-	Name             string
-	ReceiveInterval  *uint32
-	TransmitInterval *uint32
-	DetectMultiplier *uint32
-	EchoInterval     *uint32
-	EchoMode         bool
-	PassiveMode      bool
-	MinimumTTL       *uint32
+	Name                string
+	ReceiveInterval     *uint32
+	TransmitInterval    *uint32
+	DetectMultiplier    *uint32
+	EchoInterval        *uint32
+	EchoReceiveInterval *uint32
+	EchoMode            bool
+	PassiveMode         bool
+	MinimumTTL          *uint32
 }
 
 func (p *Pools) IsEmpty(pool string) bool {
@@ -272,11 +337,8 @@ func peersFor(resources ClusterResources, BFDProfiles map[string]*BFDProfile) (m
 			}
 		}
 		for _, ep := range res {
-			// TODO: Be smarter regarding conflicting peers. For example, two
-			// peers could have a different hold time but they'd still result
-			// in two BGP sessions between the speaker and the remote host.
-			if reflect.DeepEqual(peer, ep) {
-				return nil, fmt.Errorf("peer %s already exists", p.Name)
+			if sameSession(peer, ep) {
+				return nil, fmt.Errorf("peer %s conflicts with existing peer %s: same myASN, ASN, address, port and VRF selected by the same nodes", p.Name, ep.Name)
 			}
 		}
 		res[peer.Name] = peer
@@ -284,6 +346,32 @@ func peersFor(resources ClusterResources, BFDProfiles map[string]*BFDProfile) (m
 	return res, nil
 }
 
+// sameSession tells if the two peers would result in two BGP sessions
+// between the same speakers and the same remote host, even if with
+// different parameters such as the hold time.
+func sameSession(a, b *Peer) bool {
+	if a.MyASN != b.MyASN || a.ASN != b.ASN || a.DynamicASN != b.DynamicASN || !a.Addr.Equal(b.Addr) ||
+		a.Interface != b.Interface || a.Port != b.Port || a.VRF != b.VRF {
+		return false
+	}
+	if len(a.NodeSelectors) != len(b.NodeSelectors) {
+		return false
+	}
+	for i := range a.NodeSelectors {
+		if a.NodeSelectors[i].String() != b.NodeSelectors[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// poolCIDR is a CIDR of an address pool, used to report which
+// pools are overlapping.
+type poolCIDR struct {
+	cidr *net.IPNet
+	pool string
+}
+
 func poolsFor(resources ClusterResources) (*Pools, error) {
 	pools := make(map[string]*Pool)
 	communities, err := communitiesFromCrs(resources.Communities)
@@ -291,7 +379,7 @@ func poolsFor(resources ClusterResources) (*Pools, error) {
 		return nil, err
 	}
 
-	var allCIDRs []*net.IPNet
+	var allCIDRs []poolCIDR
 	for _, p := range resources.Pools {
 		pool, err := addressPoolFromCR(p, resources.Namespaces)
 		if err != nil {
@@ -306,11 +394,11 @@ func poolsFor(resources ClusterResources) (*Pools, error) {
 		// Check that all specified CIDR ranges are non-overlapping.
 		for _, cidr := range pool.CIDR {
 			for _, m := range allCIDRs {
-				if cidrsOverlap(cidr, m) {
-					return nil, fmt.Errorf("CIDR %q in pool %q overlaps with already defined CIDR %q", cidr, p.Name, m)
+				if cidrsOverlap(cidr, m.cidr) {
+					return nil, fmt.Errorf("CIDR %q in pool %q overlaps with already defined CIDR %q of pool %q", cidr, p.Name, m.cidr, m.pool)
 				}
 			}
-			allCIDRs = append(allCIDRs, cidr)
+			allCIDRs = append(allCIDRs, poolCIDR{cidr: cidr, pool: p.Name})
 		}
 
 		pools[p.Name] = pool
@@ -349,11 +437,11 @@ func poolsFor(resources ClusterResources) (*Pools, error) {
 		// Check that all specified CIDR ranges are non-overlapping.
 		for _, cidr := range pool.CIDR {
 			for _, m := range allCIDRs {
-				if cidrsOverlap(cidr, m) {
-					return nil, fmt.Errorf("CIDR %q in pool %q overlaps with already defined CIDR %q", cidr, p.Name, m)
+				if cidrsOverlap(cidr, m.cidr) {
+					return nil, fmt.Errorf("CIDR %q in pool %q overlaps with already defined CIDR %q of pool %q", cidr, p.Name, m.cidr, m.pool)
 				}
 			}
-			allCIDRs = append(allCIDRs, cidr)
+			allCIDRs = append(allCIDRs, poolCIDR{cidr: cidr, pool: p.Name})
 		}
 
 		pools[p.Name] = pool
@@ -386,19 +474,73 @@ func communitiesFromCrs(cs []metallbv1beta1.Community) (map[string]community.BGP
 	return communities, nil
 }
 
+// sourceAddressFor returns the source address to use for the session with
+// the peer having the given address: either the single source address or the
+// one of the same family among the source addresses, if any.
+func sourceAddressFor(spec metallbv1beta2.BGPPeerSpec, addr net.IP) (net.IP, error) {
+	if len(spec.SrcAddresses) == 0 {
+		src := net.ParseIP(spec.SrcAddress)
+		if spec.SrcAddress != "" && src == nil {
+			return nil, fmt.Errorf("invalid source IP %q", spec.SrcAddress)
+		}
+		return src, nil
+	}
+	if spec.SrcAddress != "" {
+		return nil, errors.New("sourceAddress and sourceAddresses are mutually exclusive")
+	}
+	if addr == nil {
+		return nil, errors.New("sourceAddresses can't be set for a peer without an address")
+	}
+	var v4, v6 net.IP
+	for _, a := range spec.SrcAddresses {
+		src := net.ParseIP(a)
+		if src == nil {
+			return nil, fmt.Errorf("invalid source IP %q", a)
+		}
+		family := &v6
+		if src.To4() != nil {
+			family = &v4
+		}
+		if *family != nil {
+			return nil, fmt.Errorf("source addresses %s and %s are of the same family", *family, src)
+		}
+		*family = src
+	}
+	if addr.To4() != nil {
+		return v4, nil
+	}
+	return v6, nil
+}
+
 func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secret) (*Peer, error) {
 	if p.Spec.MyASN == 0 {
 		return nil, errors.New("missing local ASN")
 	}
-	if p.Spec.ASN == 0 {
-		return nil, errors.New("missing peer ASN")
+	if err := validateDynamicASN(p.Spec.ASN, p.Spec.DynamicASN); err != nil {
+		return nil, err
 	}
-	if p.Spec.ASN == p.Spec.MyASN && p.Spec.EBGPMultiHop {
+	ibgp := p.Spec.ASN == p.Spec.MyASN || p.Spec.DynamicASN == DynamicASNInternal
+	if ibgp && p.Spec.EBGPMultiHop {
 		return nil, errors.New("invalid ebgp-multihop parameter set for an ibgp peer")
 	}
-	ip := net.ParseIP(p.Spec.Address)
-	if ip == nil {
-		return nil, fmt.Errorf("invalid BGPPeer address %q", p.Spec.Address)
+	if p.Spec.TTLSecurity && p.Spec.EBGPMultiHop {
+		return nil, errors.New("ttl-security can't be set for an ebgp-multihop peer")
+	}
+	if p.Spec.EBGPMultiHopTTL != 0 && !p.Spec.EBGPMultiHop {
+		return nil, errors.New("ebgp-multihop-ttl can be set only for an ebgp-multihop peer")
+	}
+	if p.Spec.EBGPMultiHopTTL != 0 && (p.Spec.EBGPMultiHopTTL < 2 || p.Spec.EBGPMultiHopTTL > 255) {
+		return nil, fmt.Errorf("invalid ebgp-multihop-ttl %d: must be in 2-255 range", p.Spec.EBGPMultiHopTTL)
+	}
+	if p.Spec.Address != "" && p.Spec.Interface != "" {
+		return nil, fmt.Errorf("BGPPeer can't have both address %q and interface %q", p.Spec.Address, p.Spec.Interface)
+	}
+	var ip net.IP
+	if p.Spec.Interface == "" {
+		ip = net.ParseIP(p.Spec.Address)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid BGPPeer address %q", p.Spec.Address)
+		}
 	}
 	holdTime := p.Spec.HoldTime.Duration
 	if holdTime == 0 {
@@ -417,6 +559,13 @@ func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secr
 	if keepaliveTime > holdTime {
 		return nil, fmt.Errorf("invalid keepaliveTime %q", p.Spec.KeepaliveTime)
 	}
+	var connectTime time.Duration
+	if p.Spec.ConnectTime != nil {
+		connectTime = p.Spec.ConnectTime.Duration
+		if connectTime%time.Second != 0 || connectTime < time.Second || connectTime > 65535*time.Second {
+			return nil, fmt.Errorf("invalid connectTime %s: must be a whole number of seconds in 1s-65535s range", connectTime)
+		}
+	}
 
 	// Ideally we would set a default RouterID here, instead of having
 	// to do it elsewhere in the code. Unfortunately, we don't know
@@ -428,9 +577,9 @@ func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secr
 			return nil, fmt.Errorf("invalid router ID %q", p.Spec.RouterID)
 		}
 	}
-	src := net.ParseIP(p.Spec.SrcAddress)
-	if p.Spec.SrcAddress != "" && src == nil {
-		return nil, fmt.Errorf("invalid source IP %q", p.Spec.SrcAddress)
+	src, err := sourceAddressFor(p.Spec, ip)
+	if err != nil {
+		return nil, err
 	}
 
 	err = validateLabelSelectorDuplicate(p.Spec.NodeSelectors, "nodeSelectors")
@@ -455,25 +604,108 @@ func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secr
 	if err != nil {
 		return nil, err
 	}
+	if err := validatePeerAuth(p.Spec.AuthAlgorithm, p.Spec.AuthKeyID, password); err != nil {
+		return nil, err
+	}
+	if err := validateGracefulRestart(p.Spec.GracefulRestart); err != nil {
+		return nil, err
+	}
+	var gracefulRestart bool
+	var gracefulRestartTime time.Duration
+	if p.Spec.GracefulRestart != nil {
+		gracefulRestart = p.Spec.GracefulRestart.Enabled
+		gracefulRestartTime = p.Spec.GracefulRestart.RestartTime.Duration
+	}
 
 	return &Peer{
 		Name:          p.Name,
 		MyASN:         p.Spec.MyASN,
 		ASN:           p.Spec.ASN,
+		DynamicASN:    p.Spec.DynamicASN,
 		Addr:          ip,
+		Interface:     p.Spec.Interface,
 		SrcAddr:       src,
 		Port:          p.Spec.Port,
 		HoldTime:      holdTime,
 		KeepaliveTime: keepaliveTime,
+		ConnectTime:   connectTime,
 		RouterID:      routerID,
 		NodeSelectors: nodeSels,
 		Password:      password,
+		AuthAlgorithm: p.Spec.AuthAlgorithm,
+		AuthKeyID:     p.Spec.AuthKeyID,
 		BFDProfile:    p.Spec.BFDProfile,
 		EBGPMultiHop:  p.Spec.EBGPMultiHop,
+		TTLSecurity:   p.Spec.TTLSecurity,
 		VRF:           p.Spec.VRFName,
+		Disabled:      p.Spec.Disabled,
+
+		EBGPMultiHopTTL:     p.Spec.EBGPMultiHopTTL,
+		GracefulRestart:     gracefulRestart,
+		GracefulRestartTime: gracefulRestartTime,
 	}, nil
 }
 
+// validateGracefulRestart checks that the restart time is set only when the
+// graceful restart is enabled, and that it fits the 12 bits field in seconds
+// it is carried in, per RFC4724.
+func validateGracefulRestart(gr *metallbv1beta2.GracefulRestart) error {
+	if gr == nil || gr.RestartTime.Duration == 0 {
+		return nil
+	}
+	if !gr.Enabled {
+		return errors.New("graceful restart time can be set only when the graceful restart is enabled")
+	}
+	restartTime := gr.RestartTime.Duration
+	if restartTime%time.Second != 0 || restartTime < time.Second || restartTime > 4095*time.Second {
+		return fmt.Errorf("invalid graceful restart time %s: must be a whole number of seconds in 1s-4095s range", restartTime)
+	}
+	return nil
+}
+
+// validateDynamicASN checks that the remote end of the session has either
+// a fixed AS number or a known dynamic one.
+func validateDynamicASN(asn uint32, dynamicASN string) error {
+	switch dynamicASN {
+	case "":
+		if asn == 0 {
+			return errors.New("missing peer ASN")
+		}
+	case DynamicASNInternal, DynamicASNExternal:
+		if asn != 0 {
+			return fmt.Errorf("peer ASN %d and dynamic ASN %s are mutually exclusive", asn, dynamicASN)
+		}
+	default:
+		return fmt.Errorf("unknown dynamic ASN %q, must be one of %s, %s", dynamicASN, DynamicASNInternal, DynamicASNExternal)
+	}
+	return nil
+}
+
+// validatePeerAuth checks that the authentication algorithm is a known one,
+// that it comes with a password and that the key id is set only for TCP-AO.
+func validatePeerAuth(algorithm string, keyID uint32, password string) error {
+	switch algorithm {
+	case "":
+	case AuthTCPMD5, AuthTCPAOHMACSHA196, AuthTCPAOAES128CMAC96:
+		if password == "" {
+			return fmt.Errorf("auth algorithm %s requires a password", algorithm)
+		}
+	default:
+		return fmt.Errorf("unknown auth algorithm %q, must be one of %s, %s, %s",
+			algorithm, AuthTCPMD5, AuthTCPAOHMACSHA196, AuthTCPAOAES128CMAC96)
+	}
+	if keyID == 0 {
+		return nil
+	}
+	if algorithm != AuthTCPAOHMACSHA196 && algorithm != AuthTCPAOAES128CMAC96 {
+		return errors.New("auth key id can be set only with a TCP-AO auth algorithm")
+	}
+	if keyID > 255 {
+		return fmt.Errorf("invalid auth key id %d: must be in 0-255 range", keyID)
+	}
+	return nil
+}
+
 func passwordForPeer(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secret) (string, error) {
 	if p.Spec.Password != "" && p.Spec.PasswordSecret.Name != "" {
 		return "", fmt.Errorf("can not have both password and secret ref set in peer config %q/%q", p.Namespace,
@@ -506,9 +738,21 @@ func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Names
 	}
 
 	ret := &Pool{
-		Name:          p.Name,
-		AvoidBuggyIPs: p.Spec.AvoidBuggyIPs,
-		AutoAssign:    true,
+		Name:                p.Name,
+		AvoidBuggyIPs:       p.Spec.AvoidBuggyIPs,
+		AutoAssign:          true,
+		AllocationPriority:  p.Spec.AllocationPriority,
+		AllocationAlgorithm: p.Spec.AllocationAlgorithm,
+	}
+
+	if p.Spec.AllocationPriority < 0 {
+		return nil, fmt.Errorf("invalid allocation priority %d: must be >= 0", p.Spec.AllocationPriority)
+	}
+
+	switch p.Spec.AllocationAlgorithm {
+	case "", AllocationLowestFree, AllocationRoundRobin:
+	default:
+		return nil, fmt.Errorf("invalid allocation algorithm %q: must be %s or %s", p.Spec.AllocationAlgorithm, AllocationLowestFree, AllocationRoundRobin)
 	}
 
 	if p.Spec.AutoAssign != nil {
@@ -686,6 +930,10 @@ func bfdProfileFromCR(p metallbv1beta1.BFDProfile) (*BFDProfile, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid echo interval value")
 	}
+	res.EchoReceiveInterval, err = bfdIntFromConfig(p.Spec.EchoReceiveInterval, 10, 60000)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid echo receive interval value")
+	}
 	if p.Spec.EchoMode != nil {
 		res.EchoMode = *p.Spec.EchoMode
 	}
@@ -783,6 +1031,13 @@ func l2AdvertisementFromCR(crdAd metallbv1beta1.L2Advertisement, nodes []corev1.
 		Nodes:      selected,
 		Interfaces: crdAd.Spec.Interfaces,
 	}
+	switch crdAd.Spec.NodeSelectionPolicy {
+	case "", NodeSelectionHash, NodeSelectionLeader:
+		l2.NodeSelectionPolicy = crdAd.Spec.NodeSelectionPolicy
+	default:
+		return nil, fmt.Errorf("invalid nodeSelectionPolicy %q for %s, must be %s or %s",
+			crdAd.Spec.NodeSelectionPolicy, crdAd.Name, NodeSelectionHash, NodeSelectionLeader)
+	}
 	if len(crdAd.Spec.Interfaces) == 0 {
 		l2.AllInterfaces = true
 	}
@@ -834,6 +1089,13 @@ func bgpAdvertisementFromCR(crdAd metallbv1beta1.BGPAdvertisement, communities m
 
 	ad.LocalPref = crdAd.Spec.LocalPref
 
+	if crdAd.Spec.NextHop != "" {
+		ad.NextHop = net.ParseIP(crdAd.Spec.NextHop)
+		if ad.NextHop == nil {
+			return nil, fmt.Errorf("invalid next hop %q in BGP advertisement %s", crdAd.Spec.NextHop, crdAd.Name)
+		}
+	}
+
 	if len(crdAd.Spec.Peers) > 0 {
 		ad.Peers = make([]string, 0, len(crdAd.Spec.Peers))
 		ad.Peers = append(ad.Peers, crdAd.Spec.Peers...)
@@ -962,6 +1224,9 @@ func validateBGPAdvPerPool(adv *BGPAdvertisement, pool *Pool) error {
 		if len(cidrs) == 0 {
 			continue
 		}
+		if adv.NextHop != nil && (adv.NextHop.To4() == nil) != (cidrs[0].IP.To4() == nil) {
+			return fmt.Errorf("invalid next hop %s: not of the same family as the addresses %s of pool %s", adv.NextHop, addr, pool.Name)
+		}
 		maxLength := adv.AggregationLength
 		if cidrs[0].IP.To4() == nil {
 			maxLength = adv.AggregationLengthV6
@@ -1019,7 +1284,12 @@ func advertisementsAreCompatible(newAdv, adv *BGPAdvertisement) bool {
 	return true
 }
 
+// ParseCIDR parses the given pool address, either a CIDR, a start-end
+// range or a single IP, which is handled as a /32 or /128 CIDR.
 func ParseCIDR(cidr string) ([]*net.IPNet, error) {
+	if ip := net.ParseIP(cidr); ip != nil {
+		return []*net.IPNet{singleIPNet(ip)}, nil
+	}
 	if !strings.Contains(cidr, "-") {
 		_, n, err := net.ParseCIDR(cidr)
 		if err != nil {
@@ -1056,6 +1326,62 @@ func ParseCIDR(cidr string) ([]*net.IPNet, error) {
 	return ret, nil
 }
 
+// singleIPNet returns the network containing only the given IP.
+func singleIPNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// PoolForAddress returns the name of the pool, either an IPAddressPool or a
+// legacy AddressPool, the given address belongs to. It fails if the address
+// doesn't belong to any pool or, because of overlapping pools, to more than one.
+func PoolForAddress(resources ClusterResources, ip net.IP) (string, error) {
+	ranges := map[string][]string{}
+	for _, p := range resources.Pools {
+		ranges[p.Name] = append(ranges[p.Name], p.Spec.Addresses...)
+	}
+	for _, p := range resources.LegacyAddressPools {
+		ranges[p.Name] = append(ranges[p.Name], p.Spec.Addresses...)
+	}
+
+	found := []string{}
+	for name, addresses := range ranges {
+		contains, err := cidrsContain(addresses, ip)
+		if err != nil {
+			return "", fmt.Errorf("pool %s: %w", name, err)
+		}
+		if contains {
+			found = append(found, name)
+		}
+	}
+	sort.Strings(found)
+
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("address %s doesn't belong to any pool", ip)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("address %s belongs to more than one pool: %s", ip, strings.Join(found, ", "))
+}
+
+func cidrsContain(addresses []string, ip net.IP) (bool, error) {
+	for _, addr := range addresses {
+		cidrs, err := ParseCIDR(addr)
+		if err != nil {
+			return false, err
+		}
+		for _, cidr := range cidrs {
+			if cidr.Contains(ip) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 func cidrsOverlap(a, b *net.IPNet) bool {
 	return cidrContainsCIDR(a, b) || cidrContainsCIDR(b, a)
 }
@@ -664,6 +664,123 @@ func TestParse(t *testing.T) {
 			},
 		},
 
+		{
+			desc: "unnumbered peer",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:     42,
+							ASN:       142,
+							Interface: "eth0",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           142,
+						Interface:     "eth0",
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+
+		{
+			desc: "peer with both address and interface",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:     42,
+							ASN:       142,
+							Address:   "1.2.3.4",
+							Interface: "eth0",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "ttl security with ebgp multihop",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          142,
+							Address:      "1.2.3.4",
+							EBGPMultiHop: true,
+							TTLSecurity:  true,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "ebgp multihop ttl without ebgp multihop",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:           42,
+							ASN:             142,
+							Address:         "1.2.3.4",
+							EBGPMultiHopTTL: 5,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "unknown auth algorithm",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:         42,
+							ASN:           142,
+							Address:       "1.2.3.4",
+							Password:      "secret",
+							AuthAlgorithm: "TCP-AO-HMAC-SHA-256",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "auth key id with tcp-md5",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:         42,
+							ASN:           142,
+							Address:       "1.2.3.4",
+							Password:      "secret",
+							AuthAlgorithm: "TCP-MD5",
+							AuthKeyID:     3,
+						},
+					},
+				},
+			},
+		},
+
 		{
 			desc: "invalid peer-address",
 			crs: ClusterResources{
@@ -917,6 +1034,29 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "conflicting peers with different hold time",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							HoldTime: metav1.Duration{Duration: 90 * time.Second},
+						},
+					},
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							HoldTime: metav1.Duration{Duration: 30 * time.Second},
+						},
+					},
+				},
+			},
+		},
 		{
 			desc: "no pool name",
 			crs: ClusterResources{
@@ -2953,6 +3093,72 @@ func TestParse(t *testing.T) {
 				Peers:       map[string]*Peer{},
 			},
 		},
+		{
+			desc: "leader node selection policy",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.0.0/16",
+							},
+						},
+					},
+				},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "l2adv1",
+						},
+						Spec: v1beta1.L2AdvertisementSpec{
+							NodeSelectionPolicy: "Leader",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						CIDR:       []*net.IPNet{ipnet("10.20.0.0/16")},
+						AutoAssign: true,
+						L2Advertisements: []*L2Advertisement{{
+							Nodes:               map[string]bool{},
+							AllInterfaces:       true,
+							NodeSelectionPolicy: NodeSelectionLeader,
+						}},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "invalid node selection policy",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.0.0/16",
+							},
+						},
+					},
+				},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "l2adv1",
+						},
+						Spec: v1beta1.L2AdvertisementSpec{
+							NodeSelectionPolicy: "Random",
+						},
+					},
+				},
+			},
+		},
 		{
 			desc: "use duplicate match labels in ip pool selectors - in BGP adv",
 			crs: ClusterResources{
@@ -3706,6 +3912,9 @@ func TestParse(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
 			got, err := For(test.crs, DontValidate)
+			if validateErr := ValidateResources(test.crs, DontValidate); (validateErr != nil) != (err != nil) {
+				t.Errorf("%q: validation error %v doesn't match the parse error %v", test.desc, validateErr, err)
+			}
 			if err != nil && test.want != nil {
 				t.Errorf("%q: parse failed: %s", test.desc, err)
 				return
@@ -3860,3 +4069,528 @@ func FuzzParseCIDR(f *testing.F) {
 		_, _ = ParseCIDR(input)
 	})
 }
+
+func TestPoolForAddress(t *testing.T) {
+	resources := ClusterResources{
+		Pools: []v1beta1.IPAddressPool{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+				Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"192.0.2.0/28", "2001:db8::/64"}},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "pool2"},
+				Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"198.51.100.10-198.51.100.20"}},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "pool3"},
+				Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"198.51.100.15/32"}},
+			},
+		},
+		LegacyAddressPools: []v1beta1.AddressPool{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "legacy"},
+				Spec:       v1beta1.AddressPoolSpec{Addresses: []string{"203.0.113.0/24"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		desc        string
+		ip          string
+		expected    string
+		expectedErr bool
+	}{
+		{desc: "inside a pool", ip: "192.0.2.10", expected: "pool1"},
+		{desc: "inside the v6 range of a pool", ip: "2001:db8::10", expected: "pool1"},
+		{desc: "inside a range", ip: "198.51.100.12", expected: "pool2"},
+		{desc: "inside a legacy pool", ip: "203.0.113.5", expected: "legacy"},
+		{desc: "outside any pool", ip: "192.0.2.20", expectedErr: true},
+		{desc: "inside overlapping pools", ip: "198.51.100.15", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pool, err := PoolForAddress(resources, net.ParseIP(test.ip))
+			if test.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got pool %s", pool)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if pool != test.expected {
+				t.Fatalf("expected pool %s, got %s", test.expected, pool)
+			}
+		})
+	}
+
+	resources.Pools[0].Spec.Addresses = []string{"foo"}
+	if _, err := PoolForAddress(resources, net.ParseIP("192.0.2.10")); err == nil {
+		t.Fatalf("expected error for an invalid pool range")
+	}
+}
+
+func TestParseCIDRSingleIP(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{addr: "192.0.2.7", expected: "192.0.2.7/32"},
+		{addr: "2001:db8::7", expected: "2001:db8::7/128"},
+		{addr: "192.0.2.7-192.0.2.7", expected: "192.0.2.7/32"},
+	}
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			cidrs, err := ParseCIDR(test.addr)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(cidrs) != 1 || cidrs[0].String() != test.expected {
+				t.Fatalf("expected %s, got %v", test.expected, cidrs)
+			}
+		})
+	}
+}
+
+func TestPeerAuth(t *testing.T) {
+	tests := []struct {
+		desc          string
+		password      string
+		algorithm     string
+		keyID         uint32
+		expectedError bool
+	}{
+		{desc: "default", password: "secret"},
+		{desc: "tcp-ao with key id", password: "secret", algorithm: AuthTCPAOHMACSHA196, keyID: 7},
+		{desc: "algorithm without password", algorithm: AuthTCPMD5, expectedError: true},
+		{desc: "unknown algorithm", password: "secret", algorithm: "MD5", expectedError: true},
+		{desc: "key id out of range", password: "secret", algorithm: AuthTCPAOAES128CMAC96, keyID: 256, expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta2.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+				Spec: v1beta2.BGPPeerSpec{
+					MyASN:         42,
+					ASN:           142,
+					Address:       "1.2.3.4",
+					Password:      test.password,
+					AuthAlgorithm: test.algorithm,
+					AuthKeyID:     test.keyID,
+				},
+			}
+			peer, err := peerFromCR(p, nil)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if peer.AuthAlgorithm != test.algorithm || peer.AuthKeyID != test.keyID {
+				t.Fatalf("expected auth algorithm %q with key id %d, got %q with %d", test.algorithm, test.keyID, peer.AuthAlgorithm, peer.AuthKeyID)
+			}
+		})
+	}
+}
+
+func TestPeerDynamicASN(t *testing.T) {
+	tests := []struct {
+		desc          string
+		asn           uint32
+		dynamicASN    string
+		ebgpMultiHop  bool
+		expectedError bool
+	}{
+		{desc: "fixed asn", asn: 142},
+		{desc: "dynamic external", dynamicASN: DynamicASNExternal},
+		{desc: "dynamic internal", dynamicASN: DynamicASNInternal},
+		{desc: "dynamic external multihop", dynamicASN: DynamicASNExternal, ebgpMultiHop: true},
+		{desc: "dynamic internal multihop", dynamicASN: DynamicASNInternal, ebgpMultiHop: true, expectedError: true},
+		{desc: "both asn and dynamic asn", asn: 142, dynamicASN: DynamicASNExternal, expectedError: true},
+		{desc: "unknown dynamic asn", dynamicASN: "any", expectedError: true},
+		{desc: "neither asn nor dynamic asn", expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta2.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+				Spec: v1beta2.BGPPeerSpec{
+					MyASN:        42,
+					ASN:          test.asn,
+					DynamicASN:   test.dynamicASN,
+					Address:      "1.2.3.4",
+					EBGPMultiHop: test.ebgpMultiHop,
+				},
+			}
+			peer, err := peerFromCR(p, nil)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if peer.ASN != test.asn || peer.DynamicASN != test.dynamicASN {
+				t.Fatalf("expected asn %d with dynamic asn %q, got %d with %q", test.asn, test.dynamicASN, peer.ASN, peer.DynamicASN)
+			}
+		})
+	}
+}
+
+func TestPeerSourceAddresses(t *testing.T) {
+	tests := []struct {
+		desc          string
+		addr          string
+		srcAddr       string
+		srcAddrs      []string
+		expected      string
+		expectedError bool
+	}{
+		{desc: "single source", addr: "1.2.3.4", srcAddr: "10.0.0.1", expected: "10.0.0.1"},
+		{desc: "dual source ipv4 peer", addr: "1.2.3.4", srcAddrs: []string{"2001:db8::2", "10.0.0.1"}, expected: "10.0.0.1"},
+		{desc: "dual source ipv6 peer", addr: "2001:db8::1", srcAddrs: []string{"10.0.0.1", "2001:db8::2"}, expected: "2001:db8::2"},
+		{desc: "no source of the peer family", addr: "2001:db8::1", srcAddrs: []string{"10.0.0.1"}},
+		{desc: "two sources of the same family", addr: "1.2.3.4", srcAddrs: []string{"10.0.0.1", "10.0.0.2"}, expectedError: true},
+		{desc: "invalid source", addr: "1.2.3.4", srcAddrs: []string{"10.0.0"}, expectedError: true},
+		{desc: "both source and sources", addr: "1.2.3.4", srcAddr: "10.0.0.1", srcAddrs: []string{"10.0.0.1"}, expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta2.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+				Spec: v1beta2.BGPPeerSpec{
+					MyASN:        42,
+					ASN:          142,
+					Address:      test.addr,
+					SrcAddress:   test.srcAddr,
+					SrcAddresses: test.srcAddrs,
+				},
+			}
+			peer, err := peerFromCR(p, nil)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !peer.SrcAddr.Equal(net.ParseIP(test.expected)) {
+				t.Fatalf("expected source address %q, got %s", test.expected, peer.SrcAddr)
+			}
+		})
+	}
+}
+
+func TestBFDProfileEchoReceiveInterval(t *testing.T) {
+	tests := []struct {
+		desc          string
+		interval      *uint32
+		expectedError bool
+	}{
+		{desc: "not set"},
+		{desc: "valid", interval: pointer.Uint32Ptr(100)},
+		{desc: "lower bound", interval: pointer.Uint32Ptr(10)},
+		{desc: "upper bound", interval: pointer.Uint32Ptr(60000)},
+		{desc: "too low", interval: pointer.Uint32Ptr(9), expectedError: true},
+		{desc: "too high", interval: pointer.Uint32Ptr(60001), expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta1.BFDProfile{
+				ObjectMeta: metav1.ObjectMeta{Name: "bfd1"},
+				Spec: v1beta1.BFDProfileSpec{
+					EchoReceiveInterval: test.interval,
+				},
+			}
+			profile, err := bfdProfileFromCR(p)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(profile.EchoReceiveInterval, test.interval) {
+				t.Fatalf("expected echo receive interval %v, got %v", test.interval, profile.EchoReceiveInterval)
+			}
+		})
+	}
+}
+
+func TestPoolAllocationAlgorithm(t *testing.T) {
+	tests := []struct {
+		desc          string
+		algorithm     string
+		expectedError bool
+	}{
+		{desc: "not set"},
+		{desc: "lowest free", algorithm: AllocationLowestFree},
+		{desc: "round robin", algorithm: AllocationRoundRobin},
+		{desc: "unknown", algorithm: "Random", expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta1.IPAddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+				Spec: v1beta1.IPAddressPoolSpec{
+					Addresses:           []string{"10.20.0.0/16"},
+					AllocationAlgorithm: test.algorithm,
+				},
+			}
+			pool, err := addressPoolFromCR(p, nil)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if pool.AllocationAlgorithm != test.algorithm {
+				t.Fatalf("expected allocation algorithm %q, got %q", test.algorithm, pool.AllocationAlgorithm)
+			}
+		})
+	}
+}
+
+func TestBGPAdvertisementNextHop(t *testing.T) {
+	tests := []struct {
+		desc          string
+		addresses     []string
+		nextHop       string
+		expectedError bool
+	}{
+		{desc: "not set", addresses: []string{"10.20.0.0/16"}},
+		{desc: "ipv4", addresses: []string{"10.20.0.0/16"}, nextHop: "10.1.1.1"},
+		{desc: "ipv6", addresses: []string{"2001:db8::/64"}, nextHop: "2001:db8::1"},
+		{desc: "invalid", addresses: []string{"10.20.0.0/16"}, nextHop: "10.1.1", expectedError: true},
+		{desc: "family mismatch", addresses: []string{"10.20.0.0/16"}, nextHop: "2001:db8::1", expectedError: true},
+		{desc: "dual stack pool", addresses: []string{"10.20.0.0/16", "2001:db8::/64"}, nextHop: "10.1.1.1", expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			resources := ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{Addresses: test.addresses},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "adv1"},
+						Spec:       v1beta1.BGPAdvertisementSpec{NextHop: test.nextHop},
+					},
+				},
+			}
+			cfg, err := For(resources, DontValidate)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			adv := cfg.Pools.ByName["pool1"].BGPAdvertisements[0]
+			if !adv.NextHop.Equal(net.ParseIP(test.nextHop)) {
+				t.Fatalf("expected next hop %q, got %s", test.nextHop, adv.NextHop)
+			}
+		})
+	}
+}
+
+func TestPeerGracefulRestart(t *testing.T) {
+	tests := []struct {
+		desc            string
+		gracefulRestart *v1beta2.GracefulRestart
+		expectedEnabled bool
+		expectedTime    time.Duration
+		expectedError   bool
+	}{
+		{desc: "not set"},
+		{desc: "enabled", gracefulRestart: &v1beta2.GracefulRestart{Enabled: true}, expectedEnabled: true},
+		{
+			desc:            "enabled with restart time",
+			gracefulRestart: &v1beta2.GracefulRestart{Enabled: true, RestartTime: metav1.Duration{Duration: 2 * time.Minute}},
+			expectedEnabled: true,
+			expectedTime:    2 * time.Minute,
+		},
+		{
+			desc:            "restart time without graceful restart",
+			gracefulRestart: &v1beta2.GracefulRestart{RestartTime: metav1.Duration{Duration: 2 * time.Minute}},
+			expectedError:   true,
+		},
+		{
+			desc:            "restart time too long",
+			gracefulRestart: &v1beta2.GracefulRestart{Enabled: true, RestartTime: metav1.Duration{Duration: 4096 * time.Second}},
+			expectedError:   true,
+		},
+		{
+			desc:            "restart time not in seconds",
+			gracefulRestart: &v1beta2.GracefulRestart{Enabled: true, RestartTime: metav1.Duration{Duration: 1500 * time.Millisecond}},
+			expectedError:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta2.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+				Spec: v1beta2.BGPPeerSpec{
+					MyASN:           42,
+					ASN:             142,
+					Address:         "1.2.3.4",
+					GracefulRestart: test.gracefulRestart,
+				},
+			}
+			peer, err := peerFromCR(p, nil)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if peer.GracefulRestart != test.expectedEnabled || peer.GracefulRestartTime != test.expectedTime {
+				t.Fatalf("expected graceful restart %t with time %s, got %t with %s",
+					test.expectedEnabled, test.expectedTime, peer.GracefulRestart, peer.GracefulRestartTime)
+			}
+		})
+	}
+}
+
+func TestPeerConnectTime(t *testing.T) {
+	tests := []struct {
+		desc          string
+		connectTime   *metav1.Duration
+		expected      time.Duration
+		expectedError bool
+	}{
+		{desc: "not set"},
+		{desc: "set", connectTime: &metav1.Duration{Duration: 10 * time.Second}, expected: 10 * time.Second},
+		{desc: "zero", connectTime: &metav1.Duration{}, expectedError: true},
+		{desc: "not in seconds", connectTime: &metav1.Duration{Duration: 1500 * time.Millisecond}, expectedError: true},
+		{desc: "too long", connectTime: &metav1.Duration{Duration: 65536 * time.Second}, expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta2.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+				Spec: v1beta2.BGPPeerSpec{
+					MyASN:       42,
+					ASN:         142,
+					Address:     "1.2.3.4",
+					ConnectTime: test.connectTime,
+				},
+			}
+			peer, err := peerFromCR(p, nil)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if peer.ConnectTime != test.expected {
+				t.Fatalf("expected connect time %s, got %s", test.expected, peer.ConnectTime)
+			}
+		})
+	}
+}
+
+func TestPeerTTLSecurity(t *testing.T) {
+	tests := []struct {
+		desc          string
+		ttlSecurity   bool
+		ebgpMultiHop  bool
+		expectedError bool
+	}{
+		{desc: "not set"},
+		{desc: "set", ttlSecurity: true},
+		{desc: "multihop", ttlSecurity: true, ebgpMultiHop: true, expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta2.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+				Spec: v1beta2.BGPPeerSpec{
+					MyASN:        42,
+					ASN:          142,
+					Address:      "1.2.3.4",
+					TTLSecurity:  test.ttlSecurity,
+					EBGPMultiHop: test.ebgpMultiHop,
+				},
+			}
+			peer, err := peerFromCR(p, nil)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if peer.TTLSecurity != test.ttlSecurity {
+				t.Fatalf("expected ttl security %t, got %t", test.ttlSecurity, peer.TTLSecurity)
+			}
+		})
+	}
+}
+
+func TestPeerEBGPMultiHopTTL(t *testing.T) {
+	tests := []struct {
+		desc          string
+		ttl           uint32
+		ebgpMultiHop  bool
+		expectedError bool
+	}{
+		{desc: "not set"},
+		{desc: "set", ttl: 5, ebgpMultiHop: true},
+		{desc: "not multihop", ttl: 5, expectedError: true},
+		{desc: "too low", ttl: 1, ebgpMultiHop: true, expectedError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := v1beta2.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+				Spec: v1beta2.BGPPeerSpec{
+					MyASN:           42,
+					ASN:             142,
+					Address:         "1.2.3.4",
+					EBGPMultiHop:    test.ebgpMultiHop,
+					EBGPMultiHopTTL: test.ttl,
+				},
+			}
+			peer, err := peerFromCR(p, nil)
+			if test.expectedError {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if peer.EBGPMultiHopTTL != test.ttl {
+				t.Fatalf("expected ebgp multihop ttl %d, got %d", test.ttl, peer.EBGPMultiHopTTL)
+			}
+		})
+	}
+}
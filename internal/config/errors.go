@@ -0,0 +1,30 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package config
+
+// ConversionErrorKind describes why a configuration element could not be converted.
+type ConversionErrorKind string
+
+const (
+	// ParseError means the configuration element is malformed.
+	ParseError ConversionErrorKind = "parse"
+	// ValidationError means the configuration element is well formed but
+	// its value is not acceptable.
+	ValidationError ConversionErrorKind = "validation"
+)
+
+// ConversionError is an error that happens while converting a
+// configuration element. Retrying the conversion will not help, the
+// configuration must be fixed instead.
+type ConversionError struct {
+	Kind ConversionErrorKind
+	// Name is the name of the offending configuration element, e.g. the
+	// name of the pool, the address or the interface of the peer or the
+	// alias of the community. The elements without a name, such as the
+	// labels to set, are named by their value.
+	Name string
+	// Reason is the human readable description of the error.
+	Reason string
+}
+
+func (e *ConversionError) Error() string { return e.Reason }
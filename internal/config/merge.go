@@ -0,0 +1,114 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package config
+
+import (
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// MergeByName merges the incoming resources into the existing ones. For each
+// resource type, an incoming resource replaces the existing one with the same
+// name, while resources with a new name are appended. The order of the existing
+// resources is preserved, so merging the same set twice yields the same result.
+func MergeByName(existing, incoming ClusterResources) ClusterResources {
+	res := ClusterResources{
+		Pools:              mergeByName(existing.Pools, incoming.Pools),
+		Peers:              mergeByName(existing.Peers, incoming.Peers),
+		BFDProfiles:        mergeByName(existing.BFDProfiles, incoming.BFDProfiles),
+		BGPAdvs:            mergeByName(existing.BGPAdvs, incoming.BGPAdvs),
+		L2Advs:             mergeByName(existing.L2Advs, incoming.L2Advs),
+		LegacyAddressPools: mergeByName(existing.LegacyAddressPools, incoming.LegacyAddressPools),
+		Communities:        mergeByName(existing.Communities, incoming.Communities),
+		Nodes:              mergeByName(existing.Nodes, incoming.Nodes),
+		Namespaces:         mergeByName(existing.Namespaces, incoming.Namespaces),
+		BGPExtras:          existing.BGPExtras,
+	}
+
+	if existing.PasswordSecrets != nil || incoming.PasswordSecrets != nil {
+		res.PasswordSecrets = make(map[string]corev1.Secret, len(existing.PasswordSecrets))
+		for k, s := range existing.PasswordSecrets {
+			res.PasswordSecrets[k] = s
+		}
+		for k, s := range incoming.PasswordSecrets {
+			res.PasswordSecrets[k] = s
+		}
+	}
+
+	if incoming.BGPExtras.Name != "" || incoming.BGPExtras.Data != nil {
+		res.BGPExtras = incoming.BGPExtras
+	}
+
+	return res
+}
+
+// DropConflictingLegacyPools removes the pools defined with the same name both as
+// IPAddressPool and as legacy AddressPool, which would make the configuration invalid.
+// The legacy pools win when legacyPrecedence is true, the IPAddressPools otherwise.
+// It returns the names of the conflicting pools.
+func DropConflictingLegacyPools(resources *ClusterResources, legacyPrecedence bool) []string {
+	legacy := make(map[string]bool, len(resources.LegacyAddressPools))
+	for _, p := range resources.LegacyAddressPools {
+		legacy[p.Name] = true
+	}
+	conflicting := map[string]bool{}
+	var res []string
+	for _, p := range resources.Pools {
+		if legacy[p.Name] && !conflicting[p.Name] {
+			conflicting[p.Name] = true
+			res = append(res, p.Name)
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+
+	if legacyPrecedence {
+		pools := make([]metallbv1beta1.IPAddressPool, 0, len(resources.Pools))
+		for _, p := range resources.Pools {
+			if !conflicting[p.Name] {
+				pools = append(pools, p)
+			}
+		}
+		resources.Pools = pools
+		return res
+	}
+	legacyPools := make([]metallbv1beta1.AddressPool, 0, len(resources.LegacyAddressPools))
+	for _, p := range resources.LegacyAddressPools {
+		if !conflicting[p.Name] {
+			legacyPools = append(legacyPools, p)
+		}
+	}
+	resources.LegacyAddressPools = legacyPools
+	return res
+}
+
+// mergeByName returns a copy of existing where the items having the same
+// name of an incoming item are replaced, and the others incoming items
+// are appended.
+func mergeByName[T any, PT interface {
+	GetName() string
+	*T
+}](existing, incoming []T) []T {
+	if existing == nil && incoming == nil {
+		return nil
+	}
+	res := make([]T, len(existing), len(existing)+len(incoming))
+	copy(res, existing)
+
+	indexes := make(map[string]int, len(existing))
+	for i := range res {
+		indexes[PT(&res[i]).GetName()] = i
+	}
+
+	for i := range incoming {
+		name := PT(&incoming[i]).GetName()
+		if idx, ok := indexes[name]; ok {
+			res[idx] = incoming[i]
+			continue
+		}
+		indexes[name] = len(res)
+		res = append(res, incoming[i])
+	}
+	return res
+}
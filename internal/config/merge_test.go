@@ -0,0 +1,165 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeByName(t *testing.T) {
+	pool := func(name string, addresses ...string) v1beta1.IPAddressPool {
+		return v1beta1.IPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1beta1.IPAddressPoolSpec{Addresses: addresses},
+		}
+	}
+	peer := func(name, address string) v1beta2.BGPPeer {
+		return v1beta2.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: address},
+		}
+	}
+
+	tests := []struct {
+		desc     string
+		existing ClusterResources
+		incoming ClusterResources
+		expected ClusterResources
+	}{
+		{
+			desc: "updates resources with the same name",
+			existing: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/24"), pool("pool2", "11.0.0.0/24")},
+				Peers: []v1beta2.BGPPeer{peer("peer1", "1.2.3.4")},
+			},
+			incoming: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{pool("pool1", "12.0.0.0/24")},
+				Peers: []v1beta2.BGPPeer{peer("peer1", "1.2.3.5")},
+			},
+			expected: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{pool("pool1", "12.0.0.0/24"), pool("pool2", "11.0.0.0/24")},
+				Peers: []v1beta2.BGPPeer{peer("peer1", "1.2.3.5")},
+			},
+		},
+		{
+			desc: "appends resources with a new name",
+			existing: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/24")},
+			},
+			incoming: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{pool("pool2", "11.0.0.0/24")},
+				Peers: []v1beta2.BGPPeer{peer("peer1", "1.2.3.4")},
+			},
+			expected: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/24"), pool("pool2", "11.0.0.0/24")},
+				Peers: []v1beta2.BGPPeer{peer("peer1", "1.2.3.4")},
+			},
+		},
+		{
+			desc: "merges secrets and keeps the existing bgp extras",
+			existing: ClusterResources{
+				PasswordSecrets: map[string]corev1.Secret{
+					"secret1": {ObjectMeta: metav1.ObjectMeta{Name: "secret1"}, Type: corev1.SecretTypeOpaque},
+				},
+				BGPExtras: corev1.ConfigMap{Data: map[string]string{"extras": "foo"}},
+			},
+			incoming: ClusterResources{
+				PasswordSecrets: map[string]corev1.Secret{
+					"secret1": {ObjectMeta: metav1.ObjectMeta{Name: "secret1"}, Type: corev1.SecretTypeBasicAuth},
+					"secret2": {ObjectMeta: metav1.ObjectMeta{Name: "secret2"}},
+				},
+			},
+			expected: ClusterResources{
+				PasswordSecrets: map[string]corev1.Secret{
+					"secret1": {ObjectMeta: metav1.ObjectMeta{Name: "secret1"}, Type: corev1.SecretTypeBasicAuth},
+					"secret2": {ObjectMeta: metav1.ObjectMeta{Name: "secret2"}},
+				},
+				BGPExtras: corev1.ConfigMap{Data: map[string]string{"extras": "foo"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			merged := MergeByName(test.existing, test.incoming)
+			if !cmp.Equal(test.expected, merged) {
+				t.Fatalf("unexpected merge result (-want +got)\n%s", cmp.Diff(test.expected, merged))
+			}
+			// merging the same resources again must not change the result.
+			again := MergeByName(merged, test.incoming)
+			if !cmp.Equal(merged, again) {
+				t.Fatalf("merge is not idempotent (-want +got)\n%s", cmp.Diff(merged, again))
+			}
+		})
+	}
+}
+
+func TestDropConflictingLegacyPools(t *testing.T) {
+	pool := func(name string, addresses ...string) v1beta1.IPAddressPool {
+		return v1beta1.IPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1beta1.IPAddressPoolSpec{Addresses: addresses},
+		}
+	}
+	legacyPool := func(name string, addresses ...string) v1beta1.AddressPool {
+		return v1beta1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1beta1.AddressPoolSpec{Protocol: "layer2", Addresses: addresses},
+		}
+	}
+	resources := func() ClusterResources {
+		return ClusterResources{
+			Pools:              []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/24"), pool("pool2", "11.0.0.0/24")},
+			LegacyAddressPools: []v1beta1.AddressPool{legacyPool("pool1", "12.0.0.0/24"), legacyPool("pool3", "13.0.0.0/24")},
+		}
+	}
+
+	tests := []struct {
+		desc             string
+		legacyPrecedence bool
+		expected         ClusterResources
+	}{
+		{
+			desc:             "crds win",
+			legacyPrecedence: false,
+			expected: ClusterResources{
+				Pools:              []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/24"), pool("pool2", "11.0.0.0/24")},
+				LegacyAddressPools: []v1beta1.AddressPool{legacyPool("pool3", "13.0.0.0/24")},
+			},
+		},
+		{
+			desc:             "legacy wins",
+			legacyPrecedence: true,
+			expected: ClusterResources{
+				Pools:              []v1beta1.IPAddressPool{pool("pool2", "11.0.0.0/24")},
+				LegacyAddressPools: []v1beta1.AddressPool{legacyPool("pool1", "12.0.0.0/24"), legacyPool("pool3", "13.0.0.0/24")},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			res := resources()
+			conflicting := DropConflictingLegacyPools(&res, test.legacyPrecedence)
+			if !cmp.Equal([]string{"pool1"}, conflicting) {
+				t.Fatalf("unexpected conflicting pools %v", conflicting)
+			}
+			if !cmp.Equal(test.expected, res) {
+				t.Fatalf("unexpected resources (-want +got)\n%s", cmp.Diff(test.expected, res))
+			}
+			if _, err := For(res, DontValidate); err != nil {
+				t.Fatalf("resources still not valid: %v", err)
+			}
+		})
+	}
+
+	res := ClusterResources{Pools: []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/24")}}
+	if conflicting := DropConflictingLegacyPools(&res, true); conflicting != nil {
+		t.Fatalf("expected no conflicting pools, got %v", conflicting)
+	}
+}
@@ -36,6 +36,18 @@ func DiscardFRROnly(c ClusterResources) error {
 		if p.Spec.VRFName != "" {
 			return fmt.Errorf("peer %s has vrf set on native bgp mode", p.Spec.Address)
 		}
+		if p.Spec.EBGPMultiHopTTL != 0 {
+			return fmt.Errorf("peer %s has ebgp-multihop-ttl set on native bgp mode", p.Spec.Address)
+		}
+		if p.Spec.TTLSecurity {
+			return fmt.Errorf("peer %s has ttl-security set on native bgp mode", p.Spec.Address)
+		}
+		if p.Spec.ConnectTime != nil {
+			return fmt.Errorf("peer %s has connect-time set on native bgp mode", p.Spec.Address)
+		}
+		if p.Spec.GracefulRestart != nil && p.Spec.GracefulRestart.Enabled {
+			return fmt.Errorf("peer %s has graceful restart enabled on native bgp mode", p.Spec.Address)
+		}
 	}
 	if len(c.BFDProfiles) > 0 {
 		return errors.New("bfd profiles section set")
@@ -118,6 +130,53 @@ func findNonLegacyCommunity(c ClusterResources) error {
 	return nil
 }
 
+// MissingNamespaces returns, for each pool, the namespaces its service allocation
+// refers to that don't exist in the cluster. A pool referencing a missing namespace
+// is not an error, as the namespace can be created later, but it is worth a warning.
+func MissingNamespaces(c ClusterResources) map[string][]string {
+	existing := map[string]bool{}
+	for _, ns := range c.Namespaces {
+		existing[ns.Name] = true
+	}
+	res := map[string][]string{}
+	for _, p := range c.Pools {
+		if p.Spec.AllocateTo == nil {
+			continue
+		}
+		for _, ns := range p.Spec.AllocateTo.Namespaces {
+			if !existing[ns] {
+				res[p.Name] = append(res[p.Name], ns)
+			}
+		}
+	}
+	return res
+}
+
+// MissingPools returns, for each advertisement, the pools it references by name
+// that don't exist. Such advertisements don't advertise anything for those pools.
+// The advertisements are identified as BGPAdvertisement/<name> or L2Advertisement/<name>.
+func MissingPools(c ClusterResources) map[string][]string {
+	existing := map[string]bool{}
+	for _, p := range c.Pools {
+		existing[p.Name] = true
+	}
+	res := map[string][]string{}
+	addMissing := func(adv string, pools []string) {
+		for _, p := range pools {
+			if !existing[p] {
+				res[adv] = append(res[adv], p)
+			}
+		}
+	}
+	for _, adv := range c.BGPAdvs {
+		addMissing("BGPAdvertisement/"+adv.Name, adv.Spec.IPAddressPools)
+	}
+	for _, adv := range c.L2Advs {
+		addMissing("L2Advertisement/"+adv.Name, adv.Spec.IPAddressPools)
+	}
+	return res
+}
+
 // DontValidate is a Validate function that always returns
 // success.
 func DontValidate(c ClusterResources) error {
@@ -154,6 +213,14 @@ func DiscardNativeOnly(c ClusterResources) error {
 	return nil
 }
 
+// ValidateResources runs on the given resources the same checks For does, such as
+// the uniqueness of the peers, the references to the bfd profiles and the aggregation
+// lengths of the advertisements, without returning the resulting configuration.
+func ValidateResources(resources ClusterResources, validate Validate) error {
+	_, err := For(resources, validate)
+	return err
+}
+
 // validateConfig is meant to validate all the inter-dependencies of a parsed configuration.
 // In this case, we ensure that bfd echo is not enabled on a v6 pool.
 func validateConfig(cfg *Config) error {
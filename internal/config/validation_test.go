@@ -6,8 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -428,3 +430,219 @@ func TestValidateFRR(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateResources(t *testing.T) {
+	aggregationLength := int32(16)
+	tests := []struct {
+		desc      string
+		resources ClusterResources
+		validate  Validate
+		mustFail  bool
+	}{
+		{
+			desc: "valid resources",
+			resources: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "peer1"},
+						Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4"},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.20.0.0/24"}},
+					},
+				},
+			},
+			validate: DontValidate,
+		},
+		{
+			desc: "rejected by the validate function",
+			resources: ClusterResources{
+				BFDProfiles: []v1beta1.BFDProfile{
+					{ObjectMeta: v1.ObjectMeta{Name: "bfd"}},
+				},
+			},
+			validate: DiscardFRROnly,
+			mustFail: true,
+		},
+		{
+			desc: "conflicting peers",
+			resources: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "peer1"},
+						Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4"},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "peer2"},
+						Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4", HoldTime: v1.Duration{Duration: time.Minute}},
+					},
+				},
+			},
+			validate: DontValidate,
+			mustFail: true,
+		},
+		{
+			desc: "missing bfd profile",
+			resources: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "peer1"},
+						Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4", BFDProfile: "bfd"},
+					},
+				},
+			},
+			validate: DontValidate,
+			mustFail: true,
+		},
+		{
+			desc: "aggregation length shorter than the pool prefix",
+			resources: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.20.0.0/24"}},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: &aggregationLength,
+							IPAddressPools:    []string{"pool1"},
+						},
+					},
+				},
+			},
+			validate: DontValidate,
+			mustFail: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := ValidateResources(test.resources, test.validate)
+			_, parseErr := For(test.resources, test.validate)
+			if (err == nil) != (parseErr == nil) {
+				t.Fatalf("validation error %v doesn't match the parse error %v", err, parseErr)
+			}
+			if test.mustFail && err == nil {
+				t.Fatalf("Expected error for %s", test.desc)
+			}
+			if !test.mustFail && err != nil {
+				t.Fatalf("Not expected error %s for %s", err, test.desc)
+			}
+		})
+	}
+}
+
+func TestMissingNamespaces(t *testing.T) {
+	pool := func(name string, namespaces ...string) v1beta1.IPAddressPool {
+		return v1beta1.IPAddressPool{
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec: v1beta1.IPAddressPoolSpec{
+				Addresses:  []string{"10.20.0.0/16"},
+				AllocateTo: &v1beta1.ServiceAllocation{Namespaces: namespaces},
+			},
+		}
+	}
+	namespaces := []corev1.Namespace{
+		{ObjectMeta: v1.ObjectMeta{Name: "ns1"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "ns2"}},
+	}
+
+	tests := []struct {
+		desc     string
+		config   ClusterResources
+		expected map[string][]string
+	}{
+		{
+			desc: "all namespaces exist",
+			config: ClusterResources{
+				Pools:      []v1beta1.IPAddressPool{pool("pool1", "ns1", "ns2")},
+				Namespaces: namespaces,
+			},
+			expected: map[string][]string{},
+		},
+		{
+			desc: "pool without service allocation",
+			config: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{ObjectMeta: v1.ObjectMeta{Name: "pool1"}},
+				},
+				Namespaces: namespaces,
+			},
+			expected: map[string][]string{},
+		},
+		{
+			desc: "missing namespaces",
+			config: ClusterResources{
+				Pools:      []v1beta1.IPAddressPool{pool("pool1", "ns1", "ns3"), pool("pool2", "ns4")},
+				Namespaces: namespaces,
+			},
+			expected: map[string][]string{
+				"pool1": {"ns3"},
+				"pool2": {"ns4"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			missing := MissingNamespaces(test.config)
+			if !cmp.Equal(test.expected, missing) {
+				t.Fatalf("unexpected missing namespaces (-want +got)\n%s", cmp.Diff(test.expected, missing))
+			}
+		})
+	}
+}
+
+func TestMissingPools(t *testing.T) {
+	pools := []v1beta1.IPAddressPool{
+		{ObjectMeta: v1.ObjectMeta{Name: "pool1"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "pool2"}},
+	}
+	tests := []struct {
+		desc     string
+		config   ClusterResources
+		expected map[string][]string
+	}{
+		{
+			desc: "all pools exist",
+			config: ClusterResources{
+				Pools: pools,
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{ObjectMeta: v1.ObjectMeta{Name: "adv1"}, Spec: v1beta1.BGPAdvertisementSpec{IPAddressPools: []string{"pool1", "pool2"}}},
+				},
+				L2Advs: []v1beta1.L2Advertisement{
+					{ObjectMeta: v1.ObjectMeta{Name: "adv1"}},
+				},
+			},
+			expected: map[string][]string{},
+		},
+		{
+			desc: "missing pools",
+			config: ClusterResources{
+				Pools: pools,
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{ObjectMeta: v1.ObjectMeta{Name: "adv1"}, Spec: v1beta1.BGPAdvertisementSpec{IPAddressPools: []string{"pool1", "pool3"}}},
+				},
+				L2Advs: []v1beta1.L2Advertisement{
+					{ObjectMeta: v1.ObjectMeta{Name: "adv1"}, Spec: v1beta1.L2AdvertisementSpec{IPAddressPools: []string{"pool4"}}},
+				},
+			},
+			expected: map[string][]string{
+				"BGPAdvertisement/adv1": {"pool3"},
+				"L2Advertisement/adv1":  {"pool4"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			missing := MissingPools(test.config)
+			if !cmp.Equal(test.expected, missing) {
+				t.Fatalf("unexpected missing pools (-want +got)\n%s", cmp.Diff(test.expected, missing))
+			}
+		})
+	}
+}
@@ -58,7 +58,7 @@ func (v *validator) Validate(resources ...client.ObjectList) error {
 			clusterResources.Nodes = append(clusterResources.Nodes, list.Items...)
 		}
 	}
-	_, err := For(clusterResources, v.validate)
+	err := ValidateResources(clusterResources, v.validate)
 	if errors.As(err, &TransientError{}) { // we do not want to make assumption on ordering in webhooks.
 		return nil
 	}
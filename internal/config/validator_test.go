@@ -3,9 +3,12 @@
 package config
 
 import (
+	"strings"
 	"testing"
 
+	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/api/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestValidator(t *testing.T) {
@@ -28,3 +31,118 @@ func TestValidator(t *testing.T) {
 		t.Error("The validator should not fail for non existing bfd profile")
 	}
 }
+
+func TestValidatorPoolOverlaps(t *testing.T) {
+	v := validator{DontValidate}
+	pool := func(name string, addresses ...string) v1beta1.IPAddressPool {
+		return v1beta1.IPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1beta1.IPAddressPoolSpec{Addresses: addresses},
+		}
+	}
+
+	tests := []struct {
+		desc        string
+		pools       []v1beta1.IPAddressPool
+		expectedErr string
+	}{
+		{
+			desc:        "overlapping cidrs",
+			pools:       []v1beta1.IPAddressPool{pool("pool1", "10.20.0.0/16"), pool("pool2", "10.20.30.0/24")},
+			expectedErr: `overlaps with already defined CIDR "10.20.0.0/16" of pool "pool1"`,
+		},
+		{
+			desc:        "overlapping ranges",
+			pools:       []v1beta1.IPAddressPool{pool("pool1", "10.0.0.1-10.0.0.10"), pool("pool2", "10.0.0.10-10.0.0.20")},
+			expectedErr: `of pool "pool1"`,
+		},
+		{
+			desc:        "range overlapping a cidr",
+			pools:       []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/24"), pool("pool2", "10.0.0.250-10.0.1.5")},
+			expectedErr: `of pool "pool1"`,
+		},
+		{
+			desc:  "adjacent ranges",
+			pools: []v1beta1.IPAddressPool{pool("pool1", "10.0.0.1-10.0.0.10"), pool("pool2", "10.0.0.11-10.0.0.20")},
+		},
+		{
+			desc:  "adjacent cidrs",
+			pools: []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/25"), pool("pool2", "10.0.0.128/25")},
+		},
+		{
+			desc:  "disjoint pools",
+			pools: []v1beta1.IPAddressPool{pool("pool1", "10.0.0.0/24"), pool("pool2", "192.168.0.0/24", "fc00::/64")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := v.Validate(&v1beta1.IPAddressPoolList{Items: test.pools})
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidatorPeerConflicts(t *testing.T) {
+	v := validator{DontValidate}
+	peer := func(name string, port uint16, selectors ...metav1.LabelSelector) v1beta2.BGPPeer {
+		return v1beta2.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1beta2.BGPPeerSpec{
+				MyASN:         42,
+				ASN:           142,
+				Address:       "1.2.3.4",
+				Port:          port,
+				NodeSelectors: selectors,
+			},
+		}
+	}
+	nodeA := metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/hostname": "nodeA"}}
+	nodeB := metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/hostname": "nodeB"}}
+
+	tests := []struct {
+		desc        string
+		peers       []v1beta2.BGPPeer
+		expectedErr string
+	}{
+		{
+			desc:        "same session",
+			peers:       []v1beta2.BGPPeer{peer("peer1", 179), peer("peer2", 179)},
+			expectedErr: "peer peer2 conflicts with existing peer peer1",
+		},
+		{
+			desc:        "same session from the same nodes",
+			peers:       []v1beta2.BGPPeer{peer("peer1", 179, nodeA), peer("peer2", 179, nodeA)},
+			expectedErr: "peer peer2 conflicts with existing peer peer1",
+		},
+		{
+			desc:  "different port",
+			peers: []v1beta2.BGPPeer{peer("peer1", 179), peer("peer2", 1179)},
+		},
+		{
+			desc:  "different nodes",
+			peers: []v1beta2.BGPPeer{peer("peer1", 179, nodeA), peer("peer2", 179, nodeB)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := v.Validate(&v1beta2.BGPPeerList{Items: test.peers})
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
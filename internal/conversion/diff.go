@@ -0,0 +1,214 @@
+// SPDX-License-Identifier:Apache-2.0
+
+// Package conversion compares MetalLB configurations, e.g. the resources
+// converted from the legacy ConfigMap and the ones deployed as CRs.
+package conversion
+
+import (
+	"fmt"
+	"sort"
+
+	"go.universe.tf/metallb/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// ChangeType is the type of change of a resource between two configurations.
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Removed  ChangeType = "removed"
+	Modified ChangeType = "modified"
+)
+
+// Change is a resource added, removed or modified between two configurations.
+type Change struct {
+	Type      ChangeType `json:"type"`
+	Kind      string     `json:"kind"`
+	Namespace string     `json:"namespace,omitempty"`
+	Name      string     `json:"name"`
+}
+
+func (c Change) String() string {
+	name := c.Name
+	if c.Namespace != "" {
+		name = c.Namespace + "/" + c.Name
+	}
+	return fmt.Sprintf("%s %s %s", c.Type, c.Kind, name)
+}
+
+// object is the part of a resource relevant to the configuration: its
+// identity and the content that is compared.
+type object struct {
+	namespace string
+	name      string
+	content   interface{}
+}
+
+// Diff returns the resources added, removed or modified going from the old
+// configuration to the new one, sorted by kind and then by namespace and name.
+// Only the content of the resources is compared, ignoring their metadata
+// such as the resource version. The nodes and the namespaces, which are not
+// part of the configuration, are not compared. A nil configuration is the same
+// as an empty one. An error is returned if one of the configurations has two
+// resources of the same kind with the same name.
+func Diff(old, new *config.ClusterResources) ([]Change, error) {
+	if old == nil {
+		old = &config.ClusterResources{}
+	}
+	if new == nil {
+		new = &config.ClusterResources{}
+	}
+
+	kinds := []struct {
+		kind    string
+		objects func(*config.ClusterResources) []object
+	}{
+		{"AddressPool", legacyPoolObjects},
+		{"BFDProfile", bfdProfileObjects},
+		{"BGPAdvertisement", bgpAdvertisementObjects},
+		{"BGPPeer", peerObjects},
+		{"Community", communityObjects},
+		{"ConfigMap", bgpExtrasObjects},
+		{"IPAddressPool", poolObjects},
+		{"L2Advertisement", l2AdvertisementObjects},
+		{"Secret", passwordSecretObjects},
+	}
+
+	res := []Change{}
+	for _, k := range kinds {
+		changes, err := diffObjects(k.kind, k.objects(old), k.objects(new))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, changes...)
+	}
+	return res, nil
+}
+
+func diffObjects(kind string, old, new []object) ([]Change, error) {
+	oldByKey, err := objectsByKey(kind, old)
+	if err != nil {
+		return nil, err
+	}
+	newByKey, err := objectsByKey(kind, new)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []Change{}
+	for k, o := range oldByKey {
+		n, ok := newByKey[k]
+		switch {
+		case !ok:
+			res = append(res, Change{Type: Removed, Kind: kind, Namespace: o.namespace, Name: o.name})
+		case !equality.Semantic.DeepEqual(o.content, n.content):
+			res = append(res, Change{Type: Modified, Kind: kind, Namespace: o.namespace, Name: o.name})
+		}
+	}
+	for k, n := range newByKey {
+		if _, ok := oldByKey[k]; !ok {
+			res = append(res, Change{Type: Added, Kind: kind, Namespace: n.namespace, Name: n.name})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Namespace != res[j].Namespace {
+			return res[i].Namespace < res[j].Namespace
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+func objectsByKey(kind string, objects []object) (map[string]object, error) {
+	res := map[string]object{}
+	for _, o := range objects {
+		key := o.namespace + "/" + o.name
+		if _, ok := res[key]; ok {
+			return nil, fmt.Errorf("duplicate %s %s", kind, key)
+		}
+		res[key] = o
+	}
+	return res, nil
+}
+
+func bfdProfileObjects(r *config.ClusterResources) []object {
+	res := []object{}
+	for _, o := range r.BFDProfiles {
+		res = append(res, object{namespace: o.Namespace, name: o.Name, content: o.Spec})
+	}
+	return res
+}
+
+func bgpAdvertisementObjects(r *config.ClusterResources) []object {
+	res := []object{}
+	for _, o := range r.BGPAdvs {
+		res = append(res, object{namespace: o.Namespace, name: o.Name, content: o.Spec})
+	}
+	return res
+}
+
+func peerObjects(r *config.ClusterResources) []object {
+	res := []object{}
+	for _, o := range r.Peers {
+		res = append(res, object{namespace: o.Namespace, name: o.Name, content: o.Spec})
+	}
+	return res
+}
+
+func communityObjects(r *config.ClusterResources) []object {
+	res := []object{}
+	for _, o := range r.Communities {
+		res = append(res, object{namespace: o.Namespace, name: o.Name, content: o.Spec})
+	}
+	return res
+}
+
+// bgpExtrasObjects returns the bgp extras ConfigMap, if any, comparing only
+// its data.
+func bgpExtrasObjects(r *config.ClusterResources) []object {
+	if r.BGPExtras.Name == "" {
+		return []object{}
+	}
+	return []object{{namespace: r.BGPExtras.Namespace, name: r.BGPExtras.Name, content: r.BGPExtras.Data}}
+}
+
+func poolObjects(r *config.ClusterResources) []object {
+	res := []object{}
+	for _, o := range r.Pools {
+		res = append(res, object{namespace: o.Namespace, name: o.Name, content: o.Spec})
+	}
+	return res
+}
+
+func legacyPoolObjects(r *config.ClusterResources) []object {
+	res := []object{}
+	for _, o := range r.LegacyAddressPools {
+		res = append(res, object{namespace: o.Namespace, name: o.Name, content: o.Spec})
+	}
+	return res
+}
+
+func l2AdvertisementObjects(r *config.ClusterResources) []object {
+	res := []object{}
+	for _, o := range r.L2Advs {
+		res = append(res, object{namespace: o.Namespace, name: o.Name, content: o.Spec})
+	}
+	return res
+}
+
+// passwordSecretObjects returns the secrets holding the passwords of the
+// peers, comparing their type and their data.
+func passwordSecretObjects(r *config.ClusterResources) []object {
+	res := []object{}
+	for _, o := range r.PasswordSecrets {
+		content := struct {
+			Type       corev1.SecretType
+			Data       map[string][]byte
+			StringData map[string]string
+		}{o.Type, o.Data, o.StringData}
+		res = append(res, object{namespace: o.Namespace, name: o.Name, content: content})
+	}
+	return res
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package conversion
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testNamespace = "metallb-system"
+
+func testResources() *config.ClusterResources {
+	return &config.ClusterResources{
+		Peers: []v1beta2.BGPPeer{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer1", Namespace: testNamespace},
+				Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer2", Namespace: testNamespace},
+				Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 143, Address: "1.2.3.5"},
+			},
+		},
+		Pools: []v1beta1.IPAddressPool{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "pool1", Namespace: testNamespace},
+				Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.20.0.0/16"}},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "pool2", Namespace: testNamespace},
+				Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.30.0.0/16"}},
+			},
+		},
+		L2Advs: []v1beta1.L2Advertisement{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "l2adv1", Namespace: testNamespace},
+				Spec:       v1beta1.L2AdvertisementSpec{IPAddressPools: []string{"pool1"}},
+			},
+		},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		desc     string
+		modify   func(*config.ClusterResources)
+		expected []Change
+	}{
+		{
+			desc:     "same resources",
+			modify:   func(*config.ClusterResources) {},
+			expected: []Change{},
+		},
+		{
+			desc: "only metadata changed",
+			modify: func(r *config.ClusterResources) {
+				r.Peers[0].ResourceVersion = "2"
+				r.Pools[0].Labels = map[string]string{"foo": "bar"}
+			},
+			expected: []Change{},
+		},
+		{
+			desc: "one peer and one pool added",
+			modify: func(r *config.ClusterResources) {
+				r.Peers = append(r.Peers, v1beta2.BGPPeer{
+					ObjectMeta: metav1.ObjectMeta{Name: "peer3", Namespace: testNamespace},
+					Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 144, Address: "1.2.3.6"},
+				})
+				r.Pools = append(r.Pools, v1beta1.IPAddressPool{
+					ObjectMeta: metav1.ObjectMeta{Name: "pool3", Namespace: testNamespace},
+					Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.40.0.0/16"}},
+				})
+			},
+			expected: []Change{
+				{Type: Added, Kind: "BGPPeer", Namespace: testNamespace, Name: "peer3"},
+				{Type: Added, Kind: "IPAddressPool", Namespace: testNamespace, Name: "pool3"},
+			},
+		},
+		{
+			desc: "one peer and one pool removed",
+			modify: func(r *config.ClusterResources) {
+				r.Peers = r.Peers[:1]
+				r.Pools = r.Pools[1:]
+			},
+			expected: []Change{
+				{Type: Removed, Kind: "BGPPeer", Namespace: testNamespace, Name: "peer2"},
+				{Type: Removed, Kind: "IPAddressPool", Namespace: testNamespace, Name: "pool1"},
+			},
+		},
+		{
+			desc: "one peer and one pool modified",
+			modify: func(r *config.ClusterResources) {
+				r.Peers[1].Spec.ASN = 150
+				r.Pools[0].Spec.Addresses = []string{"10.20.0.0/24"}
+			},
+			expected: []Change{
+				{Type: Modified, Kind: "BGPPeer", Namespace: testNamespace, Name: "peer2"},
+				{Type: Modified, Kind: "IPAddressPool", Namespace: testNamespace, Name: "pool1"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			old, new := testResources(), testResources()
+			test.modify(new)
+			changes, err := Diff(old, new)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(test.expected, changes) {
+				t.Fatalf("unexpected changes (-want +got):\n%s", cmp.Diff(test.expected, changes))
+			}
+		})
+	}
+}
+
+func TestDiffNil(t *testing.T) {
+	changes, err := Diff(nil, testResources())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(changes) != 5 {
+		t.Fatalf("expected 5 changes, got %v", changes)
+	}
+	for _, c := range changes {
+		if c.Type != Added {
+			t.Fatalf("expected only added resources, got %s", c)
+		}
+	}
+}
+
+func TestDiffDuplicate(t *testing.T) {
+	r := testResources()
+	r.Pools[1].Name = r.Pools[0].Name
+	if _, err := Diff(testResources(), r); err == nil {
+		t.Fatalf("expected error for duplicate pools, got nil")
+	}
+}
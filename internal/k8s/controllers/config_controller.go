@@ -18,7 +18,10 @@ package controllers
 
 import (
 	"context"
+	"net"
 	"reflect"
+	"strconv"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -27,8 +30,10 @@ import (
 	"go.universe.tf/metallb/internal/config"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -47,7 +52,20 @@ type ConfigReconciler struct {
 	ValidateConfig config.Validate
 	ForceReload    func()
 	BGPType        string
+	Rendered       *RenderedConfig
+	Recorder       record.EventRecorder
+	// RetryBaseDelay, RetryMaxDelay and RetryJitter define the exponential
+	// backoff used to reload the configuration when the handler fails, see
+	// backoffDelay. A zero RetryBaseDelay keeps the default backoff of the
+	// controller, a zero RetryMaxDelay doesn't cap it.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	RetryJitter    float64
 	currentConfig  *config.Config
+	failures       int
+	// unmatchedSelectors are the objects whose node selectors matched no
+	// node at the last reconcile, keyed as <kind>/<name>.
+	unmatchedSelectors map[string]bool
 }
 
 func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -148,6 +166,8 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, nil
 	}
 
+	r.warnSelectorsWithoutNodes(bgpPeers.Items, l2Advertisements.Items, nodes.Items)
+
 	if cfg.BGPExtras != "" {
 		level.Info(r.Logger).Log("controller", "ConfigReconciler", "warning message", "BGP Extras provided, please note that this configuration is not supported and used at your own risk")
 	}
@@ -168,7 +188,11 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 		// of the reconciliaton loop. If we don't reset, the retry will find the config identical and will exit,
 		// which is not what we want here.
 		r.currentConfig = nil
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "metallb CRs and Secrets", dumpClusterResources(&resources), "event", "reload failed, retry")
+		r.failures++
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "metallb CRs and Secrets", dumpClusterResources(&resources), "event", "reload failed, retry", "failures", r.failures)
+		if r.RetryBaseDelay > 0 {
+			return ctrl.Result{RequeueAfter: backoffDelay(r.RetryBaseDelay, r.RetryMaxDelay, r.RetryJitter, r.failures)}, nil
+		}
 		return ctrl.Result{}, errRetry
 	case SyncStateReprocessAll:
 		level.Info(r.Logger).Log("controller", "ConfigReconciler", "event", "force service reload")
@@ -180,12 +204,102 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, nil
 	}
 
+	r.failures = 0
+	r.Rendered.set(cfg)
+	updatePeersConfigured(cfg)
 	configLoaded.Set(1)
 	configStale.Set(0)
 	level.Info(r.Logger).Log("controller", "ConfigReconciler", "event", "config reloaded")
 	return ctrl.Result{}, nil
 }
 
+// updatePeersConfigured sets the peersConfigured gauge to the peers of the
+// loaded configuration, dropping the series of the removed ones. The peers are
+// labeled as the sessions of the speakers, by address and port, or by
+// interface for the unnumbered ones. The VRF and the name of the peer are
+// labels too, as peers with the same address and ASN can differ by VRF or by
+// the nodes they are selected by.
+func updatePeersConfigured(cfg *config.Config) {
+	peersConfigured.Reset()
+	for _, p := range cfg.Peers {
+		addr := p.Interface
+		if p.Addr != nil {
+			addr = net.JoinHostPort(p.Addr.String(), strconv.Itoa(int(p.Port)))
+		}
+		asn := p.DynamicASN
+		if asn == "" {
+			asn = strconv.FormatUint(uint64(p.ASN), 10)
+		}
+		expected := 1.0
+		if p.Disabled {
+			expected = 0
+		}
+		peersConfigured.WithLabelValues(addr, asn, p.VRF, p.Name).Set(expected)
+	}
+}
+
+// warnSelectorsWithoutNodes warns about the peers and the l2 advertisements
+// whose node selectors don't match any node, as no speaker would use them.
+// Since the nodes can be added later, the configuration is not rejected.
+// The events are recorded only when an object starts not matching any node,
+// not at every reconcile.
+func (r *ConfigReconciler) warnSelectorsWithoutNodes(peers []metallbv1beta2.BGPPeer, l2Advs []metallbv1beta1.L2Advertisement, nodes []corev1.Node) {
+	unmatched := map[string]bool{}
+	for i := range peers {
+		if selectorsMatchNodes(peers[i].Spec.NodeSelectors, nodes) {
+			continue
+		}
+		key := "BGPPeer/" + peers[i].Name
+		unmatched[key] = true
+		level.Warn(r.Logger).Log("controller", "ConfigReconciler", "warning", "node selectors don't match any node", "peer", peers[i].Name)
+		if !r.unmatchedSelectors[key] {
+			r.recordSelectorWithoutNodes(&peers[i])
+		}
+	}
+	for i := range l2Advs {
+		if selectorsMatchNodes(l2Advs[i].Spec.NodeSelectors, nodes) {
+			continue
+		}
+		key := "L2Advertisement/" + l2Advs[i].Name
+		unmatched[key] = true
+		level.Warn(r.Logger).Log("controller", "ConfigReconciler", "warning", "node selectors don't match any node", "l2advertisement", l2Advs[i].Name)
+		if !r.unmatchedSelectors[key] {
+			r.recordSelectorWithoutNodes(&l2Advs[i])
+		}
+	}
+	r.unmatchedSelectors = unmatched
+	selectorsWithoutNodes.Set(float64(len(unmatched)))
+}
+
+func (r *ConfigReconciler) recordSelectorWithoutNodes(obj runtime.Object) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(obj, corev1.EventTypeWarning, WarningNodeSelectorWithoutNodes,
+		"node selectors don't match any node")
+}
+
+// selectorsMatchNodes tells if any of the given node selectors matches one of
+// the nodes. No selectors match all the nodes. The invalid selectors, which
+// are reported when parsing the configuration, match no nodes.
+func selectorsMatchNodes(selectors []metav1.LabelSelector, nodes []corev1.Node) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	for i := range selectors {
+		sel, err := metav1.LabelSelectorAsSelector(&selectors[i])
+		if err != nil {
+			continue
+		}
+		for _, n := range nodes {
+			if sel.Matches(labels.Set(n.Labels)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	p := predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
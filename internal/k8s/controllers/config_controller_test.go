@@ -18,7 +18,10 @@ package controllers
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -27,6 +30,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	v1beta1 "go.universe.tf/metallb/api/v1beta1"
 	v1beta2 "go.universe.tf/metallb/api/v1beta2"
 	"go.universe.tf/metallb/internal/config"
@@ -35,7 +39,9 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -145,6 +151,56 @@ func TestConfigController(t *testing.T) {
 	}
 }
 
+func TestConfigRenderedDump(t *testing.T) {
+	initObjects := objectsFromResources(configControllerValidResources)
+	fakeClient, err := newFakeClient(initObjects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	rendered := &RenderedConfig{}
+	r := &ConfigReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: config.DontValidate,
+		Handler: func(l log.Logger, cfg *config.Config) SyncState {
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+		Rendered:    rendered,
+	}
+
+	dump := func() (int, string) {
+		rec := httptest.NewRecorder()
+		rendered.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, _ := dump(); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d before the first reconcile, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	code, body := dump()
+	if code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, code)
+	}
+	if !strings.Contains(body, "1.2.3.4") {
+		t.Fatalf("expected the dump to contain the peer address, got %s", body)
+	}
+}
+
 func TestSecretShouldntTrigger(t *testing.T) {
 	initObjects := objectsFromResources(configControllerValidResources)
 	fakeClient, err := newFakeClient(initObjects)
@@ -189,7 +245,7 @@ func TestSecretShouldntTrigger(t *testing.T) {
 		Spec: v1beta2.BGPPeerSpec{
 			MyASN:      42,
 			ASN:        142,
-			Address:    "1.2.3.4",
+			Address:    "1.2.3.5",
 			BFDProfile: "default",
 		},
 	})
@@ -219,6 +275,256 @@ func TestSecretShouldntTrigger(t *testing.T) {
 	}
 }
 
+func TestConfigRetryBackoff(t *testing.T) {
+	initObjects := objectsFromResources(configControllerValidResources)
+	fakeClient, err := newFakeClient(initObjects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	const retryJitter = 0.5
+	r := &ConfigReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: config.DontValidate,
+		Handler: func(l log.Logger, cfg *config.Config) SyncState {
+			return SyncStateError
+		},
+		ForceReload:    func() {},
+		Rendered:       &RenderedConfig{},
+		RetryBaseDelay: 10 * time.Second,
+		RetryMaxDelay:  40 * time.Second,
+		RetryJitter:    retryJitter,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+
+	for _, delay := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 40 * time.Second} {
+		res, err := r.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("expected the reconcile to be requeued without error, got %v", err)
+		}
+		maxDelay := time.Duration(float64(delay) * (1 + retryJitter))
+		if res.RequeueAfter < delay || res.RequeueAfter > maxDelay {
+			t.Fatalf("expected requeue after between %s and %s, got %s", delay, maxDelay, res.RequeueAfter)
+		}
+	}
+
+	r.RetryBaseDelay = 0
+	if _, err := r.Reconcile(context.TODO(), req); err != errRetry {
+		t.Fatalf("expected %v without a retry delay, got %v", errRetry, err)
+	}
+}
+
+func TestConfigSelectorsWithoutNodes(t *testing.T) {
+	tests := []struct {
+		desc     string
+		labels   map[string]string
+		expected []string
+	}{
+		{
+			desc:     "selector matching one node",
+			labels:   map[string]string{"rack": "a"},
+			expected: []string{},
+		},
+		{
+			desc:   "selector matching zero nodes",
+			labels: map[string]string{"rack": "b"},
+			expected: []string{
+				"Warning NodeSelectorWithoutNodes node selectors don't match any node",
+				"Warning NodeSelectorWithoutNodes node selectors don't match any node",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			selectors := []metav1.LabelSelector{{MatchLabels: map[string]string{"rack": "a"}}}
+			objects := []client.Object{
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: test.labels}},
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+				&v1beta2.BGPPeer{
+					ObjectMeta: metav1.ObjectMeta{Name: "peer1", Namespace: testNamespace},
+					Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4", NodeSelectors: selectors},
+				},
+				&v1beta2.BGPPeer{
+					ObjectMeta: metav1.ObjectMeta{Name: "peer2", Namespace: testNamespace},
+					Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.5"},
+				},
+				&v1beta1.L2Advertisement{
+					ObjectMeta: metav1.ObjectMeta{Name: "l2adv1", Namespace: testNamespace},
+					Spec:       v1beta1.L2AdvertisementSpec{NodeSelectors: selectors},
+				},
+			}
+			fakeClient, err := newFakeClient(objects)
+			if err != nil {
+				t.Fatalf("test failed to create fake client: %v", err)
+			}
+
+			recorder := record.NewFakeRecorder(10)
+			r := &ConfigReconciler{
+				Client:         fakeClient,
+				Logger:         log.NewNopLogger(),
+				Scheme:         scheme,
+				Namespace:      testNamespace,
+				ValidateConfig: config.DontValidate,
+				Handler: func(l log.Logger, cfg *config.Config) SyncState {
+					return SyncStateSuccess
+				},
+				ForceReload: func() {},
+				Recorder:    recorder,
+			}
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: testNamespace,
+				},
+			}
+			if _, err := r.Reconcile(context.TODO(), req); err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+
+			events := []string{}
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if !cmp.Equal(test.expected, events) {
+				t.Fatalf("unexpected events (-want +got)\n%s", cmp.Diff(test.expected, events))
+			}
+			if metric := testutil.ToFloat64(selectorsWithoutNodes); metric != float64(len(test.expected)) {
+				t.Fatalf("expected %d selectors without nodes, got %v", len(test.expected), metric)
+			}
+
+			// the events are not recorded again while the selectors keep not matching.
+			if _, err := r.Reconcile(context.TODO(), req); err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+			if len(recorder.Events) != 0 {
+				t.Fatalf("expected no events on the second reconcile, got %d", len(recorder.Events))
+			}
+			if metric := testutil.ToFloat64(selectorsWithoutNodes); metric != float64(len(test.expected)) {
+				t.Fatalf("expected %d selectors without nodes, got %v", len(test.expected), metric)
+			}
+		})
+	}
+}
+
+func TestConfigPeersConfigured(t *testing.T) {
+	objects := []client.Object{
+		&v1beta2.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: "peer1", Namespace: testNamespace},
+			Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4", Port: 179},
+		},
+		&v1beta2.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: "peer2", Namespace: testNamespace},
+			Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 143, Address: "1.2.3.5", Port: 1179, Disabled: true},
+		},
+	}
+	fakeClient, err := newFakeClient(objects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	r := &ConfigReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: config.DontValidate,
+		Handler: func(l log.Logger, cfg *config.Config) SyncState {
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if count := testutil.CollectAndCount(peersConfigured); count != 2 {
+		t.Fatalf("expected 2 configured peers, got %d", count)
+	}
+	if metric := testutil.ToFloat64(peersConfigured.WithLabelValues("1.2.3.4:179", "142", "", "peer1")); metric != 1 {
+		t.Fatalf("expected peer1 to be expected up, got %v", metric)
+	}
+	if metric := testutil.ToFloat64(peersConfigured.WithLabelValues("1.2.3.5:1179", "143", "", "peer2")); metric != 0 {
+		t.Fatalf("expected the disabled peer2 not to be expected up, got %v", metric)
+	}
+
+	if err := fakeClient.Delete(context.TODO(), objects[1]); err != nil {
+		t.Fatalf("failed to delete peer2: %v", err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if count := testutil.CollectAndCount(peersConfigured); count != 1 {
+		t.Fatalf("expected the removed peer to be dropped, got %d configured peers", count)
+	}
+	if metric := testutil.ToFloat64(peersConfigured.WithLabelValues("1.2.3.4:179", "142", "", "peer1")); metric != 1 {
+		t.Fatalf("expected peer1 to be expected up, got %v", metric)
+	}
+}
+
+func TestConfigPeersConfiguredSameAddress(t *testing.T) {
+	objects := []client.Object{
+		&v1beta2.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: "peer1", Namespace: testNamespace},
+			Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4", Port: 179},
+		},
+		&v1beta2.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: "peer-red", Namespace: testNamespace},
+			Spec:       v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4", Port: 179, VRFName: "red", Disabled: true},
+		},
+		&v1beta2.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: "peer-nodeA", Namespace: testNamespace},
+			Spec: v1beta2.BGPPeerSpec{MyASN: 42, ASN: 142, Address: "1.2.3.4", Port: 179,
+				NodeSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{"kubernetes.io/hostname": "nodeA"}}}},
+		},
+	}
+	fakeClient, err := newFakeClient(objects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	r := &ConfigReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: config.DontValidate,
+		Handler: func(l log.Logger, cfg *config.Config) SyncState {
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if count := testutil.CollectAndCount(peersConfigured); count != 3 {
+		t.Fatalf("expected 3 configured peers, got %d", count)
+	}
+	if metric := testutil.ToFloat64(peersConfigured.WithLabelValues("1.2.3.4:179", "142", "", "peer1")); metric != 1 {
+		t.Fatalf("expected peer1 to be expected up, got %v", metric)
+	}
+	if metric := testutil.ToFloat64(peersConfigured.WithLabelValues("1.2.3.4:179", "142", "red", "peer-red")); metric != 0 {
+		t.Fatalf("expected the disabled peer-red not to be expected up, got %v", metric)
+	}
+	if metric := testutil.ToFloat64(peersConfigured.WithLabelValues("1.2.3.4:179", "142", "", "peer-nodeA")); metric != 1 {
+		t.Fatalf("expected peer-nodeA to be expected up, got %v", metric)
+	}
+}
+
 func TestNodeEvent(t *testing.T) {
 	g := NewGomegaWithT(t)
 	testEnv := &envtest.Environment{
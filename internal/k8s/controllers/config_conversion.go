@@ -3,6 +3,7 @@
 package controllers
 
 import (
+	"errors"
 	"sort"
 
 	"go.universe.tf/metallb/internal/config"
@@ -27,6 +28,17 @@ func toConfig(fromK8s config.ClusterResources, validate config.Validate) (*confi
 	return cfg, err
 }
 
+// syncStateForError returns the sync state matching an error returned while
+// converting the resources to a configuration. Transient errors depend on
+// resources that may show up later and are worth a retry, while conversion
+// and validation errors need the configuration to be fixed.
+func syncStateForError(err error) SyncState {
+	if errors.As(err, &config.TransientError{}) {
+		return SyncStateError
+	}
+	return SyncStateErrorNoRetry
+}
+
 // We need to do this ballet because we need to leverage the GetName() function
 // of the objects, but the interface is implemented by the pointer, not the object,
 // whereas what we are given with .Items is the slice of objects.
@@ -3,6 +3,8 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
 	"reflect"
 	"testing"
@@ -252,3 +254,39 @@ func TestConversionIsStable(t *testing.T) {
 func shuffleObjects[T any](toShuffle []T) {
 	rand.Shuffle(len(toShuffle), func(i, j int) { toShuffle[i], toShuffle[j] = toShuffle[j], toShuffle[i] })
 }
+
+func TestSyncStateForError(t *testing.T) {
+	tests := []struct {
+		desc     string
+		err      error
+		expected SyncState
+	}{
+		{
+			desc:     "transient error",
+			err:      config.TransientError{Message: "missing community"},
+			expected: SyncStateError,
+		},
+		{
+			desc:     "wrapped transient error",
+			err:      fmt.Errorf("parsing pool: %w", config.TransientError{Message: "missing community"}),
+			expected: SyncStateError,
+		},
+		{
+			desc:     "conversion error",
+			err:      &config.ConversionError{Kind: config.ValidationError, Name: "peer1", Reason: "invalid hold time"},
+			expected: SyncStateErrorNoRetry,
+		},
+		{
+			desc:     "generic error",
+			err:      errors.New("invalid CIDR"),
+			expected: SyncStateErrorNoRetry,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if res := syncStateForError(test.err); res != test.expected {
+				t.Fatalf("expected sync state %d, got %d", test.expected, res)
+			}
+		})
+	}
+}
@@ -9,8 +9,13 @@ import (
 	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
 	"go.universe.tf/metallb/internal/config"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// redactedPassword replaces the passwords in the dumps, which end up in
+// the debug logs and must not leak the credentials of the peers.
+const redactedPassword = "****"
+
 func dumpClusterResources(c *config.ClusterResources) string {
 	withNoSecret := config.ClusterResources{
 		Pools:              c.Pools,
@@ -26,6 +31,8 @@ func dumpClusterResources(c *config.ClusterResources) string {
 	for k, s := range c.PasswordSecrets {
 		secretToDump := *s.DeepCopy()
 		secretToDump.Data = nil
+		secretToDump.StringData = nil
+		redactLastApplied(&secretToDump.ObjectMeta)
 		withNoSecret.PasswordSecrets[k] = secretToDump
 	}
 	return dumpResource(withNoSecret)
@@ -36,7 +43,7 @@ func dumpConfig(cfg *config.Config) string {
 	toDump.Peers = make(map[string]*config.Peer, 0)
 	for _, p := range cfg.Peers {
 		p1 := *p
-		p1.Password = "<retracted>"
+		p1.Password = redactPassword(p1.Password)
 		toDump.Peers[p.Name] = &p1
 	}
 	return spew.Sdump(toDump)
@@ -54,8 +61,24 @@ func sanitizeBGPPeer(peers ...metallbv1beta2.BGPPeer) []metallbv1beta2.BGPPeer {
 	res := make([]metallbv1beta2.BGPPeer, 0)
 	for _, p := range peers {
 		toAdd := p.DeepCopy()
-		toAdd.Spec.Password = "<retracted>"
+		toAdd.Spec.Password = redactPassword(toAdd.Spec.Password)
+		redactLastApplied(&toAdd.ObjectMeta)
 		res = append(res, *toAdd)
 	}
 	return res
 }
+
+func redactPassword(password string) string {
+	if password == "" {
+		return ""
+	}
+	return redactedPassword
+}
+
+// redactLastApplied redacts the annotation kubectl sets with the applied
+// object, as it contains the passwords in clear text.
+func redactLastApplied(meta *metav1.ObjectMeta) {
+	if _, ok := meta.Annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		meta.Annotations[corev1.LastAppliedConfigAnnotation] = redactedPassword
+	}
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDumpRedactsPasswords(t *testing.T) {
+	const password = "s3cr3tpass"
+	lastApplied := map[string]string{
+		corev1.LastAppliedConfigAnnotation: `{"spec":{"password":"` + password + `"}}`,
+	}
+	resources := config.ClusterResources{
+		Peers: []v1beta2.BGPPeer{
+			{
+				ObjectMeta: v1.ObjectMeta{Name: "peer1", Namespace: testNamespace, Annotations: lastApplied},
+				Spec: v1beta2.BGPPeerSpec{
+					Address:  "10.0.0.1",
+					Password: password,
+				},
+			},
+			{
+				ObjectMeta: v1.ObjectMeta{Name: "peer2", Namespace: testNamespace},
+				Spec: v1beta2.BGPPeerSpec{
+					Address:        "10.0.0.2",
+					PasswordSecret: corev1.SecretReference{Name: "secret1", Namespace: testNamespace},
+				},
+			},
+		},
+		PasswordSecrets: map[string]corev1.Secret{
+			"secret1": {
+				ObjectMeta: v1.ObjectMeta{Name: "secret1", Namespace: testNamespace, Annotations: lastApplied},
+				Type:       corev1.SecretTypeBasicAuth,
+				Data:       map[string][]byte{"password": []byte(password)},
+				StringData: map[string]string{"password": password},
+			},
+		},
+	}
+
+	dumped := dumpClusterResources(&resources)
+	if strings.Contains(dumped, password) {
+		t.Fatalf("password found in the dump of the resources: %s", dumped)
+	}
+	for _, s := range []string{"10.0.0.1", "10.0.0.2", "secret1", redactedPassword} {
+		if !strings.Contains(dumped, s) {
+			t.Fatalf("expected %q in the dump of the resources: %s", s, dumped)
+		}
+	}
+	if resources.Peers[0].Spec.Password != password || len(resources.PasswordSecrets["secret1"].Data) == 0 {
+		t.Fatalf("the dumped resources were modified")
+	}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {Name: "peer1", Password: password},
+		},
+	}
+	dumped = dumpConfig(cfg)
+	if strings.Contains(dumped, password) {
+		t.Fatalf("password found in the dump of the config: %s", dumped)
+	}
+	if !strings.Contains(dumped, redactedPassword) {
+		t.Fatalf("expected %q in the dump of the config: %s", redactedPassword, dumped)
+	}
+}
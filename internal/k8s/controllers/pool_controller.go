@@ -18,15 +18,27 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/internal/bgp/community"
 	"go.universe.tf/metallb/internal/config"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -41,37 +53,88 @@ type PoolReconciler struct {
 	Handler        func(log.Logger, *config.Pools) SyncState
 	ValidateConfig config.Validate
 	ForceReload    func()
-	currentConfig  *config.Config
+	// ResyncPeriod is the interval after which the configuration is pushed
+	// to the handler again, even if no event was received. Zero disables it.
+	ResyncPeriod time.Duration
+	// DryRun makes the reconciler only report how the pools would change,
+	// without calling the handler.
+	DryRun bool
+	// ObserveOnly replaces the handler with one recording how the pools would
+	// change, without applying them. Differently from DryRun, the configuration
+	// is tracked as if it was applied, so that the reconciler keeps running as
+	// it would with the real handler.
+	ObserveOnly bool
+	// RetryBaseDelay, RetryMaxDelay and RetryJitter define the exponential
+	// backoff used to retry when the handler fails, see backoffDelay. A zero
+	// RetryBaseDelay keeps the default backoff of the controller, a zero
+	// RetryMaxDelay doesn't cap it.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	RetryJitter    float64
+	// LegacyPrecedence makes the legacy AddressPools win over the IPAddressPools
+	// having the same name. By default, the IPAddressPools win.
+	LegacyPrecedence bool
+	// WatchNamespaces, when set, restricts the namespaces considered for the
+	// service allocation to the given ones: only them can be matched by the
+	// namespace selectors of the pools. Empty means all the namespaces.
+	WatchNamespaces []string
+	// Rendered, when set, is updated with the configuration applied.
+	Rendered *RenderedConfig
+	// PoolsInUse returns the pools currently backing services. When set, a
+	// configuration leaving one of them without advertisements is rejected.
+	PoolsInUse func() []string
+	Recorder   record.EventRecorder
+	// configLock guards the state of the reconciler below, which is
+	// read by CurrentConfig and by the handlers while reconciling.
+	configLock      sync.RWMutex
+	currentConfig   *config.Config
+	lastSync        time.Time
+	lastDryRun      PoolsDiff
+	lastObserved    PoolsDiff
+	failures        int
+	advertisedPools map[string]bool
 }
 
+// PoolsDiff describes how the pools change between two configurations.
+type PoolsDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// IsEmpty tells if no pools are changed.
+func (d PoolsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Results of the reconciliations of the pools, labeling poolReconcileDuration.
+const (
+	reconcileSuccess = "success"
+	reconcileError   = "error"
+)
+
 func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+	res, result, err := r.reconcile(ctx, req)
+	poolReconcileDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	return res, err
+}
+
+// reconcile renders and applies the configuration, returning the result of
+// the reconciliation along with the usual ones: the configuration not being
+// applied because of an error is an error, even when it is not retried.
+func (r *PoolReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, string, error) {
 	level.Info(r.Logger).Log("controller", "PoolReconciler", "start reconcile", req.NamespacedName.String())
 	defer level.Info(r.Logger).Log("controller", "PoolReconciler", "end reconcile", req.NamespacedName.String())
 	updates.Inc()
 
-	var addressPools metallbv1beta1.AddressPoolList
-	if err := r.List(ctx, &addressPools, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get addresspools", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var ipAddressPools metallbv1beta1.IPAddressPoolList
-	if err := r.List(ctx, &ipAddressPools, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get ipaddresspools", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var communities metallbv1beta1.CommunityList
-	if err := r.List(ctx, &communities, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get communities", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var namespaces corev1.NamespaceList
-	if err := r.List(ctx, &namespaces); err != nil {
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get namespaces", "error", err)
-		return ctrl.Result{}, err
+	listed, err := r.listResources(ctx)
+	if err != nil {
+		return ctrl.Result{}, reconcileError, err
 	}
+	addressPools, ipAddressPools, communities := listed.addressPools, listed.ipAddressPools, listed.communities
+	bgpAdvertisements, l2Advertisements, namespaces := listed.bgpAdvertisements, listed.l2Advertisements, listed.namespaces
+	legacyResources.WithLabelValues("pool").Set(float64(len(addressPools.Items)))
 
 	resources := config.ClusterResources{
 		Pools:              ipAddressPools.Items,
@@ -82,26 +145,139 @@ func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 	level.Debug(r.Logger).Log("controller", "PoolReconciler", "metallb CRs", dumpClusterResources(&resources))
 
+	// The advertisements are not part of the resources the pools are rendered
+	// from, they are only checked for the aliases they reference.
+	missingAliases := missingCommunityAliases(config.ClusterResources{
+		LegacyAddressPools: addressPools.Items,
+		BGPAdvs:            bgpAdvertisements.Items,
+		Communities:        communities.Items,
+	})
+	if len(missingAliases) > 0 {
+		missingCommunities.Set(1)
+		configStale.Set(1)
+		for _, referrer := range sortedKeys(missingAliases) {
+			level.Error(r.Logger).Log("controller", "PoolReconciler", "error", "community aliases not defined by any community resource", "referrer", referrer, "aliases", strings.Join(missingAliases[referrer], ","))
+		}
+		r.recordMissingCommunities(addressPools.Items, bgpAdvertisements.Items, missingAliases)
+		return ctrl.Result{}, reconcileError, errRetry
+	}
+	missingCommunities.Set(0)
+
+	warnings := []ConfigWarning{}
+	dropped := "AddressPool"
+	if r.LegacyPrecedence {
+		dropped = "IPAddressPool"
+	}
+	for _, pool := range config.DropConflictingLegacyPools(&resources, r.LegacyPrecedence) {
+		level.Warn(r.Logger).Log("controller", "PoolReconciler", "warning", "pool defined both as IPAddressPool and AddressPool, ignoring the "+dropped, "pool", pool)
+		warnings = append(warnings, ConfigWarning{
+			Category: WarningConflictingPool,
+			Message:  fmt.Sprintf("pool %s is defined both as IPAddressPool and AddressPool, the %s is ignored", pool, dropped),
+		})
+	}
+	missingNamespaces := config.MissingNamespaces(resources)
+	for _, pool := range sortedKeys(missingNamespaces) {
+		missing := strings.Join(missingNamespaces[pool], ",")
+		level.Warn(r.Logger).Log("controller", "PoolReconciler", "warning", "pool references non existing namespaces", "pool", pool, "namespaces", missing)
+		warnings = append(warnings, ConfigWarning{
+			Category: WarningMissingNamespace,
+			Message:  fmt.Sprintf("pool %s references non existing namespaces %s", pool, missing),
+		})
+	}
+	missingPools := config.MissingPools(config.ClusterResources{
+		Pools:   ipAddressPools.Items,
+		BGPAdvs: bgpAdvertisements.Items,
+		L2Advs:  l2Advertisements.Items,
+	})
+	orphanedAdvertisements.Set(float64(len(missingPools)))
+	for _, adv := range sortedKeys(missingPools) {
+		missing := strings.Join(missingPools[adv], ",")
+		level.Warn(r.Logger).Log("controller", "PoolReconciler", "warning", "advertisement references non existing pools", "advertisement", adv, "pools", missing)
+		warnings = append(warnings, ConfigWarning{
+			Category: WarningMissingPool,
+			Message:  fmt.Sprintf("%s references non existing pools %s", adv, missing),
+		})
+	}
+	r.recordMissingPools(bgpAdvertisements.Items, l2Advertisements.Items, missingPools)
+	// The warnings are exported on any return from here, as the ones about
+	// the pools without advertisements are added once the config is parsed.
+	defer func() {
+		if err := exportWarnings(ctx, r.Client, r.Namespace, warnings); err != nil {
+			level.Error(r.Logger).Log("controller", "PoolReconciler", "error", "failed to export the configuration warnings", "error", err)
+		}
+	}()
+
 	cfg, err := toConfig(resources, r.ValidateConfig)
 	if err != nil {
 		configStale.Set(1)
 		level.Error(r.Logger).Log("controller", "PoolReconciler", "error", "failed to parse the configuration", "error", err)
-		return ctrl.Result{}, nil
+		if syncStateForError(err) == SyncStateError {
+			return ctrl.Result{}, reconcileError, errRetry
+		}
+		return ctrl.Result{}, reconcileError, nil
 	}
 
 	level.Debug(r.Logger).Log("controller", "PoolReconciler", "rendered config", dumpConfig(cfg))
-	if reflect.DeepEqual(r.currentConfig, cfg) {
+	if r.DryRun {
+		var current *config.Pools
+		if c := r.CurrentConfig(); c != nil {
+			current = c.Pools
+		}
+		diff := diffPools(current, cfg.Pools)
+		r.configLock.Lock()
+		r.lastDryRun = diff
+		r.configLock.Unlock()
+		level.Info(r.Logger).Log("controller", "PoolReconciler", "event", "dry run, not applying the configuration", "diff", dumpResource(diff))
+		return ctrl.Result{}, reconcileSuccess, nil
+	}
+
+	advertised, err := advertisedPools(ipAddressPools.Items, addressPools.Items, bgpAdvertisements.Items, l2Advertisements.Items)
+	if err != nil {
+		configStale.Set(1)
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "error", "failed to match the advertisements to the pools", "error", err)
+		return ctrl.Result{}, reconcileError, nil
+	}
+	withoutAdvertisements := notAdvertised(ipAddressPools.Items, advertised)
+	poolsWithoutAdvertisements.Set(float64(len(withoutAdvertisements)))
+	for _, pool := range withoutAdvertisements {
+		level.Warn(r.Logger).Log("controller", "PoolReconciler", "warning", "pool has no advertisements, its IPs are not announced", "pool", pool)
+		warnings = append(warnings, ConfigWarning{
+			Category: WarningPoolWithoutAdvertisements,
+			Message:  fmt.Sprintf("pool %s has no advertisements, its IPs are not announced", pool),
+		})
+	}
+	r.recordPoolsWithoutAdvertisements(ipAddressPools.Items, withoutAdvertisements)
+	if unadvertised := r.unadvertisedPoolsInUse(advertised); len(unadvertised) > 0 {
+		configStale.Set(1)
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "error", "configuration leaves pools in use without advertisements, rejecting it", "pools", strings.Join(unadvertised, ","))
+		r.recordUnadvertisedPools(ipAddressPools.Items, unadvertised)
+		return ctrl.Result{}, reconcileError, nil
+	}
+
+	if reflect.DeepEqual(r.CurrentConfig(), cfg) && !r.resyncDue() {
 		level.Debug(r.Logger).Log("controller", "PoolReconciler", "event", "configuration did not change, ignoring")
-		return ctrl.Result{}, nil
+		r.configLock.Lock()
+		r.advertisedPools = advertised
+		r.configLock.Unlock()
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, reconcileSuccess, nil
 	}
 
-	res := r.Handler(r.Logger, cfg.Pools)
+	handler := r.Handler
+	if r.ObserveOnly {
+		handler = r.observe
+	}
+	res := handler(r.Logger, cfg.Pools)
 	switch res {
 	case SyncStateError:
 		updateErrors.Inc()
 		configStale.Set(1)
-		level.Error(r.Logger).Log("controller", "PoolReconciler", "metallb CRs and Secrets", dumpClusterResources(&resources), "event", "reload failed, retry")
-		return ctrl.Result{}, errRetry
+		failures := r.recordFailure()
+		consecutiveFailures.Set(float64(failures))
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "metallb CRs and Secrets", dumpClusterResources(&resources), "event", "reload failed, retry", "failures", failures)
+		if r.RetryBaseDelay > 0 {
+			return ctrl.Result{RequeueAfter: backoffDelay(r.RetryBaseDelay, r.RetryMaxDelay, r.RetryJitter, failures)}, reconcileError, nil
+		}
+		return ctrl.Result{}, reconcileError, errRetry
 	case SyncStateReprocessAll:
 		level.Info(r.Logger).Log("controller", "PoolReconciler", "event", "force service reload")
 		r.ForceReload()
@@ -109,17 +285,287 @@ func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		updateErrors.Inc()
 		configStale.Set(1)
 		level.Error(r.Logger).Log("controller", "PoolReconciler", "metallb CRs and Secrets", dumpClusterResources(&resources), "event", "reload failed, no retry")
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, reconcileError, nil
 	}
 
-	r.currentConfig = cfg
+	r.recordSuccess(cfg, advertised)
+	consecutiveFailures.Set(0)
+	r.Rendered.set(cfg)
 
 	configLoaded.Set(1)
 	configStale.Set(0)
 	level.Info(r.Logger).Log("controller", "PoolReconciler", "event", "config reloaded")
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, reconcileSuccess, nil
+}
+
+// CurrentConfig returns the last configuration applied by the reconciler.
+// It is safe to call it while reconciling.
+func (r *PoolReconciler) CurrentConfig() *config.Config {
+	r.configLock.RLock()
+	defer r.configLock.RUnlock()
+	return r.currentConfig
+}
+
+// recordSuccess records the configuration accepted by the handler,
+// resetting the consecutive failures.
+func (r *PoolReconciler) recordSuccess(cfg *config.Config, advertised map[string]bool) {
+	r.configLock.Lock()
+	defer r.configLock.Unlock()
+	r.currentConfig = cfg
+	r.advertisedPools = advertised
+	r.lastSync = time.Now()
+	r.failures = 0
 }
 
+// recordFailure records a failure of the handler, returning the number
+// of consecutive failures.
+func (r *PoolReconciler) recordFailure() int {
+	r.configLock.Lock()
+	defer r.configLock.Unlock()
+	r.failures++
+	return r.failures
+}
+
+// observe is the handler used in observe only mode: it records how the pools
+// would change and reports success, without applying them. Since nothing is
+// applied, the services don't need to be reprocessed.
+func (r *PoolReconciler) observe(l log.Logger, pools *config.Pools) SyncState {
+	var current *config.Pools
+	if c := r.CurrentConfig(); c != nil {
+		current = c.Pools
+	}
+	diff := diffPools(current, pools)
+	r.configLock.Lock()
+	r.lastObserved = diff
+	r.configLock.Unlock()
+	level.Info(l).Log("controller", "PoolReconciler", "event", "observe only, not applying the configuration", "diff", dumpResource(diff))
+	return SyncStateSuccess
+}
+
+// diffPools returns the names of the pools added, removed and changed
+// going from the old pools to the new ones.
+func diffPools(old, new *config.Pools) PoolsDiff {
+	res := PoolsDiff{}
+	oldByName := map[string]*config.Pool{}
+	if old != nil {
+		oldByName = old.ByName
+	}
+	newByName := map[string]*config.Pool{}
+	if new != nil {
+		newByName = new.ByName
+	}
+	for name, p := range newByName {
+		oldPool, ok := oldByName[name]
+		if !ok {
+			res.Added = append(res.Added, name)
+			continue
+		}
+		if !reflect.DeepEqual(oldPool, p) {
+			res.Changed = append(res.Changed, name)
+		}
+	}
+	for name := range oldByName {
+		if _, ok := newByName[name]; !ok {
+			res.Removed = append(res.Removed, name)
+		}
+	}
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	sort.Strings(res.Changed)
+	return res
+}
+
+// advertisedPools returns the names of the pools selected by at least one
+// advertisement. Legacy address pools carry their own protocol and are
+// always considered advertised.
+func advertisedPools(pools []metallbv1beta1.IPAddressPool, legacyPools []metallbv1beta1.AddressPool,
+	bgpAdvs []metallbv1beta1.BGPAdvertisement, l2Advs []metallbv1beta1.L2Advertisement) (map[string]bool, error) {
+	res := map[string]bool{}
+	for _, p := range legacyPools {
+		res[p.Name] = true
+	}
+
+	addSelected := func(names []string, selectors []metav1.LabelSelector) error {
+		if len(names) == 0 && len(selectors) == 0 {
+			for _, p := range pools {
+				res[p.Name] = true
+			}
+			return nil
+		}
+		for _, n := range names {
+			res[n] = true
+		}
+		for _, sel := range selectors {
+			sel := sel // so we can use &sel
+			s, err := metav1.LabelSelectorAsSelector(&sel)
+			if err != nil {
+				return err
+			}
+			for _, p := range pools {
+				if s.Matches(labels.Set(p.Labels)) {
+					res[p.Name] = true
+				}
+			}
+		}
+		return nil
+	}
+	for _, adv := range bgpAdvs {
+		if err := addSelected(adv.Spec.IPAddressPools, adv.Spec.IPAddressPoolSelectors); err != nil {
+			return nil, err
+		}
+	}
+	for _, adv := range l2Advs {
+		if err := addSelected(adv.Spec.IPAddressPools, adv.Spec.IPAddressPoolSelectors); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// notAdvertised returns the names of the given pools not selected by any
+// advertisement, sorted by name. Their IPs are allocated but never announced.
+func notAdvertised(pools []metallbv1beta1.IPAddressPool, advertised map[string]bool) []string {
+	res := []string{}
+	for _, p := range pools {
+		if !advertised[p.Name] {
+			res = append(res, p.Name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// unadvertisedPoolsInUse returns the pools backing services that were advertised
+// by the last applied configuration and are not advertised anymore.
+func (r *PoolReconciler) unadvertisedPoolsInUse(advertised map[string]bool) []string {
+	r.configLock.RLock()
+	previous := r.advertisedPools
+	r.configLock.RUnlock()
+	if r.PoolsInUse == nil || previous == nil {
+		return nil
+	}
+	res := []string{}
+	for _, pool := range r.PoolsInUse() {
+		if previous[pool] && !advertised[pool] {
+			res = append(res, pool)
+		}
+	}
+	return res
+}
+
+func (r *PoolReconciler) recordUnadvertisedPools(pools []metallbv1beta1.IPAddressPool, unadvertised []string) {
+	if r.Recorder == nil {
+		return
+	}
+	for _, name := range unadvertised {
+		for i := range pools {
+			if pools[i].Name != name {
+				continue
+			}
+			r.Recorder.Eventf(&pools[i], corev1.EventTypeWarning, "AdvertisementRemovalRejected",
+				"pool %s is in use and the configuration leaves it without advertisements", name)
+		}
+	}
+}
+
+func (r *PoolReconciler) recordPoolsWithoutAdvertisements(pools []metallbv1beta1.IPAddressPool, withoutAdvertisements []string) {
+	if r.Recorder == nil {
+		return
+	}
+	for _, name := range withoutAdvertisements {
+		for i := range pools {
+			if pools[i].Name != name {
+				continue
+			}
+			r.Recorder.Eventf(&pools[i], corev1.EventTypeWarning, WarningPoolWithoutAdvertisements,
+				"pool has no advertisements, its IPs are not announced")
+		}
+	}
+}
+
+func (r *PoolReconciler) recordMissingPools(bgpAdvs []metallbv1beta1.BGPAdvertisement, l2Advs []metallbv1beta1.L2Advertisement, missingPools map[string][]string) {
+	if r.Recorder == nil {
+		return
+	}
+	for i := range bgpAdvs {
+		if missing, ok := missingPools["BGPAdvertisement/"+bgpAdvs[i].Name]; ok {
+			r.Recorder.Eventf(&bgpAdvs[i], corev1.EventTypeWarning, WarningMissingPool,
+				"advertisement references non existing pools %s", strings.Join(missing, ","))
+		}
+	}
+	for i := range l2Advs {
+		if missing, ok := missingPools["L2Advertisement/"+l2Advs[i].Name]; ok {
+			r.Recorder.Eventf(&l2Advs[i], corev1.EventTypeWarning, WarningMissingPool,
+				"advertisement references non existing pools %s", strings.Join(missing, ","))
+		}
+	}
+}
+
+func (r *PoolReconciler) recordMissingCommunities(legacyPools []metallbv1beta1.AddressPool, bgpAdvs []metallbv1beta1.BGPAdvertisement, missingAliases map[string][]string) {
+	if r.Recorder == nil {
+		return
+	}
+	for i := range legacyPools {
+		if missing, ok := missingAliases["AddressPool/"+legacyPools[i].Name]; ok {
+			r.Recorder.Eventf(&legacyPools[i], corev1.EventTypeWarning, WarningMissingCommunity,
+				"advertisements reference community aliases not defined by any community resource %s", strings.Join(missing, ","))
+		}
+	}
+	for i := range bgpAdvs {
+		if missing, ok := missingAliases["BGPAdvertisement/"+bgpAdvs[i].Name]; ok {
+			r.Recorder.Eventf(&bgpAdvs[i], corev1.EventTypeWarning, WarningMissingCommunity,
+				"advertisement references community aliases not defined by any community resource %s", strings.Join(missing, ","))
+		}
+	}
+}
+
+// resyncDue tells if the resync period elapsed since the configuration
+// was last pushed to the handler.
+func (r *PoolReconciler) resyncDue() bool {
+	if r.ResyncPeriod == 0 {
+		return false
+	}
+	r.configLock.RLock()
+	defer r.configLock.RUnlock()
+	return time.Since(r.lastSync) >= r.ResyncPeriod
+}
+
+// missingCommunityAliases returns the communities referenced by the advertisements
+// that are not community values, and so must be aliases, but are not defined by
+// any community resource. They are keyed by the kind and the name of the object
+// referencing them, e.g. BGPAdvertisement/adv1.
+func missingCommunityAliases(resources config.ClusterResources) map[string][]string {
+	defined := map[string]bool{}
+	for _, c := range resources.Communities {
+		for _, alias := range c.Spec.Communities {
+			defined[alias.Name] = true
+		}
+	}
+	res := map[string][]string{}
+	addMissing := func(referrer string, communities []string) {
+		for _, c := range communities {
+			_, err := community.New(c)
+			if errors.Is(err, community.ErrInvalidCommunityFormat) && !defined[c] {
+				res[referrer] = append(res[referrer], c)
+			}
+		}
+	}
+	for _, p := range resources.LegacyAddressPools {
+		for _, adv := range p.Spec.BGPAdvertisements {
+			addMissing("AddressPool/"+p.Name, adv.Communities)
+		}
+	}
+	for _, adv := range resources.BGPAdvs {
+		addMissing("BGPAdvertisement/"+adv.Name, adv.Spec.Communities)
+	}
+	return res
+}
+
+// ipAddressPoolChanged filters out the updates of the pools changing neither
+// their spec nor their labels, which the advertisements select the pools by,
+// e.g. the status only ones, as they don't affect the configuration.
+var ipAddressPoolChanged = predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})
+
 func (r *PoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	p := predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
@@ -127,10 +573,100 @@ func (r *PoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		},
 	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&metallbv1beta1.IPAddressPool{}).
+		For(&metallbv1beta1.IPAddressPool{}, builder.WithPredicates(ipAddressPoolChanged)).
 		Watches(&metallbv1beta1.AddressPool{}, &handler.EnqueueRequestForObject{}).
 		Watches(&metallbv1beta1.Community{}, &handler.EnqueueRequestForObject{}).
-		Watches(&corev1.Namespace{}, &handler.EnqueueRequestForObject{}).
+		Watches(&metallbv1beta1.BGPAdvertisement{}, &handler.EnqueueRequestForObject{}).
+		Watches(&metallbv1beta1.L2Advertisement{}, &handler.EnqueueRequestForObject{}).
+		Watches(&corev1.Namespace{}, &handler.EnqueueRequestForObject{},
+			builder.WithPredicates(predicate.NewPredicateFuncs(r.watchesNamespace))).
 		WithEventFilter(p).
 		Complete(r)
 }
+
+// poolResources are the resources listed by the PoolReconciler.
+type poolResources struct {
+	addressPools      metallbv1beta1.AddressPoolList
+	ipAddressPools    metallbv1beta1.IPAddressPoolList
+	communities       metallbv1beta1.CommunityList
+	bgpAdvertisements metallbv1beta1.BGPAdvertisementList
+	l2Advertisements  metallbv1beta1.L2AdvertisementList
+	namespaces        corev1.NamespaceList
+}
+
+// listResources lists the resources needed to render the configuration.
+// All the kinds are listed at each reconcile: the client of the manager reads
+// them from the informers cache, so listing only the changed kind would save
+// no API calls while making the result depend on the previous requests.
+func (r *PoolReconciler) listResources(ctx context.Context) (*poolResources, error) {
+	res := poolResources{}
+	if err := r.List(ctx, &res.addressPools, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get addresspools", "error", err)
+		return nil, err
+	}
+
+	if err := r.List(ctx, &res.ipAddressPools, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get ipaddresspools", "error", err)
+		return nil, err
+	}
+
+	if err := r.List(ctx, &res.communities, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get communities", "error", err)
+		return nil, err
+	}
+
+	if err := r.List(ctx, &res.bgpAdvertisements, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get bgpadvertisements", "error", err)
+		return nil, err
+	}
+
+	if err := r.List(ctx, &res.l2Advertisements, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get l2advertisements", "error", err)
+		return nil, err
+	}
+
+	namespaces, err := r.listNamespaces(ctx)
+	if err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get namespaces", "error", err)
+		return nil, err
+	}
+	res.namespaces = namespaces
+
+	return &res, nil
+}
+
+// listNamespaces returns the namespaces the reconciler watches. When WatchNamespaces
+// is set, only those are fetched, skipping the ones not existing, instead of listing
+// all the namespaces of the cluster.
+func (r *PoolReconciler) listNamespaces(ctx context.Context) (corev1.NamespaceList, error) {
+	res := corev1.NamespaceList{}
+	if len(r.WatchNamespaces) == 0 {
+		err := r.List(ctx, &res)
+		return res, err
+	}
+	for _, name := range r.WatchNamespaces {
+		var ns corev1.Namespace
+		err := r.Get(ctx, client.ObjectKey{Name: name}, &ns)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return corev1.NamespaceList{}, err
+		}
+		res.Items = append(res.Items, ns)
+	}
+	return res, nil
+}
+
+// watchesNamespace tells if the given namespace is one of the watched ones.
+func (r *PoolReconciler) watchesNamespace(obj client.Object) bool {
+	if len(r.WatchNamespaces) == 0 {
+		return true
+	}
+	for _, ns := range r.WatchNamespaces {
+		if obj.GetName() == ns {
+			return true
+		}
+	}
+	return false
+}
@@ -18,16 +18,29 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	v1beta1 "go.universe.tf/metallb/api/v1beta1"
 	metallbcfg "go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/pointer"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -36,6 +49,7 @@ func TestPoolController(t *testing.T) {
 		desc                    string
 		handlerRes              SyncState
 		validResources          bool
+		transientError          bool
 		expectReconcileFails    bool
 		expectForceReloadCalled bool
 	}{
@@ -74,11 +88,21 @@ func TestPoolController(t *testing.T) {
 			expectReconcileFails:    false,
 			expectForceReloadCalled: false,
 		},
+		{
+			desc:                    "handler returns SyncStateSuccess, resources referencing a missing community",
+			handlerRes:              SyncStateSuccess,
+			validResources:          false,
+			transientError:          true,
+			expectReconcileFails:    true,
+			expectForceReloadCalled: false,
+		},
 	}
 	for _, test := range tests {
 		var resources metallbcfg.ClusterResources
 		if test.validResources {
 			resources = poolControllerValidResources
+		} else if test.transientError {
+			resources = poolControllerTransientErrorResources
 		} else {
 			resources = poolControllerInvalidResources
 		}
@@ -188,6 +212,10 @@ var (
 		},
 	}
 
+	poolControllerTransientErrorResources = metallbcfg.ClusterResources{
+		LegacyAddressPools: poolControllerValidResources.LegacyAddressPools,
+	}
+
 	poolControllerInvalidResources = metallbcfg.ClusterResources{
 		Pools: []v1beta1.IPAddressPool{
 			{
@@ -199,3 +227,1066 @@ var (
 		},
 	}
 )
+
+func TestPoolControllerMissingCommunities(t *testing.T) {
+	tests := []struct {
+		desc                 string
+		resources            metallbcfg.ClusterResources
+		expectReconcileFails bool
+		expectMetric         float64
+		expectedEvents       []string
+	}{
+		{
+			desc:                 "aliases referenced, community resource present",
+			resources:            poolControllerValidResources,
+			expectReconcileFails: false,
+			expectMetric:         0,
+		},
+		{
+			desc:                 "aliases referenced, community resource missing",
+			resources:            poolControllerTransientErrorResources,
+			expectReconcileFails: true,
+			expectMetric:         1,
+			expectedEvents: []string{
+				"Warning MissingCommunity advertisements reference community aliases not defined by any community resource bar",
+			},
+		},
+		{
+			desc: "advertisement referencing an alias not defined by the community resource",
+			resources: metallbcfg.ClusterResources{
+				Pools:       poolControllerValidResources.Pools,
+				Communities: poolControllerValidResources.Communities,
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name:      "adv1",
+							Namespace: testNamespace,
+						},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities: []string{"bar", "foo", "1234:1"},
+						},
+					},
+				},
+			},
+			expectReconcileFails: true,
+			expectMetric:         1,
+			expectedEvents: []string{
+				"Warning MissingCommunity advertisement references community aliases not defined by any community resource foo",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			fakeClient, err := newFakeClient(objectsFromResources(test.resources))
+			if err != nil {
+				t.Fatalf("failed to create fake client: %v", err)
+			}
+
+			handlerCalled := false
+			recorder := record.NewFakeRecorder(10)
+			r := &PoolReconciler{
+				Client:         fakeClient,
+				Logger:         log.NewNopLogger(),
+				Scheme:         scheme,
+				Namespace:      testNamespace,
+				ValidateConfig: metallbcfg.DontValidate,
+				Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+					handlerCalled = true
+					return SyncStateSuccess
+				},
+				ForceReload: func() {},
+				Recorder:    recorder,
+			}
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: testNamespace,
+				},
+			}
+
+			_, err = r.Reconcile(context.TODO(), req)
+			if test.expectReconcileFails != (err != nil) {
+				t.Fatalf("fail reconcile expected: %v, got: %v", test.expectReconcileFails, err)
+			}
+			if test.expectReconcileFails && handlerCalled {
+				t.Fatalf("handler was called despite the missing community resource")
+			}
+			if metric := testutil.ToFloat64(missingCommunities); metric != test.expectMetric {
+				t.Fatalf("expected missing communities metric %v, got %v", test.expectMetric, metric)
+			}
+			events := []string{}
+			for len(recorder.Events) > 0 {
+				if e := <-recorder.Events; strings.Contains(e, WarningMissingCommunity) {
+					events = append(events, e)
+				}
+			}
+			if !cmp.Equal(test.expectedEvents, events, cmpopts.EquateEmpty()) {
+				t.Fatalf("unexpected events (-want +got)\n%s", cmp.Diff(test.expectedEvents, events, cmpopts.EquateEmpty()))
+			}
+		})
+	}
+}
+
+func TestPoolControllerReconcileDuration(t *testing.T) {
+	tests := []struct {
+		desc           string
+		resources      metallbcfg.ClusterResources
+		handlerRes     SyncState
+		expectedResult string
+	}{
+		{
+			desc:           "successful reconcile",
+			resources:      poolControllerValidResources,
+			handlerRes:     SyncStateSuccess,
+			expectedResult: "success",
+		},
+		{
+			desc:           "failed reconcile",
+			resources:      poolControllerTransientErrorResources,
+			handlerRes:     SyncStateSuccess,
+			expectedResult: "error",
+		},
+		{
+			desc:           "failed parse",
+			resources:      poolControllerInvalidResources,
+			handlerRes:     SyncStateSuccess,
+			expectedResult: "error",
+		},
+		{
+			desc:           "handler failure without retry",
+			resources:      poolControllerValidResources,
+			handlerRes:     SyncStateErrorNoRetry,
+			expectedResult: "error",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			fakeClient, err := newFakeClient(objectsFromResources(test.resources))
+			if err != nil {
+				t.Fatalf("failed to create fake client: %v", err)
+			}
+
+			r := &PoolReconciler{
+				Client:         fakeClient,
+				Logger:         log.NewNopLogger(),
+				Scheme:         scheme,
+				Namespace:      testNamespace,
+				ValidateConfig: metallbcfg.DontValidate,
+				Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+					return test.handlerRes
+				},
+				ForceReload: func() {},
+			}
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: testNamespace,
+				},
+			}
+
+			before := reconcileDurationSamples(t, test.expectedResult)
+			_, _ = r.Reconcile(context.TODO(), req)
+			after := reconcileDurationSamples(t, test.expectedResult)
+			if after <= before {
+				t.Fatalf("expected the %s reconcile duration to be observed, got %d samples before and %d after", test.expectedResult, before, after)
+			}
+		})
+	}
+}
+
+func reconcileDurationSamples(t *testing.T, result string) uint64 {
+	t.Helper()
+	histogram, ok := poolReconcileDuration.WithLabelValues(result).(prometheus.Histogram)
+	if !ok {
+		t.Fatalf("unexpected reconcile duration metric type")
+	}
+	m := &dto.Metric{}
+	if err := histogram.Write(m); err != nil {
+		t.Fatalf("failed to read the reconcile duration metric: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestPoolControllerResync(t *testing.T) {
+	tests := []struct {
+		desc                string
+		resyncPeriod        time.Duration
+		expectedRequeue     time.Duration
+		expectedHandlerRuns int
+	}{
+		{
+			desc:                "resync disabled",
+			resyncPeriod:        0,
+			expectedRequeue:     0,
+			expectedHandlerRuns: 1,
+		},
+		{
+			desc:                "resync enabled",
+			resyncPeriod:        time.Nanosecond,
+			expectedRequeue:     time.Nanosecond,
+			expectedHandlerRuns: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			fakeClient, err := newFakeClient(objectsFromResources(poolControllerValidResources))
+			if err != nil {
+				t.Fatalf("failed to create fake client: %v", err)
+			}
+
+			handlerRuns := 0
+			r := &PoolReconciler{
+				Client:         fakeClient,
+				Logger:         log.NewNopLogger(),
+				Scheme:         scheme,
+				Namespace:      testNamespace,
+				ValidateConfig: metallbcfg.DontValidate,
+				Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+					handlerRuns++
+					return SyncStateSuccess
+				},
+				ForceReload:  func() {},
+				ResyncPeriod: test.resyncPeriod,
+			}
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: testNamespace,
+				},
+			}
+
+			// The second reconcile happens with the same configuration, and must call
+			// the handler only if the resync period elapsed.
+			for i := 0; i < 2; i++ {
+				res, err := r.Reconcile(context.TODO(), req)
+				if err != nil {
+					t.Fatalf("unexpected reconcile error: %v", err)
+				}
+				if res.RequeueAfter != test.expectedRequeue {
+					t.Fatalf("expected requeue after %s, got %s", test.expectedRequeue, res.RequeueAfter)
+				}
+			}
+			if handlerRuns != test.expectedHandlerRuns {
+				t.Fatalf("expected handler to run %d times, got %d", test.expectedHandlerRuns, handlerRuns)
+			}
+		})
+	}
+}
+
+func TestPoolControllerDryRun(t *testing.T) {
+	current, err := metallbcfg.For(metallbcfg.ClusterResources{
+		Pools: []v1beta1.IPAddressPool{
+			{
+				ObjectMeta: v1.ObjectMeta{Name: "pool1", Namespace: testNamespace},
+				Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.30.0.0/16"}},
+			},
+			{
+				ObjectMeta: v1.ObjectMeta{Name: "pool2", Namespace: testNamespace},
+				Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.40.0.0/16"}},
+			},
+		},
+	}, metallbcfg.DontValidate)
+	if err != nil {
+		t.Fatalf("failed to create the current config: %v", err)
+	}
+
+	fakeClient, err := newFakeClient(objectsFromResources(poolControllerValidResources))
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: metallbcfg.DontValidate,
+		Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+			t.Fatalf("handler called in dry run mode")
+			return SyncStateSuccess
+		},
+		ForceReload:   func() { t.Fatalf("force reload called in dry run mode") },
+		DryRun:        true,
+		currentConfig: current,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if r.CurrentConfig() != current {
+		t.Fatalf("current config updated in dry run mode")
+	}
+
+	expected := PoolsDiff{
+		Added:   []string{"legacypool1"},
+		Removed: []string{"pool2"},
+		Changed: []string{"pool1"},
+	}
+	if !cmp.Equal(expected, r.lastDryRun) {
+		t.Fatalf("unexpected diff (-want +got)\n%s", cmp.Diff(expected, r.lastDryRun))
+	}
+}
+
+func TestPoolControllerObserveOnly(t *testing.T) {
+	fakeClient, err := newFakeClient(objectsFromResources(poolControllerValidResources))
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: metallbcfg.DontValidate,
+		Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+			t.Fatalf("handler called in observe only mode")
+			return SyncStateSuccess
+		},
+		ForceReload: func() { t.Fatalf("force reload called in observe only mode") },
+		ObserveOnly: true,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if r.CurrentConfig() == nil {
+		t.Fatalf("current config not updated in observe only mode")
+	}
+	expected := PoolsDiff{Added: []string{"legacypool1", "pool1"}}
+	if !cmp.Equal(expected, r.lastObserved) {
+		t.Fatalf("unexpected diff (-want +got)\n%s", cmp.Diff(expected, r.lastObserved))
+	}
+
+	first := r.CurrentConfig()
+	err = fakeClient.Delete(context.TODO(), &v1beta1.IPAddressPool{
+		ObjectMeta: v1.ObjectMeta{Name: "pool1", Namespace: testNamespace},
+	})
+	if err != nil {
+		t.Fatalf("failed to delete pool1: %v", err)
+	}
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if r.CurrentConfig() == first {
+		t.Fatalf("current config not advanced in observe only mode")
+	}
+	expected = PoolsDiff{Removed: []string{"pool1"}}
+	if !cmp.Equal(expected, r.lastObserved) {
+		t.Fatalf("unexpected diff (-want +got)\n%s", cmp.Diff(expected, r.lastObserved))
+	}
+}
+
+func TestPoolControllerRetryBackoff(t *testing.T) {
+	fakeClient, err := newFakeClient(objectsFromResources(poolControllerValidResources))
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	handlerRes := SyncStateError
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: metallbcfg.DontValidate,
+		Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+			return handlerRes
+		},
+		ForceReload:    func() {},
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  5 * time.Second,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		res, err := r.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("unexpected reconcile error: %v", err)
+		}
+		if res.RequeueAfter != expected {
+			t.Fatalf("expected requeue after %s, got %s", expected, res.RequeueAfter)
+		}
+	}
+	if r.failures != 5 {
+		t.Fatalf("expected 5 consecutive failures, got %d", r.failures)
+	}
+
+	handlerRes = SyncStateSuccess
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if r.failures != 0 {
+		t.Fatalf("expected the failures to be reset after a success, got %d", r.failures)
+	}
+
+	// the handler is not called again for an unchanged configuration.
+	r.currentConfig = nil
+	handlerRes = SyncStateError
+	res, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if res.RequeueAfter != time.Second {
+		t.Fatalf("expected the backoff to restart from the base delay, got %s", res.RequeueAfter)
+	}
+}
+
+func TestPoolControllerObservedConfig(t *testing.T) {
+	fakeClient, err := newFakeClient(objectsFromResources(poolControllerValidResources))
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	rendered := &RenderedConfig{}
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: metallbcfg.DontValidate,
+		Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+		Rendered:    rendered,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	rendered.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/config?format=json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	var observed observedConfig
+	if err := json.Unmarshal(rec.Body.Bytes(), &observed); err != nil {
+		t.Fatalf("failed to decode the observed config: %v", err)
+	}
+	names := []string{}
+	for _, p := range observed.Pools {
+		names = append(names, p.Name)
+	}
+	if !cmp.Equal([]string{"legacypool1", "pool1"}, names) {
+		t.Fatalf("unexpected pools %v", names)
+	}
+}
+
+func TestPoolControllerConcurrentConfigAccess(t *testing.T) {
+	fakeClient, err := newFakeClient(objectsFromResources(poolControllerValidResources))
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: metallbcfg.DontValidate,
+		Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+		// a resync always due makes each reconcile apply the configuration again.
+		ResyncPeriod: time.Nanosecond,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+
+	done := make(chan struct{})
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				if cfg := r.CurrentConfig(); cfg != nil {
+					_ = len(cfg.Pools.ByName)
+				}
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("unexpected reconcile error: %v", err)
+		}
+	}
+	close(done)
+	<-readerDone
+
+	if cfg := r.CurrentConfig(); cfg == nil || len(cfg.Pools.ByName) != 2 {
+		t.Fatalf("unexpected current config %v", cfg)
+	}
+}
+
+func TestPoolControllerWarnings(t *testing.T) {
+	pool := v1beta1.IPAddressPool{
+		ObjectMeta: v1.ObjectMeta{Name: "pool1", Namespace: testNamespace},
+		Spec: v1beta1.IPAddressPoolSpec{
+			Addresses:  []string{"10.20.0.0/16"},
+			AllocateTo: &v1beta1.ServiceAllocation{Namespaces: []string{"tenant"}},
+		},
+	}
+	l2Adv := &v1beta1.L2Advertisement{ObjectMeta: v1.ObjectMeta{Name: "l2adv", Namespace: testNamespace}}
+	metallbNamespace := &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: testNamespace}}
+
+	fakeClient, err := newFakeClient([]client.Object{pool.DeepCopy(), l2Adv, metallbNamespace})
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: metallbcfg.DontValidate,
+		Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+
+	warnings := func() []ConfigWarning {
+		var ns corev1.Namespace
+		err := fakeClient.Get(context.TODO(), client.ObjectKey{Name: testNamespace}, &ns)
+		if err != nil {
+			t.Fatalf("failed to get namespace: %v", err)
+		}
+		raw, ok := ns.Annotations[configWarningsAnnotation]
+		if !ok {
+			return nil
+		}
+		res := []ConfigWarning{}
+		if err := json.Unmarshal([]byte(raw), &res); err != nil {
+			t.Fatalf("failed to unmarshal warnings %s: %v", raw, err)
+		}
+		return res
+	}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	raised := warnings()
+	if len(raised) != 1 || raised[0].Category != WarningMissingNamespace {
+		t.Fatalf("expected a missing namespace warning, got %v", raised)
+	}
+
+	// A warning still present keeps its timestamp.
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if stillRaised := warnings(); !cmp.Equal(raised, stillRaised) {
+		t.Fatalf("unexpected warnings (-want +got)\n%s", cmp.Diff(raised, stillRaised))
+	}
+
+	// Creating the namespace resolves the warning.
+	err = fakeClient.Create(context.TODO(), &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "tenant"}})
+	if err != nil {
+		t.Fatalf("failed to create namespace: %v", err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if resolved := warnings(); resolved != nil {
+		t.Fatalf("expected warnings to be cleared, got %v", resolved)
+	}
+}
+
+func TestPoolControllerAdvertisementRemovalGuard(t *testing.T) {
+	// Besides the rejection, pool1 left without advertisements is always
+	// reported by a PoolWithoutAdvertisements event.
+	tests := []struct {
+		desc                string
+		poolsInUse          []string
+		expectedHandlerRuns int
+		expectedEvents      int
+	}{
+		{
+			desc:                "pool in use left without advertisements",
+			poolsInUse:          []string{"pool1"},
+			expectedHandlerRuns: 1,
+			expectedEvents:      2,
+		},
+		{
+			desc:                "pool not in use left without advertisements",
+			poolsInUse:          []string{"pool2"},
+			expectedHandlerRuns: 2,
+			expectedEvents:      1,
+		},
+		{
+			desc:                "no pools in use",
+			poolsInUse:          []string{},
+			expectedHandlerRuns: 2,
+			expectedEvents:      1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			l2Adv := &v1beta1.L2Advertisement{
+				ObjectMeta: v1.ObjectMeta{Name: "l2adv", Namespace: testNamespace},
+				Spec:       v1beta1.L2AdvertisementSpec{IPAddressPools: []string{"pool1"}},
+			}
+			resources := metallbcfg.ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1", Namespace: testNamespace},
+						Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.30.0.0/16"}},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool2", Namespace: testNamespace, Labels: map[string]string{"bgp": "true"}},
+						Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.40.0.0/16"}},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "bgpadv", Namespace: testNamespace},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPoolSelectors: []v1.LabelSelector{{MatchLabels: map[string]string{"bgp": "true"}}},
+						},
+					},
+				},
+				L2Advs: []v1beta1.L2Advertisement{*l2Adv},
+			}
+			fakeClient, err := newFakeClient(objectsFromResources(resources))
+			if err != nil {
+				t.Fatalf("failed to create fake client: %v", err)
+			}
+
+			handlerRuns := 0
+			recorder := record.NewFakeRecorder(10)
+			r := &PoolReconciler{
+				Client:         fakeClient,
+				Logger:         log.NewNopLogger(),
+				Scheme:         scheme,
+				Namespace:      testNamespace,
+				ValidateConfig: metallbcfg.DontValidate,
+				Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+					handlerRuns++
+					return SyncStateSuccess
+				},
+				ForceReload: func() {},
+				PoolsInUse:  func() []string { return test.poolsInUse },
+				Recorder:    recorder,
+			}
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: testNamespace,
+				},
+			}
+
+			_, err = r.Reconcile(context.TODO(), req)
+			if err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+
+			// Removing the only advertisement of pool1 together with a change of pool2,
+			// so that the handler runs if the configuration is accepted.
+			err = fakeClient.Delete(context.TODO(), l2Adv)
+			if err != nil {
+				t.Fatalf("failed to delete the l2 advertisement: %v", err)
+			}
+			pool2 := &v1beta1.IPAddressPool{}
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Name: "pool2", Namespace: testNamespace}, pool2)
+			if err != nil {
+				t.Fatalf("failed to get pool2: %v", err)
+			}
+			pool2.Spec.Addresses = append(pool2.Spec.Addresses, "10.50.0.0/16")
+			err = fakeClient.Update(context.TODO(), pool2)
+			if err != nil {
+				t.Fatalf("failed to update pool2: %v", err)
+			}
+
+			_, err = r.Reconcile(context.TODO(), req)
+			if err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+			if handlerRuns != test.expectedHandlerRuns {
+				t.Fatalf("expected handler to run %d times, got %d", test.expectedHandlerRuns, handlerRuns)
+			}
+			if len(recorder.Events) != test.expectedEvents {
+				t.Fatalf("expected %d events, got %d", test.expectedEvents, len(recorder.Events))
+			}
+		})
+	}
+}
+
+func TestPoolControllerLegacyResources(t *testing.T) {
+	fakeClient, err := newFakeClient(objectsFromResources(poolControllerValidResources))
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: metallbcfg.DontValidate,
+		Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	expected := len(poolControllerValidResources.LegacyAddressPools)
+	if metric := testutil.ToFloat64(legacyResources.WithLabelValues("pool")); metric != float64(expected) {
+		t.Fatalf("expected %d legacy pools, got %v", expected, metric)
+	}
+
+	// removing the legacy pools drops the gauge to zero.
+	for _, p := range poolControllerValidResources.LegacyAddressPools {
+		pool := &v1beta1.AddressPool{ObjectMeta: v1.ObjectMeta{Name: p.Name, Namespace: p.Namespace}}
+		if err := fakeClient.Delete(context.TODO(), pool); err != nil {
+			t.Fatalf("failed to delete the legacy pool: %v", err)
+		}
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if metric := testutil.ToFloat64(legacyResources.WithLabelValues("pool")); metric != 0 {
+		t.Fatalf("expected no legacy pools, got %v", metric)
+	}
+}
+
+func TestPoolControllerMissingPools(t *testing.T) {
+	objects := objectsFromResources(poolControllerValidResources)
+	objects = append(objects,
+		&v1beta1.BGPAdvertisement{
+			ObjectMeta: v1.ObjectMeta{Name: "bgp-orphan", Namespace: testNamespace},
+			Spec:       v1beta1.BGPAdvertisementSpec{IPAddressPools: []string{"pool1", "deleted"}},
+		},
+		&v1beta1.L2Advertisement{
+			ObjectMeta: v1.ObjectMeta{Name: "l2-orphan", Namespace: testNamespace},
+			Spec:       v1beta1.L2AdvertisementSpec{IPAddressPools: []string{"deleted"}},
+		},
+		&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: testNamespace}},
+	)
+	fakeClient, err := newFakeClient(objects)
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: metallbcfg.DontValidate,
+		Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+		Recorder:    recorder,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+
+	if metric := testutil.ToFloat64(orphanedAdvertisements); metric != 2 {
+		t.Fatalf("expected 2 orphaned advertisements, got %v", metric)
+	}
+	if len(recorder.Events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(recorder.Events))
+	}
+	for i := 0; i < 2; i++ {
+		if event := <-recorder.Events; !strings.Contains(event, WarningMissingPool) || !strings.Contains(event, "deleted") {
+			t.Fatalf("unexpected event %q", event)
+		}
+	}
+
+	var ns corev1.Namespace
+	if err := fakeClient.Get(context.TODO(), client.ObjectKey{Name: testNamespace}, &ns); err != nil {
+		t.Fatalf("failed to get namespace: %v", err)
+	}
+	warnings := []ConfigWarning{}
+	if err := json.Unmarshal([]byte(ns.Annotations[configWarningsAnnotation]), &warnings); err != nil {
+		t.Fatalf("failed to unmarshal warnings: %v", err)
+	}
+	messages := []string{}
+	for _, w := range warnings {
+		if w.Category == WarningMissingPool {
+			messages = append(messages, w.Message)
+		}
+	}
+	expected := []string{
+		"BGPAdvertisement/bgp-orphan references non existing pools deleted",
+		"L2Advertisement/l2-orphan references non existing pools deleted",
+	}
+	if !cmp.Equal(expected, messages) {
+		t.Fatalf("unexpected warnings (-want +got)\n%s", cmp.Diff(expected, messages))
+	}
+}
+
+func TestPoolControllerWatchNamespaces(t *testing.T) {
+	pool := &v1beta1.IPAddressPool{
+		ObjectMeta: v1.ObjectMeta{Name: "pool1", Namespace: testNamespace},
+		Spec: v1beta1.IPAddressPoolSpec{
+			Addresses: []string{"10.20.0.0/16"},
+			AllocateTo: &v1beta1.ServiceAllocation{
+				NamespaceSelectors: []v1.LabelSelector{{MatchLabels: map[string]string{"team": "a"}}},
+			},
+		},
+	}
+	namespaces := []string{"ns1", "ns2", "ns3"}
+
+	tests := []struct {
+		desc            string
+		watchNamespaces []string
+		expected        []string
+	}{
+		{
+			desc:     "all namespaces",
+			expected: []string{"ns1", "ns2", "ns3"},
+		},
+		{
+			desc:            "only allowed namespaces",
+			watchNamespaces: []string{"ns1", "ns3", "notexisting"},
+			expected:        []string{"ns1", "ns3"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			objects := []client.Object{pool.DeepCopy()}
+			for _, ns := range namespaces {
+				objects = append(objects, &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: ns, Labels: map[string]string{"team": "a"}}})
+			}
+			fakeClient, err := newFakeClient(objects)
+			if err != nil {
+				t.Fatalf("failed to create fake client: %v", err)
+			}
+
+			r := &PoolReconciler{
+				Client:         fakeClient,
+				Logger:         log.NewNopLogger(),
+				Scheme:         scheme,
+				Namespace:      testNamespace,
+				ValidateConfig: metallbcfg.DontValidate,
+				Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+					return SyncStateSuccess
+				},
+				ForceReload:     func() {},
+				WatchNamespaces: test.watchNamespaces,
+			}
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: testNamespace},
+			}
+			if _, err := r.Reconcile(context.TODO(), req); err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+
+			cfg := r.CurrentConfig()
+			if cfg == nil || cfg.Pools.ByName["pool1"] == nil {
+				t.Fatalf("expected pool1 to be part of the configuration")
+			}
+			allocated := sets.List(cfg.Pools.ByName["pool1"].ServiceAllocations.Namespaces)
+			if !cmp.Equal(test.expected, allocated) {
+				t.Fatalf("unexpected namespaces (-want +got)\n%s", cmp.Diff(test.expected, allocated))
+			}
+			for _, ns := range namespaces {
+				watched := r.watchesNamespace(&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: ns}})
+				if watched != sets.New(test.expected...).Has(ns) {
+					t.Fatalf("unexpected watched %v for namespace %s", watched, ns)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolControllerPoolsWithoutAdvertisements(t *testing.T) {
+	tests := []struct {
+		desc     string
+		advs     []client.Object
+		expected []string
+	}{
+		{
+			desc: "pools with advertisements",
+			advs: []client.Object{
+				&v1beta1.BGPAdvertisement{
+					ObjectMeta: v1.ObjectMeta{Name: "bgpadv", Namespace: testNamespace},
+					Spec:       v1beta1.BGPAdvertisementSpec{IPAddressPools: []string{"pool1"}},
+				},
+				&v1beta1.L2Advertisement{
+					ObjectMeta: v1.ObjectMeta{Name: "l2adv", Namespace: testNamespace},
+					Spec:       v1beta1.L2AdvertisementSpec{IPAddressPools: []string{"pool2"}},
+				},
+			},
+			expected: []string{},
+		},
+		{
+			desc: "pool without advertisements",
+			advs: []client.Object{
+				&v1beta1.L2Advertisement{
+					ObjectMeta: v1.ObjectMeta{Name: "l2adv", Namespace: testNamespace},
+					Spec:       v1beta1.L2AdvertisementSpec{IPAddressPools: []string{"pool1"}},
+				},
+			},
+			expected: []string{"pool pool2 has no advertisements, its IPs are not announced"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			objects := []client.Object{
+				&v1beta1.IPAddressPool{
+					ObjectMeta: v1.ObjectMeta{Name: "pool1", Namespace: testNamespace},
+					Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.20.0.0/16"}},
+				},
+				&v1beta1.IPAddressPool{
+					ObjectMeta: v1.ObjectMeta{Name: "pool2", Namespace: testNamespace},
+					Spec:       v1beta1.IPAddressPoolSpec{Addresses: []string{"10.30.0.0/16"}},
+				},
+				&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: testNamespace}},
+			}
+			fakeClient, err := newFakeClient(append(objects, test.advs...))
+			if err != nil {
+				t.Fatalf("failed to create fake client: %v", err)
+			}
+
+			recorder := record.NewFakeRecorder(10)
+			r := &PoolReconciler{
+				Client:         fakeClient,
+				Logger:         log.NewNopLogger(),
+				Scheme:         scheme,
+				Namespace:      testNamespace,
+				ValidateConfig: metallbcfg.DontValidate,
+				Handler: func(l log.Logger, pools *metallbcfg.Pools) SyncState {
+					return SyncStateSuccess
+				},
+				ForceReload: func() {},
+				Recorder:    recorder,
+			}
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: testNamespace,
+				},
+			}
+			if _, err := r.Reconcile(context.TODO(), req); err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+
+			if metric := testutil.ToFloat64(poolsWithoutAdvertisements); metric != float64(len(test.expected)) {
+				t.Fatalf("expected %d pools without advertisements, got %v", len(test.expected), metric)
+			}
+			if len(recorder.Events) != len(test.expected) {
+				t.Fatalf("expected %d events, got %d", len(test.expected), len(recorder.Events))
+			}
+			for range test.expected {
+				if event := <-recorder.Events; !strings.Contains(event, WarningPoolWithoutAdvertisements) {
+					t.Fatalf("unexpected event %q", event)
+				}
+			}
+
+			var ns corev1.Namespace
+			if err := fakeClient.Get(context.TODO(), client.ObjectKey{Name: testNamespace}, &ns); err != nil {
+				t.Fatalf("failed to get namespace: %v", err)
+			}
+			warnings := []ConfigWarning{}
+			if raw, ok := ns.Annotations[configWarningsAnnotation]; ok {
+				if err := json.Unmarshal([]byte(raw), &warnings); err != nil {
+					t.Fatalf("failed to unmarshal warnings: %v", err)
+				}
+			}
+			messages := []string{}
+			for _, w := range warnings {
+				if w.Category == WarningPoolWithoutAdvertisements {
+					messages = append(messages, w.Message)
+				}
+			}
+			if !cmp.Equal(test.expected, messages) {
+				t.Fatalf("unexpected warnings (-want +got)\n%s", cmp.Diff(test.expected, messages))
+			}
+		})
+	}
+}
+
+func TestPoolControllerPoolUpdates(t *testing.T) {
+	pool := func(generation int64, labels map[string]string) *v1beta1.IPAddressPool {
+		return &v1beta1.IPAddressPool{
+			ObjectMeta: v1.ObjectMeta{
+				Name:       "pool1",
+				Namespace:  testNamespace,
+				Generation: generation,
+				Labels:     labels,
+			},
+			Spec: v1beta1.IPAddressPoolSpec{Addresses: []string{"10.20.0.0/16"}},
+		}
+	}
+	tests := []struct {
+		desc     string
+		old      *v1beta1.IPAddressPool
+		new      *v1beta1.IPAddressPool
+		expected bool
+	}{
+		{
+			desc:     "status only update",
+			old:      pool(1, nil),
+			new:      pool(1, nil),
+			expected: false,
+		},
+		{
+			desc:     "spec update",
+			old:      pool(1, nil),
+			new:      pool(2, nil),
+			expected: true,
+		},
+		{
+			desc:     "labels update",
+			old:      pool(1, nil),
+			new:      pool(1, map[string]string{"foo": "bar"}),
+			expected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			e := event.UpdateEvent{ObjectOld: test.old, ObjectNew: test.new}
+			if got := ipAddressPoolChanged.Update(e); got != test.expected {
+				t.Fatalf("expected the update to be enqueued: %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
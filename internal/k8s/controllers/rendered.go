@@ -0,0 +1,118 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"go.universe.tf/metallb/internal/config"
+)
+
+// RenderedConfig holds the last configuration successfully applied by
+// the reconcilers, to be inspected for debugging.
+type RenderedConfig struct {
+	sync.RWMutex
+	cfg *config.Config
+}
+
+// observedConfig is the summary of the applied configuration served
+// in JSON format.
+type observedConfig struct {
+	Pools []observedPool `json:"pools"`
+	Peers []observedPeer `json:"peers"`
+}
+
+type observedPool struct {
+	Name              string   `json:"name"`
+	CIDRs             []string `json:"cidrs"`
+	AutoAssign        bool     `json:"autoAssign"`
+	BGPAdvertisements int      `json:"bgpAdvertisements"`
+	L2Advertisements  int      `json:"l2Advertisements"`
+}
+
+type observedPeer struct {
+	Name       string `json:"name"`
+	Address    string `json:"address,omitempty"`
+	Interface  string `json:"interface,omitempty"`
+	MyASN      uint32 `json:"myASN"`
+	ASN        uint32 `json:"asn"`
+	DynamicASN string `json:"dynamicASN,omitempty"`
+	Port       uint16 `json:"port"`
+	VRF        string `json:"vrf,omitempty"`
+}
+
+func (c *RenderedConfig) set(cfg *config.Config) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.cfg = cfg
+}
+
+// ServeHTTP writes the dump of the last applied configuration, with
+// the passwords of the peers retracted. The format=json query parameter
+// returns a JSON summary of the pools and of the peers instead.
+func (c *RenderedConfig) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.cfg == nil {
+		http.Error(w, "no configuration applied yet", http.StatusServiceUnavailable)
+		return
+	}
+	if req.URL.Query().Get("format") != "json" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(dumpConfig(c.cfg)))
+		return
+	}
+	res, err := json.Marshal(observedConfigFor(c.cfg))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(res)
+}
+
+func observedConfigFor(cfg *config.Config) observedConfig {
+	res := observedConfig{
+		Pools: []observedPool{},
+		Peers: []observedPeer{},
+	}
+	if cfg.Pools != nil {
+		for _, p := range cfg.Pools.ByName {
+			pool := observedPool{
+				Name:              p.Name,
+				CIDRs:             make([]string, 0, len(p.CIDR)),
+				AutoAssign:        p.AutoAssign,
+				BGPAdvertisements: len(p.BGPAdvertisements),
+				L2Advertisements:  len(p.L2Advertisements),
+			}
+			for _, cidr := range p.CIDR {
+				pool.CIDRs = append(pool.CIDRs, cidr.String())
+			}
+			res.Pools = append(res.Pools, pool)
+		}
+	}
+	for _, p := range cfg.Peers {
+		peer := observedPeer{
+			Name:       p.Name,
+			Interface:  p.Interface,
+			MyASN:      p.MyASN,
+			ASN:        p.ASN,
+			DynamicASN: p.DynamicASN,
+			Port:       p.Port,
+			VRF:        p.VRF,
+		}
+		if p.Addr != nil {
+			peer.Address = p.Addr.String()
+		}
+		res.Peers = append(res.Peers, peer)
+	}
+	sort.Slice(res.Pools, func(i, j int) bool { return res.Pools[i].Name < res.Pools[j].Name })
+	sort.Slice(res.Peers, func(i, j int) bool { return res.Peers[i].Name < res.Peers[j].Name })
+	return res
+}
@@ -32,6 +32,62 @@ var (
 		Name:      "config_stale_bool",
 		Help:      "1 if running on a stale configuration, because the latest config failed to load.",
 	})
+
+	missingCommunities = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "k8s_client",
+		Name:      "missing_communities_bool",
+		Help:      "1 if advertisements reference community aliases not defined by any community resource.",
+	})
+
+	consecutiveFailures = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "k8s_client",
+		Name:      "pool_handler_consecutive_failures",
+		Help:      "Number of consecutive failures of the pools handler, reset by the first success.",
+	})
+
+	orphanedAdvertisements = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "k8s_client",
+		Name:      "orphaned_advertisements",
+		Help:      "Number of advertisements referencing pools that don't exist.",
+	})
+
+	poolsWithoutAdvertisements = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "k8s_client",
+		Name:      "pools_without_advertisements",
+		Help:      "Number of pools without any BGP or L2 advertisement, whose IPs are allocated but never announced.",
+	})
+
+	selectorsWithoutNodes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "k8s_client",
+		Name:      "node_selectors_without_nodes",
+		Help:      "Number of BGP peers and L2 advertisements whose node selectors don't match any node.",
+	})
+
+	peersConfigured = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "bgp",
+		Name:      "peers_configured",
+		Help:      "BGP peers of the loaded configuration, by address, ASN, VRF and name. 1 if the session is expected to be established, 0 if the peer is disabled.",
+	}, []string{"peer", "asn", "vrf", "name"})
+
+	legacyResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Name:      "legacy_resources_total",
+		Help:      "Number of legacy resources the configuration is rendered from, by kind. Only the pool kind, counting the AddressPools, exists.",
+	}, []string{"kind"})
+
+	poolReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "metallb",
+		Subsystem: "pool",
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the reconciliations of the pools, by result. The result is error when the configuration is not applied because of an error, retried or not.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
 )
 
 func init() {
@@ -39,4 +95,12 @@ func init() {
 	prometheus.MustRegister(updateErrors)
 	prometheus.MustRegister(configLoaded)
 	prometheus.MustRegister(configStale)
+	prometheus.MustRegister(missingCommunities)
+	prometheus.MustRegister(consecutiveFailures)
+	prometheus.MustRegister(orphanedAdvertisements)
+	prometheus.MustRegister(poolsWithoutAdvertisements)
+	prometheus.MustRegister(selectorsWithoutNodes)
+	prometheus.MustRegister(peersConfigured)
+	prometheus.MustRegister(poolReconcileDuration)
+	prometheus.MustRegister(legacyResources)
 }
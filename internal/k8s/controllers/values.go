@@ -2,7 +2,12 @@
 
 package controllers
 
-import "errors"
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
 
 // SyncState is the result of calling synchronization callbacks.
 type SyncState int
@@ -30,3 +35,39 @@ const (
 )
 
 var errRetry = errors.New("event handling failed, retrying")
+
+// withJitter returns the given delay increased by a random amount up to
+// factor times the delay, so that the retries of several instances failing
+// at the same time are spread. A factor <= 0 returns the delay unchanged.
+// The result is capped to the longest duration.
+func withJitter(delay time.Duration, factor float64) time.Duration {
+	if factor <= 0 {
+		return delay
+	}
+	jittered := float64(delay) + rand.Float64()*factor*float64(delay)
+	if jittered >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(jittered)
+}
+
+// backoffDelay returns the delay before retrying after the given number of
+// consecutive failures: the base delay is doubled at each failure up to the
+// max delay, unless it is zero, and then spread by withJitter. Without a max
+// delay, the doubling stops before overflowing.
+func backoffDelay(base, max time.Duration, jitter float64, failures int) time.Duration {
+	delay := base
+	for i := 1; i < failures; i++ {
+		if max > 0 && delay >= max {
+			break
+		}
+		if delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return withJitter(delay, jitter)
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		desc     string
+		max      time.Duration
+		failures int
+		expected time.Duration
+	}{
+		{desc: "first failure", failures: 1, expected: time.Second},
+		{desc: "doubled", failures: 3, expected: 4 * time.Second},
+		{desc: "capped to max", max: 10 * time.Second, failures: 5, expected: 10 * time.Second},
+		{desc: "capped to max after many failures", max: 10 * time.Second, failures: 1000, expected: 10 * time.Second},
+		{desc: "no max after many failures", failures: 1000, expected: time.Second << 33},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			delay := backoffDelay(time.Second, test.max, 0, test.failures)
+			if delay != test.expected {
+				t.Fatalf("expected delay %s, got %s", test.expected, delay)
+			}
+		})
+	}
+}
+
+func TestBackoffDelayJitterNoOverflow(t *testing.T) {
+	for _, failures := range []int{40, 64, 1000} {
+		delay := backoffDelay(time.Second, 0, 1, failures)
+		if delay < time.Second<<33 {
+			t.Fatalf("expected the longest doubled delay after %d failures, got %s", failures, delay)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// configWarningsAnnotation is the annotation of the MetalLB namespace listing the
+// warnings raised by the last reconcile.
+const configWarningsAnnotation = "metallb.universe.tf/config-warnings"
+
+// Categories of the configuration warnings.
+const (
+	WarningMissingNamespace          = "MissingNamespace"
+	WarningConflictingPool           = "ConflictingPool"
+	WarningMissingPool               = "MissingPool"
+	WarningPoolWithoutAdvertisements = "PoolWithoutAdvertisements"
+	WarningNodeSelectorWithoutNodes  = "NodeSelectorWithoutNodes"
+	WarningMissingCommunity          = "MissingCommunity"
+)
+
+// ConfigWarning is a non fatal issue found while reconciling the configuration.
+type ConfigWarning struct {
+	Category string `json:"category"`
+	Message  string `json:"message"`
+	// Since is when the warning was first raised.
+	Since metav1.Time `json:"since"`
+}
+
+// exportWarnings stores the given warnings as an annotation of the namespace. Warnings
+// that were already raised keep their original timestamp, while the resolved ones are
+// dropped.
+func exportWarnings(ctx context.Context, c client.Client, namespace string, warnings []ConfigWarning) error {
+	var ns corev1.Namespace
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, &ns); err != nil {
+		return err
+	}
+
+	var previous []ConfigWarning
+	if raw, ok := ns.Annotations[configWarningsAnnotation]; ok {
+		// a corrupted annotation is just overwritten.
+		_ = json.Unmarshal([]byte(raw), &previous)
+	}
+
+	now := metav1.Now()
+	current := make([]ConfigWarning, 0, len(warnings))
+	for _, w := range warnings {
+		w.Since = now
+		for _, p := range previous {
+			if p.Category == w.Category && p.Message == w.Message {
+				w.Since = p.Since
+				break
+			}
+		}
+		current = append(current, w)
+	}
+
+	if len(current) == 0 && len(previous) == 0 {
+		return nil
+	}
+	if reflect.DeepEqual(current, previous) {
+		return nil
+	}
+
+	patched := ns.DeepCopy()
+	if len(current) == 0 {
+		delete(patched.Annotations, configWarningsAnnotation)
+	} else {
+		raw, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		metav1.SetMetaDataAnnotation(&patched.ObjectMeta, configWarningsAnnotation, string(raw))
+	}
+	return c.Patch(ctx, patched, client.MergeFrom(&ns))
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
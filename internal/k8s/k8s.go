@@ -98,23 +98,36 @@ type Client struct {
 // Config specifies the configuration of the Kubernetes
 // client/watcher.
 type Config struct {
-	ProcessName         string
-	NodeName            string
-	MetricsHost         string
-	MetricsPort         int
-	EnablePprof         bool
-	ReadEndpoints       bool
-	Logger              log.Logger
-	DisableEpSlices     bool
-	Namespace           string
-	ValidateConfig      config.Validate
-	EnableWebhook       bool
-	DisableCertRotation bool
-	WebhookSecretName   string
-	CertDir             string
-	CertServiceName     string
-	LoadBalancerClass   string
-	WebhookWithHTTP2    bool
+	ProcessName          string
+	NodeName             string
+	MetricsHost          string
+	MetricsPort          int
+	EnablePprof          bool
+	ReadEndpoints        bool
+	Logger               log.Logger
+	DisableEpSlices      bool
+	Namespace            string
+	ValidateConfig       config.Validate
+	EnableWebhook        bool
+	DisableCertRotation  bool
+	WebhookSecretName    string
+	CertDir              string
+	CertServiceName      string
+	LoadBalancerClass    string
+	WebhookWithHTTP2     bool
+	PoolResyncPeriod     time.Duration
+	PoolDryRun           bool
+	PoolObserveOnly      bool
+	PoolRetryBaseDelay   time.Duration
+	PoolRetryMaxDelay    time.Duration
+	PoolRetryJitter      float64
+	ConfigRetryBaseDelay time.Duration
+	ConfigRetryMaxDelay  time.Duration
+	ConfigRetryJitter    float64
+	LegacyPrecedence     bool
+	PoolWatchNamespaces  []string
+	PoolsInUse           func() []string
+	EnableConfigDump     bool
 	Listener
 }
 
@@ -160,6 +173,8 @@ func New(cfg *Config) (*Client, error) {
 
 	recorder := mgr.GetEventRecorderFor(cfg.ProcessName)
 
+	rendered := &controllers.RenderedConfig{}
+
 	reloadChan := make(chan event.GenericEvent)
 	reload := func() {
 		reloadChan <- controllers.NewReloadEvent()
@@ -183,6 +198,11 @@ func New(cfg *Config) (*Client, error) {
 			ValidateConfig: cfg.ValidateConfig,
 			Handler:        cfg.ConfigHandler,
 			ForceReload:    reload,
+			Rendered:       rendered,
+			RetryBaseDelay: cfg.ConfigRetryBaseDelay,
+			RetryMaxDelay:  cfg.ConfigRetryMaxDelay,
+			RetryJitter:    cfg.ConfigRetryJitter,
+			Recorder:       recorder,
 		}).SetupWithManager(mgr); err != nil {
 			level.Error(c.logger).Log("error", err, "unable to create controller", "config")
 			return nil, errors.Wrap(err, "failed to create config reconciler")
@@ -191,13 +211,24 @@ func New(cfg *Config) (*Client, error) {
 
 	if cfg.PoolChanged != nil {
 		if err = (&controllers.PoolReconciler{
-			Client:         mgr.GetClient(),
-			Logger:         cfg.Logger,
-			Scheme:         mgr.GetScheme(),
-			Namespace:      cfg.Namespace,
-			ValidateConfig: cfg.ValidateConfig,
-			Handler:        cfg.PoolHandler,
-			ForceReload:    reload,
+			Client:           mgr.GetClient(),
+			Logger:           cfg.Logger,
+			Scheme:           mgr.GetScheme(),
+			Namespace:        cfg.Namespace,
+			ValidateConfig:   cfg.ValidateConfig,
+			Handler:          cfg.PoolHandler,
+			ForceReload:      reload,
+			ResyncPeriod:     cfg.PoolResyncPeriod,
+			DryRun:           cfg.PoolDryRun,
+			ObserveOnly:      cfg.PoolObserveOnly,
+			RetryBaseDelay:   cfg.PoolRetryBaseDelay,
+			RetryMaxDelay:    cfg.PoolRetryMaxDelay,
+			RetryJitter:      cfg.PoolRetryJitter,
+			LegacyPrecedence: cfg.LegacyPrecedence,
+			WatchNamespaces:  cfg.PoolWatchNamespaces,
+			PoolsInUse:       cfg.PoolsInUse,
+			Recorder:         recorder,
+			Rendered:         rendered,
 		}).SetupWithManager(mgr); err != nil {
 			level.Error(c.logger).Log("error", err, "unable to create controller", "config")
 			return nil, errors.Wrap(err, "failed to create config reconciler")
@@ -291,6 +322,10 @@ func New(cfg *Config) (*Client, error) {
 			mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 		}
 
+		if cfg.EnableConfigDump {
+			mux.Handle("/debug/config", rendered)
+		}
+
 		server := &http.Server{
 			Addr:              net.JoinHostPort(cfg.MetricsHost, fmt.Sprint(cfg.MetricsPort)),
 			Handler:           mux,
@@ -210,6 +210,17 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 				break
 			}
 		}
+		if p.cfg.Disabled {
+			shouldRun = false
+		}
+
+		// Unnumbered sessions are not supported by the session managers yet.
+		if p.cfg.Interface != "" {
+			if shouldRun && p.session == nil {
+				level.Warn(l).Log("op", "syncPeers", "peer", p.cfg.Name, "interface", p.cfg.Interface, "msg", "unnumbered BGP sessions are not supported, skipping peer")
+			}
+			continue
+		}
 
 		// Now, compare current state to intended state, and correct.
 		if p.session != nil && !shouldRun {
@@ -234,14 +245,21 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 					MyASN:         p.cfg.MyASN,
 					RouterID:      routerID,
 					PeerASN:       p.cfg.ASN,
+					DynamicASN:    p.cfg.DynamicASN,
 					HoldTime:      p.cfg.HoldTime,
 					KeepAliveTime: p.cfg.KeepaliveTime,
+					ConnectTime:   p.cfg.ConnectTime,
 					Password:      p.cfg.Password,
 					CurrentNode:   c.myNode,
 					BFDProfile:    p.cfg.BFDProfile,
 					EBGPMultiHop:  p.cfg.EBGPMultiHop,
+					TTLSecurity:   p.cfg.TTLSecurity,
 					SessionName:   p.cfg.Name,
 					VRFName:       p.cfg.VRF,
+
+					EBGPMultiHopTTL:     p.cfg.EBGPMultiHopTTL,
+					GracefulRestart:     p.cfg.GracefulRestart,
+					GracefulRestartTime: p.cfg.GracefulRestartTime,
 				},
 			)
 
@@ -289,6 +307,7 @@ func (c *bgpController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, p
 					Mask: m,
 				},
 				LocalPref: adCfg.LocalPref,
+				NextHop:   adCfg.NextHop,
 			}
 			if len(adCfg.Peers) > 0 {
 				ad.Peers = make([]string, 0, len(adCfg.Peers))
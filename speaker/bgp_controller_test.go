@@ -2113,6 +2113,52 @@ func TestNodeSelectors(t *testing.T) {
 				"2.3.4.5:0": nil,
 			},
 		},
+
+		{
+			desc: "Disable the matching peer",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Addr: net.ParseIP("2.3.4.5"),
+						NodeSelectors: []labels.Selector{
+							mustSelector("host=frontend"),
+						},
+						Disabled: true,
+					},
+				},
+				Pools: &config.Pools{ByName: pools},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+			},
+		},
+
+		{
+			desc: "Enable the peer again",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Addr: net.ParseIP("2.3.4.5"),
+						NodeSelectors: []labels.Selector{
+							mustSelector("host=frontend"),
+						},
+					},
+				},
+				Pools: &config.Pools{ByName: pools},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+				"2.3.4.5:0": nil,
+			},
+		},
 	}
 
 	l := log.NewNopLogger()
@@ -122,6 +122,16 @@ func (c *layer2Controller) ShouldAnnounce(l log.Logger, name string, toAnnounce
 
 	level.Debug(l).Log("event", "shouldannounce", "protocol", "l2", "nodes", availableNodes, "service", name)
 
+	if leaderSelection(pool) {
+		// The leader is the first available node by name, announcing
+		// all the IPs of the pool.
+		sort.Strings(availableNodes)
+		if availableNodes[0] == c.myNode {
+			return ""
+		}
+		return "notOwner"
+	}
+
 	// Using the first IP should work for both single and dual stack.
 	ipString := toAnnounce[0].String()
 	// Sort the slice by the hash of node + load balancer ips. This
@@ -219,6 +229,17 @@ func activeEndpointExists(eps epslices.EpsOrSlices) bool {
 	return false
 }
 
+// leaderSelection tells if the IPs of the pool must be announced from the
+// leader node, because one of its L2 advertisements asks for it.
+func leaderSelection(pool *config.Pool) bool {
+	for _, adv := range pool.L2Advertisements {
+		if adv.NodeSelectionPolicy == config.NodeSelectionLeader {
+			return true
+		}
+	}
+	return false
+}
+
 func poolMatchesNodeL2(pool *config.Pool, node string) bool {
 	for _, adv := range pool.L2Advertisements {
 		if adv.Nodes[node] {
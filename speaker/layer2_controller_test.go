@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
@@ -1212,6 +1213,97 @@ func TestShouldAnnounce(t *testing.T) {
 	}
 }
 
+func TestShouldAnnounceNodeSelectionPolicy(t *testing.T) {
+	fakeSL := &fakeSpeakerList{
+		speakers: map[string]bool{
+			"iris1": true,
+			"iris2": true,
+		},
+	}
+	speakers := map[string]*controller{}
+	for _, node := range []string{"iris1", "iris2"} {
+		c, err := newController(controllerConfig{
+			MyNode: node,
+			Logger: log.NewNopLogger(),
+			SList:  fakeSL,
+		})
+		if err != nil {
+			t.Fatalf("creating controller: %s", err)
+		}
+		c.client = &testK8S{t: t}
+		speakers[node] = c
+	}
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+		},
+	}
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("iris1"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+
+	tests := []struct {
+		desc              string
+		policy            string
+		expectedOwnership map[string]int
+	}{
+		{
+			desc:              "default, the ips are spread among the nodes",
+			policy:            "",
+			expectedOwnership: map[string]int{"iris1": 7, "iris2": 9},
+		},
+		{
+			desc:              "leader, the first node announces all the ips",
+			policy:            config.NodeSelectionLeader,
+			expectedOwnership: map[string]int{"iris1": 16},
+		},
+	}
+	l := log.NewNopLogger()
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			pool := &config.Pool{
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				L2Advertisements: []*config.L2Advertisement{
+					{
+						Nodes:               map[string]bool{"iris1": true, "iris2": true},
+						NodeSelectionPolicy: test.policy,
+					},
+				},
+			}
+			ownership := map[string]int{}
+			for i := 1; i <= 16; i++ {
+				lbIP := net.ParseIP(fmt.Sprintf("10.20.30.%d", i))
+				owners := 0
+				for node, c := range speakers {
+					if c.protocolHandlers[config.Layer2].ShouldAnnounce(l, "balancer", []net.IP{lbIP}, pool, svc, eps, nil) == "" {
+						ownership[node]++
+						owners++
+					}
+				}
+				if owners != 1 {
+					t.Fatalf("expected one owner for %s, got %d", lbIP, owners)
+				}
+			}
+			if !reflect.DeepEqual(test.expectedOwnership, ownership) {
+				t.Fatalf("expected ownership %v, got %v", test.expectedOwnership, ownership)
+			}
+		})
+	}
+}
+
 func TestShouldAnnounceEPSlices(t *testing.T) {
 	fakeSL := &fakeSpeakerList{
 		speakers: map[string]bool{
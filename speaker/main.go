@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -69,18 +70,22 @@ func main() {
 	prometheus.MustRegister(announcing)
 
 	var (
-		namespace         = flag.String("namespace", os.Getenv("METALLB_NAMESPACE"), "config file and speakers namespace")
-		host              = flag.String("host", os.Getenv("METALLB_HOST"), "HTTP host address")
-		mlBindAddr        = flag.String("ml-bindaddr", os.Getenv("METALLB_ML_BIND_ADDR"), "Bind addr for MemberList (fast dead node detection)")
-		mlBindPort        = flag.String("ml-bindport", os.Getenv("METALLB_ML_BIND_PORT"), "Bind port for MemberList (fast dead node detection)")
-		mlLabels          = flag.String("ml-labels", os.Getenv("METALLB_ML_LABELS"), "Labels to match the speakers (for MemberList / fast dead node detection)")
-		mlSecretKeyPath   = flag.String("ml-secret-key-path", os.Getenv("METALLB_ML_SECRET_KEY_PATH"), "Path to where the MemberList's secret key is mounted")
-		myNode            = flag.String("node-name", os.Getenv("METALLB_NODE_NAME"), "name of this Kubernetes node (spec.nodeName)")
-		port              = flag.Int("port", 7472, "HTTP listening port")
-		logLevel          = flag.String("log-level", "info", fmt.Sprintf("log level. must be one of: [%s]", logging.Levels.String()))
-		disableEpSlices   = flag.Bool("disable-epslices", false, "Disable the usage of EndpointSlices and default to Endpoints instead of relying on the autodiscovery mechanism")
-		enablePprof       = flag.Bool("enable-pprof", false, "Enable pprof profiling")
-		loadBalancerClass = flag.String("lb-class", "", "load balancer class. When enabled, metallb will handle only services whose spec.loadBalancerClass matches the given lb class")
+		namespace            = flag.String("namespace", os.Getenv("METALLB_NAMESPACE"), "config file and speakers namespace")
+		host                 = flag.String("host", os.Getenv("METALLB_HOST"), "HTTP host address")
+		mlBindAddr           = flag.String("ml-bindaddr", os.Getenv("METALLB_ML_BIND_ADDR"), "Bind addr for MemberList (fast dead node detection)")
+		mlBindPort           = flag.String("ml-bindport", os.Getenv("METALLB_ML_BIND_PORT"), "Bind port for MemberList (fast dead node detection)")
+		mlLabels             = flag.String("ml-labels", os.Getenv("METALLB_ML_LABELS"), "Labels to match the speakers (for MemberList / fast dead node detection)")
+		mlSecretKeyPath      = flag.String("ml-secret-key-path", os.Getenv("METALLB_ML_SECRET_KEY_PATH"), "Path to where the MemberList's secret key is mounted")
+		myNode               = flag.String("node-name", os.Getenv("METALLB_NODE_NAME"), "name of this Kubernetes node (spec.nodeName)")
+		port                 = flag.Int("port", 7472, "HTTP listening port")
+		logLevel             = flag.String("log-level", "info", fmt.Sprintf("log level. must be one of: [%s]", logging.Levels.String()))
+		disableEpSlices      = flag.Bool("disable-epslices", false, "Disable the usage of EndpointSlices and default to Endpoints instead of relying on the autodiscovery mechanism")
+		enablePprof          = flag.Bool("enable-pprof", false, "Enable pprof profiling")
+		enableConfigDump     = flag.Bool("enable-config-dump", false, "Expose the applied configuration on /debug/config of the metrics endpoint")
+		configRetryBaseDelay = flag.Duration("config-retry-base-delay", 0, "Initial delay before reloading the configuration when applying it fails, doubled at each consecutive failure. 0 uses the default backoff")
+		configRetryMaxDelay  = flag.Duration("config-retry-max-delay", 5*time.Minute, "Maximum delay before reloading the configuration when applying it fails")
+		configRetryJitter    = flag.Float64("config-retry-jitter", 0, "Maximum random increase of the configuration retry delay, as a fraction of it (e.g. 0.2 for up to 20%)")
+		loadBalancerClass    = flag.String("lb-class", "", "load balancer class. When enabled, metallb will handle only services whose spec.loadBalancerClass matches the given lb class")
 	)
 	flag.Parse()
 
@@ -174,19 +179,23 @@ func main() {
 		Logger:          logger,
 		DisableEpSlices: *disableEpSlices,
 
-		MetricsHost:   *host,
-		MetricsPort:   *port,
-		EnablePprof:   *enablePprof,
-		ReadEndpoints: true,
-		Namespace:     *namespace,
+		MetricsHost:      *host,
+		MetricsPort:      *port,
+		EnablePprof:      *enablePprof,
+		EnableConfigDump: *enableConfigDump,
+		ReadEndpoints:    true,
+		Namespace:        *namespace,
 
 		Listener: k8s.Listener{
 			ServiceChanged: ctrl.SetBalancer,
 			ConfigChanged:  ctrl.SetConfig,
 			NodeChanged:    ctrl.SetNode,
 		},
-		ValidateConfig:    validateConfig,
-		LoadBalancerClass: *loadBalancerClass,
+		ValidateConfig:       validateConfig,
+		LoadBalancerClass:    *loadBalancerClass,
+		ConfigRetryBaseDelay: *configRetryBaseDelay,
+		ConfigRetryMaxDelay:  *configRetryMaxDelay,
+		ConfigRetryJitter:    *configRetryJitter,
 	})
 	if err != nil {
 		level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to create k8s client")